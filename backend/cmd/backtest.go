@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/rule"
+	"github.com/spf13/cobra"
+)
+
+var backtestFlags struct {
+	clientID   string
+	ruleID     string
+	expression string
+	scope      string
+	start      string
+	end        string
+	mode       string
+}
+
+var backtestCmd = &cobra.Command{
+
+	Use:   "backtest",
+	Short: "Replays the stored spend of a client through a rule and prints the alerts it would have sent",
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if backtestFlags.clientID == "" {
+			return fmt.Errorf("the client is required")
+		}
+		if (backtestFlags.ruleID == "") == (backtestFlags.expression == "") {
+			return fmt.Errorf("provide either a rule id or an expression")
+		}
+		start, err := time.Parse(time.DateOnly, backtestFlags.start)
+		if err != nil {
+			return fmt.Errorf("invalid start date: %w", err)
+		}
+		end, err := time.Parse(time.DateOnly, backtestFlags.end)
+		if err != nil {
+			return fmt.Errorf("invalid end date: %w", err)
+		}
+
+		dbSvc, err := db.NewClickhouseService(nil)
+		if err != nil {
+			return err
+		}
+		dbRule := &db.DbRule{
+			RuleID:     "backtest",
+			ClientID:   backtestFlags.clientID,
+			RuleName:   "backtest",
+			Expression: backtestFlags.expression,
+			Scope:      backtestFlags.scope,
+		}
+		if backtestFlags.ruleID != "" {
+			dbRule, err = dbSvc.GetRuleByID(backtestFlags.ruleID)
+			if err != nil {
+				return fmt.Errorf("could not load the rule: %w", err)
+			}
+		}
+		r, err := rule.FromDbRule(*dbRule)
+		if err != nil {
+			return err
+		}
+
+		alerts, err := rule.Backtest(r, dbSvc, backtestFlags.clientID, start, end, backtestFlags.mode)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "AT\tENTITY\tID\tNAME\tMETRIC\tVALUE\tBASELINE")
+		for _, alert := range alerts {
+			res := alert.Result
+			baseline := "-"
+			if res.Baseline != nil {
+				baseline = fmt.Sprint(res.Baseline)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\t%s\n",
+				alert.At.Format(time.DateTime), res.EntityType, res.EntityID, res.EntityName, res.Metric, res.Value, baseline)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("%d alerts\n", len(alerts))
+		return nil
+	},
+}
+
+func init() {
+	backtestCmd.Flags().StringVar(&backtestFlags.clientID, "client", "", "id of the client")
+	backtestCmd.Flags().StringVar(&backtestFlags.ruleID, "rule-id", "", "id of a stored rule")
+	backtestCmd.Flags().StringVar(&backtestFlags.expression, "expression", "", "rule expression, e.g. \"daily_spend > 100\"")
+	backtestCmd.Flags().StringVar(&backtestFlags.scope, "scope", "", "entity the expression is evaluated on (client, provider, business, account, campaign)")
+	backtestCmd.Flags().StringVar(&backtestFlags.start, "start", "", "first day, 2006-01-02")
+	backtestCmd.Flags().StringVar(&backtestFlags.end, "end", "", "last day, 2006-01-02")
+	backtestCmd.Flags().StringVar(&backtestFlags.mode, "mode", rule.BacktestDaily, "daily or snapshots")
+
+	rootCmd.AddCommand(backtestCmd)
+}
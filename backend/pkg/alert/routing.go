@@ -0,0 +1,144 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/notifier"
+)
+
+// Notification channels of the routing, the notifications routed to
+// ChannelDigest are sent by mail in the daily digest of the client.
+// ChannelWebhook is only a destination of the rules.
+const (
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+	ChannelSlack    = "slack"
+	ChannelDigest   = "digest"
+	ChannelWebhook  = "webhook"
+)
+
+// destinationChannels are the channels of the rule destinations, in the
+// order the notifications are sent in.
+var destinationChannels = []string{ChannelEmail, ChannelTelegram, ChannelSlack, ChannelWebhook}
+
+// Routing maps the severities to the channels their notifications are sent
+// to. For example:
+//
+//	{"critical": ["telegram", "slack"], "warning": ["email"], "info": ["digest"]}
+//
+// The severities without channels are sent to the default channel of the
+// client.
+type Routing map[common.Severity][]string
+
+// ParseRouting parses the JSON routing, an empty routing is nil.
+func ParseRouting(data string) (Routing, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var raw map[string][]string
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("invalid routing: %w", err)
+	}
+	routing := make(Routing, len(raw))
+	for key, channels := range raw {
+		severity, err := common.SeverityFromString(key)
+		if err != nil || key == "" {
+			return nil, fmt.Errorf("invalid severity %q in the routing", key)
+		}
+		seen := make(map[string]bool, len(channels))
+		for _, channel := range channels {
+			channel = strings.ToLower(channel)
+			switch channel {
+			case ChannelEmail, ChannelTelegram, ChannelSlack, ChannelDigest:
+			default:
+				return nil, fmt.Errorf("invalid channel %q, expected one of email, telegram, slack, digest", channel)
+			}
+			if !seen[channel] {
+				seen[channel] = true
+				routing[severity] = append(routing[severity], channel)
+			}
+		}
+	}
+	return routing, nil
+}
+
+// Channels returns the channels of the severity.
+func (r Routing) Channels(severity common.Severity) []string {
+	return r[severity]
+}
+
+// ParseNotificationWay returns the channel of the notification way of a rule,
+// empty for DEFAULT, the routing of the client.
+func ParseNotificationWay(way string) (string, error) {
+	switch strings.ToUpper(way) {
+	case "", "DEFAULT":
+		return "", nil
+	case "EMAIL":
+		return ChannelEmail, nil
+	case "TELEGRAM":
+		return ChannelTelegram, nil
+	case "SLACK":
+		return ChannelSlack, nil
+	}
+	return "", fmt.Errorf("invalid notification way %q, expected one of default, email, telegram, slack", way)
+}
+
+// ParseDestinations parses the JSON destinations of a rule, the addresses of
+// each channel. For example:
+//
+//	{"email": ["ops@example.com", "cfo@example.com"], "telegram": ["-1001234"], "webhook": ["https://example.com/hook"]}
+//
+// Empty destinations are nil.
+func ParseDestinations(data string) ([]notifier.Destination, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var raw map[string][]string
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("invalid destinations: %w", err)
+	}
+	for channel := range raw {
+		if !slices.Contains(destinationChannels, channel) {
+			return nil, fmt.Errorf("invalid destination channel %q, expected one of email, telegram, slack, webhook", channel)
+		}
+	}
+	res := make([]notifier.Destination, 0)
+	for _, channel := range destinationChannels {
+		for _, dest := range raw[channel] {
+			dest = strings.TrimSpace(dest)
+			switch channel {
+			case ChannelEmail:
+				if !db.IsValidEmail(dest) {
+					return nil, fmt.Errorf("invalid email address %q", dest)
+				}
+			case ChannelTelegram:
+				if dest == "" {
+					return nil, fmt.Errorf("empty telegram chat id")
+				}
+			case ChannelSlack, ChannelWebhook:
+				if u, err := url.Parse(dest); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+					return nil, fmt.Errorf("invalid %s url %q", channel, dest)
+				}
+			}
+			res = append(res, notifier.Destination{Type: DestinationType(channel), Dest: dest})
+		}
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// DestinationType returns the type of the notifications sent to the channel.
+func DestinationType(channel string) string {
+	if channel == ChannelEmail {
+		return "mail"
+	}
+	return channel
+}
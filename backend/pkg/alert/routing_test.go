@@ -0,0 +1,77 @@
+package alert
+
+import (
+	"testing"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+)
+
+func TestRouting(t *testing.T) {
+	routing, err := ParseRouting(`{"critical": ["telegram", "Slack", "telegram"], "INFO": ["digest"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channels := routing.Channels(common.CRITICAL); len(channels) != 2 || channels[0] != ChannelTelegram || channels[1] != ChannelSlack {
+		t.Fatalf("unexpected critical channels %v", channels)
+	}
+	if channels := routing.Channels(common.INFO); len(channels) != 1 || channels[0] != ChannelDigest {
+		t.Fatalf("unexpected info channels %v", channels)
+	}
+	if channels := routing.Channels(common.WARNING); len(channels) != 0 {
+		t.Fatalf("unexpected warning channels %v", channels)
+	}
+
+	// no routing, every severity goes to the default channel
+	routing, err = ParseRouting("")
+	if err != nil || routing.Channels(common.CRITICAL) != nil {
+		t.Fatalf("unexpected routing %v %v", routing, err)
+	}
+
+	for _, data := range []string{`{"urgent": ["slack"]}`, `{"": ["slack"]}`, `{"info": ["sms"]}`, `["slack"]`} {
+		if _, err := ParseRouting(data); err == nil {
+			t.Fatalf("%s: expected an error", data)
+		}
+	}
+}
+
+func TestDestinations(t *testing.T) {
+	dests, err := ParseDestinations(`{"webhook": ["https://example.com/hook"], "email": ["ops@example.com", "cfo@example.com"], "telegram": ["-1001234"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"mail:ops@example.com", "mail:cfo@example.com", "telegram:-1001234", "webhook:https://example.com/hook"}
+	if len(dests) != len(expected) {
+		t.Fatalf("expected %d destinations, got %v", len(expected), dests)
+	}
+	for idx, dest := range dests {
+		if dest.Type+":"+dest.Dest != expected[idx] {
+			t.Fatalf("expected %s, got %+v", expected[idx], dest)
+		}
+	}
+	if dests, err := ParseDestinations(`{"email": []}`); err != nil || dests != nil {
+		t.Fatalf("expected no destinations, got %v %v", dests, err)
+	}
+
+	invalid := []string{
+		`{"sms": ["123"]}`,
+		`{"email": ["not an email"]}`,
+		`{"telegram": [""]}`,
+		`{"slack": ["hooks.slack.com/x"]}`,
+		`{"webhook": ["ftp://example.com"]}`,
+		`{"email": "ops@example.com"}`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseDestinations(data); err == nil {
+			t.Fatalf("%s: expected an error", data)
+		}
+	}
+
+	for way, channel := range map[string]string{"": "", "default": "", "EMAIL": ChannelEmail, "slack": ChannelSlack} {
+		if got, err := ParseNotificationWay(way); err != nil || got != channel {
+			t.Fatalf("%q: expected %q, got %q %v", way, channel, got, err)
+		}
+	}
+	if _, err := ParseNotificationWay("SMS"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,146 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// HourRange is a range of hours of the day, as "09:00" to "18:00". A range
+// ending before its start spans midnight, as "22:00" to "07:00".
+type HourRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	from, to time.Duration
+}
+
+// contains reports whether the time of the day is in the range.
+func (h HourRange) contains(offset time.Duration) bool {
+	if h.from <= h.to {
+		return offset >= h.from && offset < h.to
+	}
+	return offset >= h.from || offset < h.to
+}
+
+// Schedule is a weekly time window, as the hours a rule is evaluated at or
+// the quiet hours of a client. For example:
+//
+//	{"days": ["mon", "tue", "wed", "thu", "fri"], "hours": [{"from": "09:00", "to": "18:00"}], "timezone": "Europe/Rome"}
+//
+// No days means every day, no hours the whole day. The day is the one of the
+// checked time in the timezone, a range spanning midnight needs both days.
+type Schedule struct {
+	Days     []string    `json:"days,omitempty"`
+	Hours    []HourRange `json:"hours,omitempty"`
+	Timezone string      `json:"timezone,omitempty"`
+
+	days     map[time.Weekday]bool
+	location *time.Location
+}
+
+// ParseSchedule parses the JSON schedule, in the given default timezone when
+// the schedule has none. An empty schedule is nil.
+func ParseSchedule(data string, timezone string) (*Schedule, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var s Schedule
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, fmt.Errorf("invalid schedule: %w", err)
+	}
+	if s.Timezone == "" {
+		s.Timezone = timezone
+	}
+	location, err := LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	s.location = location
+
+	s.days = make(map[time.Weekday]bool, len(s.Days))
+	for _, d := range s.Days {
+		day, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q, expected one of mon, tue, wed, thu, fri, sat, sun", d)
+		}
+		s.days[day] = true
+	}
+	for idx := range s.Hours {
+		h := &s.Hours[idx]
+		if h.from, err = parseHour(h.From); err != nil {
+			return nil, err
+		}
+		if h.to, err = parseHour(h.To); err != nil {
+			return nil, err
+		}
+		if h.from == h.to {
+			return nil, fmt.Errorf("empty hour range %s-%s", h.From, h.To)
+		}
+	}
+	return &s, nil
+}
+
+// LoadLocation returns the timezone, UTC when empty.
+func LoadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	return location, nil
+}
+
+// parseHour returns the offset in the day of the hour, as "09:30". "24:00"
+// is the end of the day.
+func parseHour(hour string) (time.Duration, error) {
+	if hour == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", hour)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hour %q, expected HH:MM", hour)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether the time is in the schedule. A nil schedule
+// contains no time.
+func (s *Schedule) Contains(t time.Time) bool {
+	if s == nil {
+		return false
+	}
+	local := t.In(s.location)
+	if len(s.days) > 0 && !s.days[local.Weekday()] {
+		return false
+	}
+	if len(s.Hours) == 0 {
+		return true
+	}
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+	for _, h := range s.Hours {
+		if h.contains(offset) {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether a rule with the schedule is evaluated at the time,
+// the rules without a schedule are always active.
+func (s *Schedule) Active(t time.Time) bool {
+	return s == nil || s.Contains(t)
+}
@@ -0,0 +1,88 @@
+package alert
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	// monday 20 may 2024
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 5, 20+day, hour, minute, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		schedule string
+		timezone string
+		times    map[time.Time]bool
+	}{
+		{
+			schedule: `{"days": ["mon", "tue", "wed", "thu", "fri"], "hours": [{"from": "09:00", "to": "18:00"}]}`,
+			times: map[time.Time]bool{
+				at(0, 9, 0): true, at(0, 17, 59): true, at(0, 18, 0): false, at(0, 8, 59): false,
+				at(5, 12, 0): false, at(4, 12, 0): true,
+			},
+		},
+		{
+			// quiet overnight, in the timezone of the client (UTC+2)
+			schedule: `{"hours": [{"from": "22:00", "to": "07:00"}]}`,
+			timezone: "Europe/Rome",
+			times: map[time.Time]bool{
+				at(0, 20, 0): true, at(0, 4, 59): true, at(0, 5, 0): false, at(0, 19, 59): false,
+			},
+		},
+		{
+			// the timezone of the schedule takes precedence
+			schedule: `{"days": ["sat", "sun"], "timezone": "America/New_York"}`,
+			timezone: "Europe/Rome",
+			times: map[time.Time]bool{
+				at(5, 3, 0): false, at(5, 12, 0): true, at(0, 3, 0): true, at(0, 4, 0): false,
+			},
+		},
+		{
+			schedule: `{"hours": [{"from": "00:00", "to": "06:00"}, {"from": "20:00", "to": "24:00"}]}`,
+			times: map[time.Time]bool{
+				at(0, 23, 59): true, at(0, 3, 0): true, at(0, 12, 0): false,
+			},
+		},
+	}
+	for _, c := range cases {
+		s, err := ParseSchedule(c.schedule, c.timezone)
+		if err != nil {
+			t.Fatalf("%s: %v", c.schedule, err)
+		}
+		for tm, expected := range c.times {
+			if s.Contains(tm) != expected {
+				t.Fatalf("%s: %s: expected %v", c.schedule, tm, expected)
+			}
+		}
+	}
+
+	// no schedule, a rule is always active and there are no quiet hours
+	s, err := ParseSchedule("", "")
+	if err != nil || s != nil {
+		t.Fatalf("expected no schedule, got %v %v", s, err)
+	}
+	if !s.Active(at(0, 3, 0)) || s.Contains(at(0, 3, 0)) {
+		t.Fatal("unexpected empty schedule")
+	}
+}
+
+func TestScheduleValidation(t *testing.T) {
+	invalid := []struct {
+		schedule string
+		timezone string
+	}{
+		{schedule: `{"days": ["monday"]}`},
+		{schedule: `{"hours": [{"from": "9", "to": "18:00"}]}`},
+		{schedule: `{"hours": [{"from": "25:00", "to": "18:00"}]}`},
+		{schedule: `{"hours": [{"from": "10:00", "to": "10:00"}]}`},
+		{schedule: `{"timezone": "Mars/Olympus"}`},
+		{schedule: `{}`, timezone: "Nowhere"},
+		{schedule: `[]`},
+	}
+	for _, c := range invalid {
+		if _, err := ParseSchedule(c.schedule, c.timezone); err == nil {
+			t.Fatalf("%s: expected an error", c.schedule)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/notifier"
+)
+
+// Action is the notification to send on the transition of an alert.
+type Action int
+
+const (
+	// None is a transition that is not notified, as a firing alert that
+	// keeps matching.
+	None Action = iota
+	// Fire notifies the alert that started firing.
+	Fire
+	// Renotify sends again the notification of an alert that keeps firing.
+	Renotify
+	// Resolve notifies the alert that stopped firing.
+	Resolve
+)
+
+// String returns the string representation of the action.
+func (a Action) String() string {
+	switch a {
+	case Fire:
+		return "fire"
+	case Renotify:
+		return "renotify"
+	case Resolve:
+		return "resolve"
+	}
+	return "none"
+}
+
+// Policy is the notification policy of the alerts of a rule.
+type Policy struct {
+	// RuleName names the alerts that are resolved without a result, as the
+	// ones of the entities that are no longer fetched.
+	RuleName string
+	// Cooldown is the time after a notification during which a new firing
+	// of the alert is not notified, so that a flapping rule is not sent at
+	// every evaluation.
+	Cooldown time.Duration
+	// Renotify is the interval the notification of a firing alert is sent
+	// again at, 0 never.
+	Renotify time.Duration
+	// NotifyResolved sends a notification when the alert stops firing.
+	NotifyResolved bool
+	// SustainCount is the number of consecutive evaluations, and SustainFor
+	// the time, the rule has to match for before the alert fires, so that a
+	// single noisy fetch does not fire it.
+	SustainCount int
+	SustainFor   time.Duration
+	// Schedule is the time window the rule is evaluated in, always when nil.
+	// Its firing alerts are left unchanged out of the window, the pending
+	// ones lose their streak.
+	Schedule *Schedule
+	// Severity routes the notifications of the rule.
+	Severity common.Severity
+	// Destinations are where the notifications of the rule are sent to,
+	// otherwise they are sent to the Way channel of the client, or routed by
+	// severity when empty.
+	Destinations []notifier.Destination
+	Way          string
+}
+
+// PolicyFromRule returns the notification policy of the rule, its schedule
+// is in the given timezone when it has none.
+func PolicyFromRule(r db.DbRule, timezone string) (Policy, error) {
+	schedule, err := ParseSchedule(r.Schedule, timezone)
+	if err != nil {
+		return Policy{}, err
+	}
+	severity, err := common.SeverityFromString(r.Severity)
+	if err != nil {
+		return Policy{}, err
+	}
+	way, err := ParseNotificationWay(r.NotificationWay)
+	if err != nil {
+		return Policy{}, err
+	}
+	destinations, err := ParseDestinations(r.Destinations)
+	if err != nil {
+		return Policy{}, err
+	}
+	return Policy{
+		RuleName:       r.RuleName,
+		Cooldown:       time.Duration(r.CooldownMinutes) * time.Minute,
+		Renotify:       time.Duration(r.RenotifyMinutes) * time.Minute,
+		NotifyResolved: r.NotifyResolved,
+		SustainCount:   int(r.SustainEvaluations),
+		SustainFor:     time.Duration(r.SustainMinutes) * time.Minute,
+		Schedule:       schedule,
+		Severity:       severity,
+		Destinations:   destinations,
+		Way:            way,
+	}, nil
+}
+
+// Transition returns the state of the alert after an evaluation of its rule,
+// and the notification to send. The previous state is nil for an alert that
+// never fired, the next one is nil when the state does not change.
+func Transition(prev *db.DbAlertState, matched bool, policy Policy, now time.Time) (*db.DbAlertState, Action) {
+	firing := prev != nil && prev.Status == db.AlertFiring
+	pending := prev != nil && prev.Status == db.AlertPending
+	if !matched {
+		if pending {
+			// the streak is broken before the alert fired
+			next := *prev
+			next.Status = db.AlertResolved
+			next.Matches = 0
+			next.UpdatedAt = now
+			return &next, None
+		}
+		if !firing {
+			return nil, None
+		}
+		next := *prev
+		next.Status = db.AlertResolved
+		next.ResolvedAt = now
+		next.UpdatedAt = now
+		// the resolution is only sent for a firing that was notified
+		if policy.NotifyResolved && notified(prev) {
+			return &next, Resolve
+		}
+		return &next, None
+	}
+
+	var next db.DbAlertState
+	if prev != nil {
+		next = *prev
+	}
+	next.UpdatedAt = now
+	if !firing {
+		if pending {
+			next.Matches++
+		} else {
+			next.Matches = 1
+			next.MatchedSince = now
+		}
+		if !sustained(&next, policy, now) {
+			next.Status = db.AlertPending
+			return &next, None
+		}
+		next.Status = db.AlertFiring
+		next.FiredAt = now
+		next.ResolvedAt = time.Time{}
+		if prev != nil && inCooldown(prev, policy, now) {
+			return &next, None
+		}
+		next.NotifiedAt = now
+		return &next, Fire
+	}
+
+	switch {
+	case !notified(prev):
+		// a firing during the cooldown is notified when the cooldown ends
+		if inCooldown(prev, policy, now) {
+			return nil, None
+		}
+		next.NotifiedAt = now
+		return &next, Fire
+	case policy.Renotify > 0 && now.Sub(prev.NotifiedAt) >= policy.Renotify:
+		next.NotifiedAt = now
+		return &next, Renotify
+	}
+	return nil, None
+}
+
+// notified reports whether the current firing period of the alert was notified.
+func notified(s *db.DbAlertState) bool {
+	return !s.NotifiedAt.IsZero() && !s.NotifiedAt.Before(s.FiredAt)
+}
+
+// sustained reports whether the pending alert matched for long enough to fire.
+func sustained(s *db.DbAlertState, policy Policy, now time.Time) bool {
+	return int(s.Matches) >= policy.SustainCount && now.Sub(s.MatchedSince) >= policy.SustainFor
+}
+
+func inCooldown(s *db.DbAlertState, policy Policy, now time.Time) bool {
+	return policy.Cooldown > 0 && !s.NotifiedAt.IsZero() && now.Sub(s.NotifiedAt) < policy.Cooldown
+}
+
+// Store persists the alert states of the clients.
+type Store interface {
+	GetAlertStates(clientID string) ([]db.DbAlertState, error)
+	UpsertAlertStates(states []db.DbAlertState) error
+}
+
+// Event is a transition of an alert to notify.
+type Event struct {
+	Action Action
+	Result *common.RuleResult
+	State  db.DbAlertState
+}
+
+// Notification returns the notification of the event for the destination.
+func (e Event) Notification(destType, dest string) *notifier.Notification {
+	result := e.Result
+	n := &notifier.Notification{
+		Severity:     result.Severity.String(),
+		CurrentSpend: result.CurrentSpend,
+		Threshold:    result.Threshold,
+		RuleName:     result.RuleName,
+		RuleID:       result.RuleID,
+		EntityType:   result.EntityType.String(),
+		EntityID:     result.EntityID,
+		EntityName:   result.EntityName,
+		Metric:       result.Metric,
+		Value:        result.Value,
+		Baseline:     result.Baseline,
+		DestType:     destType,
+		Dest:         dest,
+	}
+	switch e.Action {
+	case Renotify:
+		n.Reminder = true
+	case Resolve:
+		n.Resolved = true
+		n.Subject = fmt.Sprintf("Resolved: %s", result.RuleName)
+	}
+	return n
+}
+
+type stateKey struct {
+	ruleID     string
+	entityType string
+	entityID   string
+}
+
+// Process applies the results of the evaluation of the rules of a client to
+// the states of their alerts, and returns the transitions to notify. The
+// results are expected for every entity, matched or not, as the alerts of the
+// entities that no longer match are resolved. The results that could not be
+// evaluated leave their firing alert unchanged.
+//
+// The firing alerts without a result are the ones of the entities that are
+// no longer fetched, of the rules that were deleted or that are out of their
+// schedule. They are resolved, except for the rules out of their schedule and
+// when the fetch is partial, as the entities are then missing only for now.
+// The resolution of a deleted rule is not notified. The pending alerts that
+// were not evaluated, for any reason, lose their streak as the evaluations
+// they matched at have to be consecutive.
+func Process(store Store, clientID string, policies map[string]Policy, results []*common.RuleResult, partial bool, now time.Time) ([]Event, error) {
+	states, err := store.GetAlertStates(clientID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the alert states: %w", err)
+	}
+	current := make(map[stateKey]*db.DbAlertState, len(states))
+	for idx := range states {
+		s := &states[idx]
+		current[stateKey{s.RuleID, s.EntityType, s.EntityID}] = s
+	}
+
+	changed := make([]db.DbAlertState, 0)
+	events := make([]Event, 0)
+	apply := func(result *common.RuleResult, key stateKey) {
+		next, action := Transition(current[key], result.Result, policies[result.RuleID], now)
+		if next == nil {
+			return
+		}
+		next.ClientID = clientID
+		next.RuleID = result.RuleID
+		next.EntityType = key.entityType
+		next.EntityID = result.EntityID
+		next.EntityName = result.EntityName
+		current[key] = next
+		changed = append(changed, *next)
+		if action != None {
+			events = append(events, Event{Action: action, Result: result, State: *next})
+		}
+	}
+	evaluated := make(map[stateKey]bool, len(results))
+	failed := make(map[stateKey]bool)
+	for _, result := range results {
+		key := stateKey{result.RuleID, result.EntityType.String(), result.EntityID}
+		if result.Trace != nil && result.Trace.Error != "" {
+			failed[key] = true
+			continue
+		}
+		evaluated[key] = true
+		apply(result, key)
+	}
+	for _, s := range states {
+		key := stateKey{s.RuleID, s.EntityType, s.EntityID}
+		if evaluated[key] {
+			continue
+		}
+		policy, ok := policies[s.RuleID]
+		switch s.Status {
+		case db.AlertPending:
+		case db.AlertFiring:
+			if failed[key] || ok && (partial || !policy.Schedule.Active(now)) {
+				continue
+			}
+		default:
+			continue
+		}
+		entityType, _ := common.EntityTypeFromString(s.EntityType)
+		apply(&common.RuleResult{
+			RuleName:   policy.RuleName,
+			RuleID:     s.RuleID,
+			EntityType: entityType,
+			EntityID:   s.EntityID,
+			EntityName: s.EntityName,
+			Severity:   policy.Severity,
+		}, key)
+	}
+	if len(changed) > 0 {
+		if err := store.UpsertAlertStates(changed); err != nil {
+			return nil, fmt.Errorf("could not save the alert states: %w", err)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,239 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+type memoryStore struct {
+	states map[string][]db.DbAlertState
+}
+
+func (m *memoryStore) GetAlertStates(clientID string) ([]db.DbAlertState, error) {
+	return append([]db.DbAlertState(nil), m.states[clientID]...), nil
+}
+
+// UpsertAlertStates replaces the states with the same key, as the
+// ReplacingMergeTree does.
+func (m *memoryStore) UpsertAlertStates(states []db.DbAlertState) error {
+	for _, s := range states {
+		replaced := false
+		current := m.states[s.ClientID]
+		for idx, c := range current {
+			if c.RuleID == s.RuleID && c.EntityType == s.EntityType && c.EntityID == s.EntityID {
+				current[idx] = s
+				replaced = true
+			}
+		}
+		if !replaced {
+			current = append(current, s)
+		}
+		m.states[s.ClientID] = current
+	}
+	return nil
+}
+
+func TestTransition(t *testing.T) {
+	start := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	cases := []struct {
+		name    string
+		policy  Policy
+		matches []bool
+		actions []Action
+	}{
+		{
+			name:    "dedupe",
+			matches: []bool{false, true, true, true, false, false},
+			actions: []Action{None, Fire, None, None, None, None},
+		},
+		{
+			name:    "resolved",
+			policy:  Policy{NotifyResolved: true},
+			matches: []bool{true, true, false, false, true},
+			actions: []Action{Fire, None, Resolve, None, Fire},
+		},
+		{
+			name:    "renotify",
+			policy:  Policy{Renotify: 30 * time.Minute},
+			matches: []bool{true, true, true, true, true},
+			actions: []Action{Fire, None, Renotify, None, Renotify},
+		},
+		{
+			// the flapping alert is notified again once the cooldown ends
+			name:    "cooldown",
+			policy:  Policy{Cooldown: 45 * time.Minute, NotifyResolved: true},
+			matches: []bool{true, false, true, true, true},
+			actions: []Action{Fire, Resolve, None, Fire, None},
+		},
+		{
+			// the resolution of a firing that was not notified is not sent
+			name:    "cooldown resolved",
+			policy:  Policy{Cooldown: 60 * time.Minute, NotifyResolved: true},
+			matches: []bool{true, false, true, false},
+			actions: []Action{Fire, Resolve, None, None},
+		},
+		{
+			// a single noisy evaluation does not fire the alert
+			name:    "sustain evaluations",
+			policy:  Policy{SustainCount: 3, NotifyResolved: true},
+			matches: []bool{true, false, true, true, true, true, false},
+			actions: []Action{None, None, None, None, Fire, None, Resolve},
+		},
+		{
+			name:    "sustain duration",
+			policy:  Policy{SustainFor: 30 * time.Minute},
+			matches: []bool{true, true, false, true, true, true},
+			actions: []Action{None, None, None, None, None, Fire},
+		},
+	}
+	for _, c := range cases {
+		var state *db.DbAlertState
+		for idx, matched := range c.matches {
+			// an evaluation every 15 minutes
+			next, action := Transition(state, matched, c.policy, at(idx*15))
+			if action != c.actions[idx] {
+				t.Fatalf("%s: evaluation %d: expected %s, got %s", c.name, idx, c.actions[idx], action)
+			}
+			if next != nil {
+				state = next
+			}
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	store := &memoryStore{states: make(map[string][]db.DbAlertState)}
+	policies := map[string]Policy{"r1": {NotifyResolved: true}}
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	result := func(entityID string, matched bool) *common.RuleResult {
+		return &common.RuleResult{RuleID: "r1", RuleName: "spend", EntityType: common.ACCOUNT, EntityID: entityID, Result: matched}
+	}
+
+	events, err := Process(store, "c1", policies, []*common.RuleResult{result("a1", true), result("a2", true)}, false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Action != Fire || events[1].Action != Fire {
+		t.Fatalf("expected an alert for each account, got %+v", events)
+	}
+	if len(store.states["c1"]) != 2 || store.states["c1"][0].Status != db.AlertFiring {
+		t.Fatalf("unexpected states %+v", store.states["c1"])
+	}
+
+	// a1 keeps matching, a2 is resolved, a3 could not be evaluated
+	failed := result("a3", true)
+	failed.Trace = &common.Trace{Error: "missing field"}
+	events, err = Process(store, "c1", policies, []*common.RuleResult{result("a1", true), result("a2", false), failed}, false, now.Add(15*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != Resolve || events[0].Result.EntityID != "a2" {
+		t.Fatalf("expected the resolution of a2, got %+v", events)
+	}
+	n := events[0].Notification("mail", "user@example.com")
+	if !n.Resolved || n.Subject != "Resolved: spend" || n.EntityID != "a2" {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+	if len(store.states["c1"]) != 2 || store.states["c1"][1].Status != db.AlertResolved {
+		t.Fatalf("unexpected states %+v", store.states["c1"])
+	}
+}
+
+func TestProcessWithoutResults(t *testing.T) {
+	// monday 20 may 2024, out of the weekend schedule of r2
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	weekend, err := ParseSchedule(`{"days": ["sat", "sun"]}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := map[string]Policy{
+		"r1": {RuleName: "spend", NotifyResolved: true},
+		"r2": {RuleName: "weekend", NotifyResolved: true, Schedule: weekend},
+	}
+	firing := func(ruleID, entityID string) db.DbAlertState {
+		fired := now.Add(-time.Hour)
+		return db.DbAlertState{ClientID: "c1", RuleID: ruleID, EntityType: "ACCOUNT", EntityID: entityID, EntityName: "Account " + entityID,
+			Status: db.AlertFiring, FiredAt: fired, NotifiedAt: fired}
+	}
+	// r3 was deleted
+	store := &memoryStore{states: map[string][]db.DbAlertState{
+		"c1": {firing("r1", "a1"), firing("r1", "a2"), firing("r2", "a1"), firing("r3", "a1")},
+	}}
+	results := []*common.RuleResult{{RuleID: "r1", RuleName: "spend", EntityType: common.ACCOUNT, EntityID: "a1", Result: true}}
+
+	// a2 may only have failed to be fetched, the alert of r3 is resolved
+	// without notification
+	events, err := Process(store, "c1", policies, results, true, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no notification, got %+v", events)
+	}
+	expected := []string{db.AlertFiring, db.AlertFiring, db.AlertFiring, db.AlertResolved}
+	for idx, s := range store.states["c1"] {
+		if s.Status != expected[idx] {
+			t.Fatalf("%s %s: expected %s, got %s", s.RuleID, s.EntityID, expected[idx], s.Status)
+		}
+	}
+
+	// a2 is no longer fetched
+	events, err = Process(store, "c1", policies, results, false, now.Add(15*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != Resolve || events[0].Result.EntityID != "a2" {
+		t.Fatalf("expected the resolution of a2, got %+v", events)
+	}
+	n := events[0].Notification("mail", "user@example.com")
+	if n.Subject != "Resolved: spend" || n.EntityName != "Account a2" || n.EntityType != "ACCOUNT" {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+	expected = []string{db.AlertFiring, db.AlertResolved, db.AlertFiring, db.AlertResolved}
+	for idx, s := range store.states["c1"] {
+		if s.Status != expected[idx] {
+			t.Fatalf("%s %s: expected %s, got %s", s.RuleID, s.EntityID, expected[idx], s.Status)
+		}
+	}
+}
+
+func TestProcessBreaksStreaks(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	weekend, err := ParseSchedule(`{"days": ["sat", "sun"]}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := map[string]Policy{
+		"r1": {RuleName: "spend", SustainCount: 3},
+		"r2": {RuleName: "weekend", SustainCount: 3, Schedule: weekend},
+	}
+	pending := func(ruleID, entityID string) db.DbAlertState {
+		return db.DbAlertState{ClientID: "c1", RuleID: ruleID, EntityType: "ACCOUNT", EntityID: entityID,
+			Status: db.AlertPending, Matches: 2, MatchedSince: now.Add(-30 * time.Minute)}
+	}
+	store := &memoryStore{states: map[string][]db.DbAlertState{
+		"c1": {pending("r1", "a1"), pending("r1", "a2"), pending("r1", "a3"), pending("r2", "a1")},
+	}}
+	// a1 keeps matching, a2 is missing from a partial fetch, a3 could not be
+	// evaluated and r2 is out of its schedule
+	failed := &common.RuleResult{RuleID: "r1", EntityType: common.ACCOUNT, EntityID: "a3", Result: true, Trace: &common.Trace{Error: "missing field"}}
+	results := []*common.RuleResult{{RuleID: "r1", EntityType: common.ACCOUNT, EntityID: "a1", Result: true}, failed}
+	events, err := Process(store, "c1", policies, results, true, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != Fire || events[0].Result.EntityID != "a1" {
+		t.Fatalf("expected the firing of a1, got %+v", events)
+	}
+	for _, s := range store.states["c1"][1:] {
+		if s.Status != db.AlertResolved || s.Matches != 0 {
+			t.Fatalf("%s %s: expected the streak to be broken, got %+v", s.RuleID, s.EntityID, s)
+		}
+	}
+}
@@ -8,6 +8,8 @@ import (
 func registerRoutes(r *gin.Engine, prefix string, dbSvc db.DbService) {
 	userRoute(r, prefix, dbSvc)
 	providerRoute(r, prefix, dbSvc)
+	metricRoute(r, prefix)
+	templateRoute(r, prefix, dbSvc)
 }
 
 func StartAPI(dbSvc db.DbService) error {
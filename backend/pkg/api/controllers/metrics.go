@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s0und0fs1lence/ads-zero/pkg/metric"
+)
+
+func NewMetricController(group *gin.RouterGroup) {
+	group.GET("/", handleGetMetrics())
+	group.GET("/:name", handleGetMetric())
+}
+
+// handleGetMetrics lists every metric that can be used in a rule.
+func handleGetMetrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"data": metric.All(),
+		})
+	}
+}
+
+func handleGetMetric() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		m, ok := metric.Lookup(ctx.Param("name"))
+		if !ok {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error": "unknown metric",
+			})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"data": m,
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/rule"
+)
+
+func NewTemplateController(dbSvc db.DbService, group *gin.RouterGroup) {
+	group.GET("/", handleGetTemplates())
+	group.GET("/:name", handleGetTemplate())
+	group.POST("/instantiate", handleInstantiateTemplate(dbSvc))
+}
+
+// handleGetTemplates lists the catalog of rule templates.
+func handleGetTemplates() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"data": rule.Templates(),
+		})
+	}
+}
+
+func handleGetTemplate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		t, ok := rule.LookupTemplate(ctx.Param("name"))
+		if !ok {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error": "unknown template",
+			})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"data": t,
+		})
+	}
+}
+
+// handleInstantiateTemplate creates a rule of the client from a template,
+// the rule is then a normal rule of the client.
+func handleInstantiateTemplate(dbSvc db.DbService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req db.RuleTemplateRequest
+		if err := ctx.BindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if !req.IsValid() {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request payload",
+			})
+			return
+		}
+		t, ok := rule.LookupTemplate(req.Template)
+		if !ok {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error": "unknown template",
+			})
+			return
+		}
+		client, err := dbSvc.GetClientByID(req.ClientID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error": "client not found",
+			})
+			return
+		}
+		newRule, err := t.Instantiate(req.ClientID, req.RuleName, req.Params)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if status, err := insertRule(dbSvc, &newRule, client); err != nil {
+			ctx.JSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusCreated, gin.H{
+			"data": newRule,
+		})
+	}
+}
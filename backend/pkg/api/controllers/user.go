@@ -1,11 +1,18 @@
 package controllers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/oklog/ulid/v2"
+	"github.com/s0und0fs1lence/ads-zero/pkg/alert"
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
 	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/rule"
 )
 
 func NewUserController(dbSvc db.DbService, group *gin.RouterGroup) {
@@ -24,6 +31,184 @@ func NewUserController(dbSvc db.DbService, group *gin.RouterGroup) {
 	group.POST("/rules/create", handleCreateRule(dbSvc))
 	group.PUT("/rules/update", handleUpdateRule(dbSvc))
 	group.DELETE("/rules/delete", handleDeleteRule(dbSvc))
+	group.POST("/rules/backtest", handleBacktestRule(dbSvc))
+	group.POST("/rules/explain", handleExplainRule(dbSvc))
+	group.GET("/rules/alerts", handleGetAlerts(dbSvc))
+	group.GET("/rules/alerts/why", handleGetAlertTrace(dbSvc))
+}
+
+// requestRule returns the stored rule of the client, or the given one.
+func requestRule(dbSvc db.DbService, clientID, ruleID string, dbRule *db.DbRule) (rule.Rule, int, error) {
+	if ruleID != "" {
+		var err error
+		dbRule, err = dbSvc.GetRuleByID(ruleID)
+		if err != nil || dbRule.ClientID != clientID {
+			return nil, http.StatusNotFound, fmt.Errorf("rule not found")
+		}
+	}
+	r, err := rule.FromDbRule(*dbRule)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return r, http.StatusOK, nil
+}
+
+func handleExplainRule(dbSvc db.DbService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req db.RuleExplainRequest
+		if err := ctx.BindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if !req.IsValid() {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request payload",
+			})
+			return
+		}
+		day, err := time.Parse(time.DateOnly, req.Day)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid day",
+			})
+			return
+		}
+		r, status, err := requestRule(dbSvc, req.ClientID, req.RuleID, req.Rule)
+		if err != nil {
+			ctx.JSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		task, err := common.NewDayReplayTask(dbSvc, req.ClientID, day)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		results, err := rule.Evaluate(r, task)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"data": results,
+		})
+	}
+}
+
+func handleGetAlerts(dbSvc db.DbService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req db.ClientSpendRequest
+		if err := ctx.BindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		res, err := dbSvc.GetAlertsByClientID(req.ClientID, req.Start, req.End)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"data": res,
+		})
+	}
+}
+
+// handleGetAlertTrace tells why an alert fired.
+func handleGetAlertTrace(dbSvc db.DbService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req db.AlertRequest
+		if err := ctx.BindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		alert, err := dbSvc.GetAlertByID(req.AlertID)
+		if err != nil || alert.ClientID != req.ClientID {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error": "alert not found",
+			})
+			return
+		}
+		var trace *common.Trace
+		if alert.Trace != "" {
+			if err := json.Unmarshal([]byte(alert.Trace), &trace); err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"data": gin.H{
+				"alert": alert,
+				"trace": trace,
+			},
+		})
+	}
+}
+
+func handleBacktestRule(dbSvc db.DbService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req db.RuleBacktestRequest
+		if err := ctx.BindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if !req.IsValid() {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request payload",
+			})
+			return
+		}
+		start, err := time.Parse(time.DateOnly, req.Start)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid start date",
+			})
+			return
+		}
+		end, err := time.Parse(time.DateOnly, req.End)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid end date",
+			})
+			return
+		}
+
+		r, status, err := requestRule(dbSvc, req.ClientID, req.RuleID, req.Rule)
+		if err != nil {
+			ctx.JSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		alerts, err := rule.Backtest(r, dbSvc, req.ClientID, start, end, req.Mode)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"data": alerts,
+		})
+	}
 }
 
 func handleDeleteRule(dbSvc db.DbService) gin.HandlerFunc {
@@ -38,10 +223,98 @@ func handleUpdateRule(dbSvc db.DbService) gin.HandlerFunc {
 	}
 }
 
+// validateClientUpdate checks the timezone, the quiet hours and the routing
+// of the update.
+func validateClientUpdate(update *db.ClientUpdate) error {
+	timezone := ""
+	if update.Timezone != nil {
+		timezone = *update.Timezone
+		if _, err := alert.LoadLocation(timezone); err != nil {
+			return err
+		}
+	}
+	if update.QuietHours != nil {
+		if _, err := alert.ParseSchedule(*update.QuietHours, timezone); err != nil {
+			return fmt.Errorf("invalid quiet hours: %w", err)
+		}
+	}
+	if update.Routing != nil {
+		if _, err := alert.ParseRouting(*update.Routing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func handleCreateRule(dbSvc db.DbService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		panic("unimplemented")
+		var newRule db.DbRule
+		if err := ctx.BindJSON(&newRule); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if newRule.ClientID == "" || newRule.RuleName == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request payload",
+			})
+			return
+		}
+		client, err := dbSvc.GetClientByID(newRule.ClientID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error": "client not found",
+			})
+			return
+		}
+		if status, err := insertRule(dbSvc, &newRule, client); err != nil {
+			ctx.JSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusCreated, gin.H{
+			"data": newRule,
+		})
+	}
+}
+
+// insertRule validates the rule and inserts it for the client, with the
+// defaults of the columns. The returned status is the one of the error.
+func insertRule(dbSvc db.DbService, newRule *db.DbRule, client *db.DbClient) (int, error) {
+	newRule.RuleID = ulid.Make().String()
+	// the rule is compiled as the worker would load it, so that the
+	// invalid ones are refused here instead of failing silently later
+	if _, err := rule.FromDbRule(*newRule); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if _, err := alert.PolicyFromRule(*newRule, client.Timezone); err != nil {
+		return http.StatusBadRequest, err
 	}
+	// the enum columns are upper case
+	newRule.Scope = strings.ToUpper(newRule.Scope)
+	if newRule.Scope == "" {
+		newRule.Scope = common.CLIENT.String()
+	}
+	newRule.RuleType = strings.ToUpper(newRule.RuleType)
+	if newRule.RuleType == "" {
+		newRule.RuleType = rule.RuleTypeThreshold
+	}
+	newRule.Severity = strings.ToUpper(newRule.Severity)
+	if newRule.Severity == "" {
+		newRule.Severity = common.WARNING.String()
+	}
+	newRule.NotificationWay = strings.ToUpper(newRule.NotificationWay)
+	if newRule.NotificationWay == "" {
+		newRule.NotificationWay = "DEFAULT"
+	}
+	newRule.InsertedAt = time.Now().UTC()
+	newRule.UpdatedAt = newRule.InsertedAt
+	if err := dbSvc.InsertRule(newRule); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, nil
 }
 
 func handleGetRules(dbSvc db.DbService) gin.HandlerFunc {
@@ -221,6 +494,12 @@ func handleUpdateUser(dbSvc db.DbService) gin.HandlerFunc {
 			})
 			return
 		}
+		if err := validateClientUpdate(&update); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
 		client, err := dbSvc.UpdateClient(&update)
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{
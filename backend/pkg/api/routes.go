@@ -17,3 +17,13 @@ func providerRoute(router *gin.Engine, prefix string, dbSvc db.DbService) {
 	providerGroup := router.Group(fmt.Sprintf("%s/provider", prefix))
 	controllers.NewProviderController(dbSvc, providerGroup)
 }
+
+func metricRoute(router *gin.Engine, prefix string) {
+	metricGroup := router.Group(fmt.Sprintf("%s/metrics", prefix))
+	controllers.NewMetricController(metricGroup)
+}
+
+func templateRoute(router *gin.Engine, prefix string, dbSvc db.DbService) {
+	templateGroup := router.Group(fmt.Sprintf("%s/templates", prefix))
+	controllers.NewTemplateController(dbSvc, templateGroup)
+}
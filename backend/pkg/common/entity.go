@@ -0,0 +1,167 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/metric"
+)
+
+// EntityEvent is the part of a FetchTask that belongs to a single client,
+// provider, business, account or campaign, so that rules can be evaluated
+// on each entity on its own.
+type EntityEvent struct {
+	EntityType EntityType
+	EntityID   string
+	EntityName string
+	Accounts   []db.DbAccountSpend
+	Campaigns  []db.DbCampaignSpend
+	task       *FetchTask
+	// key identifies the entity among the ones of every provider
+	key string
+}
+
+// entityKey returns the key of the entity of the given type the row belongs
+// to: the ids of the businesses, accounts and campaigns are only unique
+// within their provider.
+func entityKey(entity EntityType, provider, business, account, campaign string) (string, error) {
+	switch entity {
+	case PROVIDER:
+		return provider, nil
+	case BUSINESS:
+		return provider + "|" + business, nil
+	case ACCOUNT:
+		return provider + "|" + account, nil
+	case CAMPAIGN:
+		return provider + "|" + account + "|" + campaign, nil
+	}
+	return "", fmt.Errorf("rules cannot be evaluated per %s", entity)
+}
+
+// Events splits the task into one EntityEvent per entity of the given type.
+// The CLIENT type always returns a single event holding the whole task.
+func (t *FetchTask) Events(entity EntityType) ([]*EntityEvent, error) {
+	if entity == CLIENT {
+		evt := &EntityEvent{
+			EntityType: CLIENT,
+			Accounts:   t.Accounts,
+			Campaigns:  t.Campaigns,
+			task:       t,
+		}
+		if len(t.Accounts) > 0 {
+			evt.EntityID = t.Accounts[0].ClientID
+			evt.EntityName = t.Accounts[0].ClientID
+		}
+		return []*EntityEvent{evt}, nil
+	}
+
+	if _, err := entityKey(entity, "", "", "", ""); err != nil {
+		return nil, err
+	}
+
+	// keep the events in the order in which the entities were fetched
+	res := make([]*EntityEvent, 0)
+	byKey := make(map[string]*EntityEvent)
+	get := func(k, id, name string) *EntityEvent {
+		evt, ok := byKey[k]
+		if !ok {
+			evt = &EntityEvent{
+				EntityType: entity,
+				EntityID:   id,
+				EntityName: name,
+				Accounts:   make([]db.DbAccountSpend, 0),
+				Campaigns:  make([]db.DbCampaignSpend, 0),
+				task:       t,
+				key:        k,
+			}
+			byKey[k] = evt
+			res = append(res, evt)
+		}
+		return evt
+	}
+
+	if entity != CAMPAIGN {
+		for _, acc := range t.Accounts {
+			id, name := acc.ProviderID, acc.ProviderType.String()
+			switch entity {
+			case BUSINESS:
+				id, name = acc.BusinessID, acc.BusinessName
+			case ACCOUNT:
+				id, name = acc.AccountID, acc.AccountName
+			}
+			k, _ := entityKey(entity, acc.ProviderID, acc.BusinessID, acc.AccountID, "")
+			evt := get(k, id, name)
+			evt.Accounts = append(evt.Accounts, acc)
+		}
+	}
+	for _, camp := range t.Campaigns {
+		k, _ := entityKey(entity, camp.ProviderID, camp.BusinessID, camp.AccountID, camp.CampaignID)
+		if entity == CAMPAIGN {
+			evt := get(k, camp.CampaignID, camp.CampaignName)
+			evt.Campaigns = append(evt.Campaigns, camp)
+			continue
+		}
+		// campaigns of an entity without account data are not evaluated
+		if evt, ok := byKey[k]; ok {
+			evt.Campaigns = append(evt.Campaigns, camp)
+		}
+	}
+	return res, nil
+}
+
+// GetTotalSpend returns the spend of the entity.
+func (e *EntityEvent) GetTotalSpend() (res float64) {
+	if e.EntityType == CAMPAIGN {
+		for _, camp := range e.Campaigns {
+			res += camp.Spend
+		}
+		return res
+	}
+	for _, acc := range e.Accounts {
+		res += acc.Spend
+	}
+	return res
+}
+
+// rows returns the rows the entity metrics are computed from: campaigns are
+// measured on their own rows, every other entity on its account rows.
+func (e *EntityEvent) rows() []metric.Row {
+	if e.EntityType == CAMPAIGN {
+		return metric.CampaignRows(e.Campaigns)
+	}
+	return metric.AccountRows(e.Accounts)
+}
+
+// GetFieldValue implements Event, the field is resolved through the metric registry.
+func (e *EntityEvent) GetFieldValue(field string) (interface{}, error) {
+	m, ok := metric.Lookup(field)
+	if !ok {
+		return nil, fmt.Errorf("invalid field")
+	}
+	return m.Compute(e.rows())
+}
+
+// GetFieldAsInt64 implements Event.
+func (e *EntityEvent) GetFieldAsInt64(field string) (int64, error) {
+	r, err := e.GetFieldAsFloat64(field)
+	return int64(r), err
+}
+
+// GetFieldAsUInt64 implements Event.
+func (e *EntityEvent) GetFieldAsUInt64(field string) (uint64, error) {
+	r, err := e.GetFieldAsFloat64(field)
+	return uint64(r), err
+}
+
+// GetFieldAsFloat64 implements Event.
+func (e *EntityEvent) GetFieldAsFloat64(field string) (float64, error) {
+	r, err := e.GetFieldValue(field)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := r.(float64)
+	if !ok {
+		return 0, fmt.Errorf("field %s is not numeric", field)
+	}
+	return f, nil
+}
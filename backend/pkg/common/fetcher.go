@@ -2,10 +2,12 @@ package common
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/metric"
 )
 
 type EntityType uint8
@@ -17,8 +19,49 @@ const (
 	CAMPAIGN
 	ADSET
 	AD
+	CLIENT
 )
 
+func (e EntityType) String() string {
+	switch e {
+	case PROVIDER:
+		return "PROVIDER"
+	case BUSINESS:
+		return "BUSINESS"
+	case ACCOUNT:
+		return "ACCOUNT"
+	case CAMPAIGN:
+		return "CAMPAIGN"
+	case ADSET:
+		return "ADSET"
+	case AD:
+		return "AD"
+	case CLIENT:
+		return "CLIENT"
+	}
+	return "UNKNOWN"
+}
+
+func EntityTypeFromString(s string) (EntityType, error) {
+	switch strings.ToUpper(s) {
+	case "PROVIDER":
+		return PROVIDER, nil
+	case "BUSINESS":
+		return BUSINESS, nil
+	case "ACCOUNT":
+		return ACCOUNT, nil
+	case "CAMPAIGN":
+		return CAMPAIGN, nil
+	case "ADSET":
+		return ADSET, nil
+	case "AD":
+		return AD, nil
+	case "CLIENT":
+		return CLIENT, nil
+	}
+	return CLIENT, fmt.Errorf("invalid entity type %q", s)
+}
+
 type FetchError struct {
 	EntityID   string
 	EntityType EntityType
@@ -33,6 +76,7 @@ type FetchTask struct {
 	Accounts  []db.DbAccountSpend
 	Campaigns []db.DbCampaignSpend
 	Errors    []FetchError
+	history   *taskHistory
 }
 
 func NewFetchTask(start, end time.Time) *FetchTask {
@@ -61,41 +105,13 @@ func (t *FetchTask) GetTotalSpend() float64 {
 	return res
 }
 
-// implement fetchTask
-func (t *FetchTask) getDailySpendValue() (res float64) {
-	for _, acc := range t.Accounts {
-		res += acc.Spend
-	}
-	return res
-}
-
+// GetFieldValue implements Event, the field is resolved through the metric registry.
 func (t *FetchTask) GetFieldValue(field string) (res interface{}, err error) {
-
-	switch field {
-	case "DAILY_SPEND":
-		return t.getDailySpendValue(), nil
-	case "AVG_CPC":
-		return t.getAvgCPCValue(), nil
-	case "AVG_CPM":
-		return t.getAvgCPMValue(), nil
-	case "DAILY_CONVERSIONS":
-		return t.getDailyConversionsValue(), nil
-	default:
+	m, ok := metric.Lookup(field)
+	if !ok {
 		return nil, fmt.Errorf("invalid field")
 	}
-
-}
-
-func (t *FetchTask) getDailyConversionsValue() (res float64) {
-	panic("unimplemented")
-}
-
-func (t *FetchTask) getAvgCPMValue() (res float64) {
-	panic("unimplemented")
-}
-
-func (t *FetchTask) getAvgCPCValue() (res float64) {
-	panic("unimplemented")
+	return m.Compute(metric.AccountRows(t.Accounts))
 }
 
 func (t *FetchTask) GetFieldAsInt64(field string) (res int64, err error) {
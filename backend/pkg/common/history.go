@@ -0,0 +1,528 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/metric"
+)
+
+// History gives access to the spend stored by the previous fetches,
+// it's satisfied by db.DbService.
+type History interface {
+	GetAccountSpend(clientID string, start, end time.Time) ([]db.DbAccountSpend, error)
+	GetCampaignSpend(clientID string, start, end time.Time) ([]db.DbCampaignSpend, error)
+	// GetAccountSnapshots returns the last snapshot taken at or before `at`
+	// of each account, for the day of `at`.
+	GetAccountSnapshots(clientID string, at time.Time) ([]db.DbAccountSpend, error)
+	GetCampaignSnapshots(clientID string, at time.Time) ([]db.DbCampaignSpend, error)
+	// GetAccountSnapshotsOfDay returns every snapshot taken on the day, from
+	// the oldest.
+	GetAccountSnapshotsOfDay(clientID string, day time.Time) ([]db.DbAccountSpend, error)
+	GetCampaignSnapshotsOfDay(clientID string, day time.Time) ([]db.DbCampaignSpend, error)
+}
+
+// HistoricalEvent is an Event that can also be measured on the days
+// preceding the fetch.
+type HistoricalEvent interface {
+	Event
+	// GetFieldSeries returns the daily values of a numeric field over the
+	// given number of days, ending on the day of the fetch. Days without
+	// data are omitted.
+	GetFieldSeries(field string, days int) ([]SeriesPoint, error)
+	// GetFieldOnDay returns the value of a numeric field over a whole day,
+	// ok is false if there's no data for the entity on that day.
+	GetFieldOnDay(field string, day time.Time) (value float64, ok bool, err error)
+	// GetFieldAt returns the value of a numeric field as it was observed at
+	// the given time, from the intraday snapshots.
+	GetFieldAt(field string, at time.Time) (value float64, ok bool, err error)
+	// FetchedAt returns the time the event data was fetched at.
+	FetchedAt() time.Time
+}
+
+// SeriesPoint is the value of a field on a day.
+type SeriesPoint struct {
+	Day   time.Time
+	Value float64
+}
+
+// taskHistory caches the rows loaded from the History, so that the rules of
+// a client query the database once per fetch.
+type taskHistory struct {
+	mx        sync.Mutex
+	source    History
+	clientID  string
+	from      time.Time
+	loaded    bool
+	accounts  []db.DbAccountSpend
+	campaigns []db.DbCampaignSpend
+	snapshots map[time.Time]*snapshot
+}
+
+type snapshot struct {
+	accounts  []db.DbAccountSpend
+	campaigns []db.DbCampaignSpend
+}
+
+// WithHistory allows the rules evaluated on the task to look at the
+// rows stored for the client before the fetched period.
+func (t *FetchTask) WithHistory(clientID string, h History) *FetchTask {
+	t.history = newTaskHistory(clientID, h)
+	return t
+}
+
+func newTaskHistory(clientID string, h History) *taskHistory {
+	return &taskHistory{
+		source:    h,
+		clientID:  clientID,
+		snapshots: make(map[time.Time]*snapshot),
+	}
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// load makes sure the rows between from and the start of the task are cached.
+func (h *taskHistory) load(from, taskStart time.Time) error {
+	if h.loaded && !from.Before(h.from) {
+		return nil
+	}
+	end := taskStart.AddDate(0, 0, -1)
+	accounts, err := h.source.GetAccountSpend(h.clientID, from, end)
+	if err != nil {
+		return fmt.Errorf("could not load the account history: %w", err)
+	}
+	campaigns, err := h.source.GetCampaignSpend(h.clientID, from, end)
+	if err != nil {
+		return fmt.Errorf("could not load the campaign history: %w", err)
+	}
+	h.accounts = latestAccountRows(accounts)
+	h.campaigns = latestCampaignRows(campaigns)
+	h.from = from
+	h.loaded = true
+	return nil
+}
+
+func (h *taskHistory) snapshot(at time.Time) (*snapshot, error) {
+	if s, ok := h.snapshots[at]; ok {
+		return s, nil
+	}
+	accounts, err := h.source.GetAccountSnapshots(h.clientID, at)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the account snapshots: %w", err)
+	}
+	campaigns, err := h.source.GetCampaignSnapshots(h.clientID, at)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the campaign snapshots: %w", err)
+	}
+	s := &snapshot{accounts: accounts, campaigns: campaigns}
+	h.snapshots[at] = s
+	return s, nil
+}
+
+// latestAccountRows keeps the most recent version of each account day, the
+// spend tables are only deduplicated by clickhouse on merges.
+func latestAccountRows(rows []db.DbAccountSpend) []db.DbAccountSpend {
+	byKey := make(map[string]int)
+	res := make([]db.DbAccountSpend, 0, len(rows))
+	for _, r := range rows {
+		k := r.ProviderID + "|" + r.AccountID + "|" + dateOf(r.DateRef).Format(time.DateOnly)
+		idx, ok := byKey[k]
+		if !ok {
+			byKey[k] = len(res)
+			res = append(res, r)
+			continue
+		}
+		if r.UpdatedAt.After(res[idx].UpdatedAt) {
+			res[idx] = r
+		}
+	}
+	return res
+}
+
+func latestCampaignRows(rows []db.DbCampaignSpend) []db.DbCampaignSpend {
+	byKey := make(map[string]int)
+	res := make([]db.DbCampaignSpend, 0, len(rows))
+	for _, r := range rows {
+		k := r.ProviderID + "|" + r.AccountID + "|" + r.CampaignID + "|" + dateOf(r.DateRef).Format(time.DateOnly)
+		idx, ok := byKey[k]
+		if !ok {
+			byKey[k] = len(res)
+			res = append(res, r)
+			continue
+		}
+		if r.UpdatedAt.After(res[idx].UpdatedAt) {
+			res[idx] = r
+		}
+	}
+	return res
+}
+
+// windowRows returns the rows of the last `days` days ending on the day of
+// the task end: the fetched rows for the days covered by the task, the
+// stored ones for the days before.
+func (t *FetchTask) windowRows(campaigns bool, days int) ([]metric.Row, error) {
+	end := dateOf(t.End)
+	from := end.AddDate(0, 0, -(days - 1))
+	taskStart := dateOf(t.Start)
+
+	res := make([]metric.Row, 0)
+	if campaigns {
+		for _, r := range metric.CampaignRows(t.Campaigns) {
+			if !dateOf(r.Campaign.DateRef).Before(from) {
+				res = append(res, r)
+			}
+		}
+	} else {
+		for _, r := range metric.AccountRows(t.Accounts) {
+			if !dateOf(r.Account.DateRef).Before(from) {
+				res = append(res, r)
+			}
+		}
+	}
+	if !from.Before(taskStart) {
+		return res, nil
+	}
+
+	if t.history == nil {
+		return nil, fmt.Errorf("historical data is not available")
+	}
+	t.history.mx.Lock()
+	defer t.history.mx.Unlock()
+	if err := t.history.load(from, taskStart); err != nil {
+		return nil, err
+	}
+	if campaigns {
+		for _, r := range metric.CampaignRows(t.history.campaigns) {
+			if d := dateOf(r.Campaign.DateRef); !d.Before(from) && d.Before(taskStart) {
+				res = append(res, r)
+			}
+		}
+		return res, nil
+	}
+	for _, r := range metric.AccountRows(t.history.accounts) {
+		if d := dateOf(r.Account.DateRef); !d.Before(from) && d.Before(taskStart) {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+// dayRows returns the rows of a single day, fetched or stored.
+func (t *FetchTask) dayRows(campaigns bool, day time.Time) ([]metric.Row, error) {
+	if day.After(dateOf(t.End)) {
+		return []metric.Row{}, nil
+	}
+	days := int(dateOf(t.End).Sub(day).Hours()/24) + 1
+	rows, err := t.windowRows(campaigns, days)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]metric.Row, 0)
+	for _, r := range rows {
+		var d time.Time
+		if campaigns {
+			d = dateOf(r.Campaign.DateRef)
+		} else {
+			d = dateOf(r.Account.DateRef)
+		}
+		if d.Equal(day) {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+// EventsWithMissing is like Events, but the accounts or campaigns that had
+// rows the previous day and are missing from the fetch are also returned,
+// with their metrics at zero: the providers usually omit the entities that
+// did not deliver. Without history only the fetched entities are returned.
+func (t *FetchTask) EventsWithMissing(entity EntityType) ([]*EntityEvent, error) {
+	if entity != ACCOUNT && entity != CAMPAIGN {
+		return t.Events(entity)
+	}
+	day := dateOf(t.End)
+	previous := day.AddDate(0, 0, -1)
+	if t.history == nil && previous.Before(dateOf(t.Start)) {
+		return t.Events(entity)
+	}
+	rows, err := t.dayRows(entity == CAMPAIGN, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	fetched := make(map[string]bool)
+	for _, acc := range t.Accounts {
+		fetched[acc.ProviderID+"|"+acc.AccountID] = true
+	}
+	for _, camp := range t.Campaigns {
+		fetched[camp.ProviderID+"|"+camp.AccountID+"|"+camp.CampaignID] = true
+	}
+	extended := &FetchTask{
+		Start:     t.Start,
+		End:       t.End,
+		Accounts:  append(make([]db.DbAccountSpend, 0, len(t.Accounts)), t.Accounts...),
+		Campaigns: append(make([]db.DbCampaignSpend, 0, len(t.Campaigns)), t.Campaigns...),
+		Errors:    t.Errors,
+		history:   t.history,
+	}
+	for _, r := range rows {
+		if r.Campaign != nil {
+			k := r.Campaign.ProviderID + "|" + r.Campaign.AccountID + "|" + r.Campaign.CampaignID
+			if fetched[k] {
+				continue
+			}
+			fetched[k] = true
+			camp := *r.Campaign
+			camp.Spend, camp.Impressions, camp.Clicks, camp.Conversions, camp.PurchaseValue = 0, 0, 0, 0, 0
+			camp.DateRef, camp.UpdatedAt = day, t.End
+			extended.Campaigns = append(extended.Campaigns, camp)
+			continue
+		}
+		k := r.Account.ProviderID + "|" + r.Account.AccountID
+		if fetched[k] {
+			continue
+		}
+		fetched[k] = true
+		acc := *r.Account
+		acc.Spend, acc.Impressions, acc.Clicks, acc.Conversions, acc.PurchaseValue = 0, 0, 0, 0, 0
+		acc.NumberOfCampaigns = 0
+		acc.DateRef, acc.UpdatedAt = day, t.End
+		extended.Accounts = append(extended.Accounts, acc)
+	}
+	return extended.Events(entity)
+}
+
+// belongs reports whether the row is part of the entity.
+func (e *EntityEvent) belongs(r metric.Row) bool {
+	if e.EntityType == CLIENT {
+		return true
+	}
+	var k string
+	if r.Campaign != nil {
+		k, _ = entityKey(e.EntityType, r.Campaign.ProviderID, r.Campaign.BusinessID, r.Campaign.AccountID, r.Campaign.CampaignID)
+	} else {
+		k, _ = entityKey(e.EntityType, r.Account.ProviderID, r.Account.BusinessID, r.Account.AccountID, "")
+	}
+	return k == e.key
+}
+
+func numericMetric(field string) (*metric.Metric, error) {
+	m, ok := metric.Lookup(field)
+	if !ok {
+		return nil, fmt.Errorf("invalid field")
+	}
+	if m.Type != metric.TypeNumber {
+		return nil, fmt.Errorf("field %s is not numeric", m.Name)
+	}
+	return m, nil
+}
+
+// computeOwn computes the metric on the rows of the entity, ok is false if
+// none of the rows belongs to the entity.
+func (e *EntityEvent) computeOwn(m *metric.Metric, rows []metric.Row) (float64, bool, error) {
+	own := make([]metric.Row, 0)
+	for _, r := range rows {
+		if e.belongs(r) {
+			own = append(own, r)
+		}
+	}
+	if len(own) == 0 {
+		return 0, false, nil
+	}
+	v, err := m.Compute(own)
+	if err != nil {
+		return 0, false, err
+	}
+	return v.(float64), true, nil
+}
+
+// FetchedAt implements HistoricalEvent.
+func (e *EntityEvent) FetchedAt() time.Time {
+	if e.task == nil {
+		return time.Time{}
+	}
+	return e.task.End
+}
+
+// GetFieldOnDay implements HistoricalEvent.
+func (e *EntityEvent) GetFieldOnDay(field string, day time.Time) (float64, bool, error) {
+	m, err := numericMetric(field)
+	if err != nil {
+		return 0, false, err
+	}
+	if e.task == nil {
+		return 0, false, fmt.Errorf("historical data is not available")
+	}
+	rows, err := e.task.dayRows(e.EntityType == CAMPAIGN, dateOf(day))
+	if err != nil {
+		return 0, false, err
+	}
+	return e.computeOwn(m, rows)
+}
+
+// GetFieldAt implements HistoricalEvent.
+func (e *EntityEvent) GetFieldAt(field string, at time.Time) (float64, bool, error) {
+	m, err := numericMetric(field)
+	if err != nil {
+		return 0, false, err
+	}
+	if e.task == nil || e.task.history == nil {
+		return 0, false, fmt.Errorf("historical data is not available")
+	}
+	e.task.history.mx.Lock()
+	s, err := e.task.history.snapshot(at)
+	e.task.history.mx.Unlock()
+	if err != nil {
+		return 0, false, err
+	}
+	if e.EntityType == CAMPAIGN {
+		return e.computeOwn(m, metric.CampaignRows(s.campaigns))
+	}
+	return e.computeOwn(m, metric.AccountRows(s.accounts))
+}
+
+// GetFieldSeries implements HistoricalEvent.
+func (e *EntityEvent) GetFieldSeries(field string, days int) ([]SeriesPoint, error) {
+	m, err := numericMetric(field)
+	if err != nil {
+		return nil, err
+	}
+	if days < 1 {
+		return nil, fmt.Errorf("invalid window of %d days", days)
+	}
+	if e.task == nil {
+		return nil, fmt.Errorf("historical data is not available")
+	}
+	rows, err := e.task.windowRows(e.EntityType == CAMPAIGN, days)
+	if err != nil {
+		return nil, err
+	}
+
+	byDay := make(map[time.Time][]metric.Row)
+	for _, r := range rows {
+		if !e.belongs(r) {
+			continue
+		}
+		var d time.Time
+		if r.Campaign != nil {
+			d = dateOf(r.Campaign.DateRef)
+		} else {
+			d = dateOf(r.Account.DateRef)
+		}
+		byDay[d] = append(byDay[d], r)
+	}
+	dates := make([]time.Time, 0, len(byDay))
+	for d := range byDay {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+
+	res := make([]SeriesPoint, 0, len(dates))
+	for _, d := range dates {
+		v, err := m.Compute(byDay[d])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, SeriesPoint{Day: d, Value: v.(float64)})
+	}
+	return res, nil
+}
+
+// NewDayReplayTask returns a task holding the rows stored for the client on
+// the given day, as they were after the last fetch of the day. The task is
+// fetched at the time of that last fetch and has access to the history.
+func NewDayReplayTask(h History, clientID string, day time.Time) (*FetchTask, error) {
+	day = dateOf(day)
+	accounts, err := h.GetAccountSpend(clientID, day, day)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the account spend: %w", err)
+	}
+	campaigns, err := h.GetCampaignSpend(clientID, day, day)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the campaign spend: %w", err)
+	}
+	task := &FetchTask{
+		Start:     day,
+		Accounts:  latestAccountRows(accounts),
+		Campaigns: latestCampaignRows(campaigns),
+	}
+	task.End = task.lastUpdate()
+	if !dateOf(task.End).Equal(day) {
+		// rows updated on a later day, or no rows at all
+		task.End = day.Add(24*time.Hour - time.Second)
+	}
+	return task.WithHistory(clientID, h), nil
+}
+
+// NewSnapshotReplayTasks returns the tasks of the day as if it was fetched
+// every step: each task holds the last snapshot taken before the end of its
+// step of the entities of the client, and is fetched when the most recent of
+// them was taken. The steps without a newer snapshot have no task. The
+// snapshots of the day are loaded at once, and the tasks share their history.
+func NewSnapshotReplayTasks(h History, clientID string, day time.Time, step time.Duration) ([]*FetchTask, error) {
+	day = dateOf(day)
+	accounts, err := h.GetAccountSnapshotsOfDay(clientID, day)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the account snapshots: %w", err)
+	}
+	campaigns, err := h.GetCampaignSnapshotsOfDay(clientID, day)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the campaign snapshots: %w", err)
+	}
+
+	// the tasks of the day start on the same day, the loaded history is
+	// valid for every one of them
+	history := newTaskHistory(clientID, h)
+	res := make([]*FetchTask, 0)
+	var last time.Time
+	for at := day.Add(step); !at.After(day.AddDate(0, 0, 1)); at = at.Add(step) {
+		task := &FetchTask{Start: day, history: history}
+		for _, r := range accounts {
+			if r.UpdatedAt.Before(at) {
+				task.Accounts = append(task.Accounts, r)
+			}
+		}
+		for _, r := range campaigns {
+			if r.UpdatedAt.Before(at) {
+				task.Campaigns = append(task.Campaigns, r)
+			}
+		}
+		task.Accounts = latestAccountRows(task.Accounts)
+		task.Campaigns = latestCampaignRows(task.Campaigns)
+		task.End = task.lastUpdate()
+		if task.Empty() || task.End.Equal(last) {
+			continue
+		}
+		last = task.End
+		res = append(res, task)
+	}
+	return res, nil
+}
+
+// lastUpdate returns the most recent update time of the rows of the task.
+func (t *FetchTask) lastUpdate() time.Time {
+	var last time.Time
+	for _, r := range t.Accounts {
+		if r.UpdatedAt.After(last) {
+			last = r.UpdatedAt
+		}
+	}
+	for _, r := range t.Campaigns {
+		if r.UpdatedAt.After(last) {
+			last = r.UpdatedAt
+		}
+	}
+	return last
+}
+
+// Empty reports whether the task has no rows.
+func (t *FetchTask) Empty() bool {
+	return len(t.Accounts) == 0 && len(t.Campaigns) == 0
+}
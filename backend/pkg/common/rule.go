@@ -1,5 +1,10 @@
 package common
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Event interface {
 	GetFieldValue(field string) (interface{}, error)
 	GetFieldAsInt64(field string) (int64, error)
@@ -12,4 +17,73 @@ type RuleResult struct {
 	RuleID    string
 	Result    bool
 	Threshold any
+	// the entity the rule matched on, the whole client for CLIENT rules
+	EntityType   EntityType
+	EntityID     string
+	EntityName   string
+	CurrentSpend float64
+	// the metric the rule compared and its value, Baseline is the value of
+	// the baseline period for period over period rules, nil otherwise
+	Metric   string
+	Value    any
+	Baseline any
+	// Trace explains why the rule matched, or didn't
+	Trace *Trace
+	// Severity is the importance of the rule, its notifications are routed
+	// by it
+	Severity Severity
+}
+
+// Severity is the importance of a rule, ordered from the least important.
+type Severity uint8
+
+const (
+	INFO Severity = iota
+	WARNING
+	CRITICAL
+)
+
+func (s Severity) String() string {
+	switch s {
+	case INFO:
+		return "INFO"
+	case WARNING:
+		return "WARNING"
+	case CRITICAL:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// SeverityFromString returns the severity, WARNING when empty.
+func SeverityFromString(s string) (Severity, error) {
+	switch strings.ToUpper(s) {
+	case "INFO":
+		return INFO, nil
+	case "WARNING", "":
+		return WARNING, nil
+	case "CRITICAL":
+		return CRITICAL, nil
+	}
+	return WARNING, fmt.Errorf("invalid severity %q, expected one of info, warning, critical", s)
+}
+
+// Trace is the evaluation of a condition on an entity, the children mirror
+// the child conditions. Rules that aren't made of conditions are traced as
+// a single comparison.
+type Trace struct {
+	// the condition as written in rule expressions
+	Expression string `json:"expression"`
+	Operator   string `json:"operator,omitempty"`
+	// Field is the compared metric, Value its resolved value and Target the
+	// value it's compared with, set on comparisons only
+	Field    string `json:"field,omitempty"`
+	Value    any    `json:"value,omitempty"`
+	Target   any    `json:"target,omitempty"`
+	Baseline any    `json:"baseline,omitempty"`
+	Result   bool   `json:"result"`
+	// Evaluated is false for the conditions skipped by a short circuit
+	Evaluated bool     `json:"evaluated"`
+	Error     string   `json:"error,omitempty"`
+	Children  []*Trace `json:"children,omitempty"`
 }
@@ -17,6 +17,8 @@ const (
 	MailTemplate             = "mail.template"
 	FacebookAppID            = "facebook.appid"
 	FacebookAppSecret        = "facebook.appsecret"
+	GoogleDeveloperToken     = "google.developertoken"
+	MicrosoftDeveloperToken  = "microsoft.developertoken"
 	TelegramBotToken         = "telegram.bot.token"
 	SimpleWorkerTickInterval = "simpleworker.tick.interval"
 )
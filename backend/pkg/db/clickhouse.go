@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -12,11 +13,16 @@ import (
 )
 
 const (
-	clientsTableName          = "clients"
-	providersTableName        = "providers"
-	accountsSpendingTableName = "account_spends"
-	campaignSpendingTableName = "campaigns_spend"
-	rulesTableName            = "client_rules"
+	clientsTableName             = "clients"
+	providersTableName           = "providers"
+	accountsSpendingTableName    = "account_spends"
+	campaignSpendingTableName    = "campaigns_spend"
+	accountSnapshotsTableName    = "account_spend_snapshots"
+	campaignSnapshotsTableName   = "campaign_spend_snapshots"
+	rulesTableName               = "client_rules"
+	alertsTableName              = "rule_alerts"
+	alertStatesTableName         = "alert_states"
+	queuedNotificationsTableName = "queued_notifications"
 )
 
 var (
@@ -25,6 +31,9 @@ var (
 
 	accSpendingTable = sqlbuilder.NewStruct(new(DbAccountSpend)).For(sqlbuilder.ClickHouse)
 	rulesTable       = sqlbuilder.NewStruct(new(DbRule)).For(sqlbuilder.ClickHouse)
+	alertsTable      = sqlbuilder.NewStruct(new(DbAlert)).For(sqlbuilder.ClickHouse)
+	alertStatesTable = sqlbuilder.NewStruct(new(DbAlertState)).For(sqlbuilder.ClickHouse)
+	queuedTable      = sqlbuilder.NewStruct(new(DbQueuedNotification)).For(sqlbuilder.ClickHouse)
 )
 
 func initConn() (clickhouse.Conn, error) {
@@ -128,6 +137,18 @@ func (c *clkService) GetClientsByQuery(builder *sqlbuilder.SelectBuilder) ([]DbC
 	return res, nil
 }
 
+// performanceColumns sums the delivery counters of a grouped query and derives
+// the ratios from the sums, so that they're weighted on the whole period.
+var performanceColumns = strings.Join([]string{
+	"sum(impressions) as impressions", "sum(clicks) as clicks",
+	"sum(conversions) as conversions", "sum(purchase_value) as purchase_value",
+	"if(sum(clicks) = 0, 0, sum(spend) / sum(clicks)) as cpc",
+	"if(sum(impressions) = 0, 0, sum(spend) / sum(impressions) * 1000) as cpm",
+	"if(sum(impressions) = 0, 0, sum(clicks) / sum(impressions) * 100) as ctr",
+	"if(sum(conversions) = 0, 0, sum(spend) / sum(conversions)) as cpa",
+	"if(sum(spend) = 0, 0, sum(purchase_value) / sum(spend)) as roas",
+}, ", ")
+
 // GetCampaignSpendGrouped implements DbService.
 func (c *clkService) GetCampaignSpendGrouped(clientID string, start time.Time, end time.Time) ([]DbCampaignSpendGrouped, error) {
 	sb := sqlbuilder.NewSelectBuilder().Select(
@@ -135,6 +156,7 @@ func (c *clkService) GetCampaignSpendGrouped(clientID string, start time.Time, e
 		"business_id", "anyLast(business_name) as business_name",
 		"campaign_id", "anyLast(campaign_name) as campaign_name",
 		"provider_id", "provider_type", "anyLast(status) as status", "sum(spend) as spend",
+		performanceColumns,
 		"min(date_ref) as date_start", "max(date_ref) as date_end", "max(updated_at) as updated_at",
 	).From(campaignSpendingTableName)
 	sb.Where(
@@ -172,6 +194,7 @@ func (c *clkService) GetAccountSpendGrouped(clientID string, start time.Time, en
 		"anyLast(account_image) as account_image",
 		"business_id", "anyLast(business_name) as business_name",
 		"provider_id", "provider_type", "anyLast(status) as status", "sum(spend) as spend",
+		performanceColumns,
 		"anyLast(number_of_campaigns) as number_of_campaigns",
 		"min(date_ref) as date_start", "max(date_ref) as date_end", "max(updated_at) as updated_at",
 	).From(accountsSpendingTableName)
@@ -261,6 +284,144 @@ func (c *clkService) InsertAccountSpend(data []DbAccountSpend) error {
 	return batch.Send()
 }
 
+// InsertAccountSnapshots implements DbService.
+func (c *clkService) InsertAccountSnapshots(data []DbAccountSpend) error {
+	batch, err := c.conn.PrepareBatch(
+		c.ctx, fmt.Sprintf("INSERT INTO %s ", accountSnapshotsTableName),
+		driver.WithCloseOnFlush(), driver.WithReleaseConnection(),
+	)
+	if err != nil {
+		return err
+	}
+	for _, e := range data {
+		if err := batch.AppendStruct(&e); err != nil {
+			return err
+		}
+	}
+	return batch.Send()
+}
+
+// GetAccountSnapshots implements DbService.
+func (c *clkService) GetAccountSnapshots(clientID string, at time.Time) ([]DbAccountSpend, error) {
+	sb := sqlbuilder.NewSelectBuilder().Select("*").From(accountSnapshotsTableName)
+	sb.Where(
+		sb.EQ("client_id", clientID),
+		sb.EQ("date_ref", time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)),
+		sb.LTE("updated_at", at),
+	)
+	sb.OrderBy("updated_at").Desc()
+	sb.SQL("LIMIT 1 BY provider_id, account_id")
+	q, args := sb.Build()
+	rows, err := c.conn.Query(c.ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]DbAccountSpend, 0)
+	for rows.Next() {
+		var accSpend DbAccountSpend
+		if err := rows.ScanStruct(&accSpend); err != nil {
+			return nil, err
+		}
+		res = append(res, accSpend)
+	}
+	return res, nil
+}
+
+// GetAccountSnapshotsOfDay implements DbService.
+func (c *clkService) GetAccountSnapshotsOfDay(clientID string, day time.Time) ([]DbAccountSpend, error) {
+	sb := sqlbuilder.NewSelectBuilder().Select("*").From(accountSnapshotsTableName)
+	sb.Where(
+		sb.EQ("client_id", clientID),
+		sb.EQ("date_ref", time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)),
+	)
+	sb.OrderBy("updated_at")
+	q, args := sb.Build()
+	rows, err := c.conn.Query(c.ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]DbAccountSpend, 0)
+	for rows.Next() {
+		var accSpend DbAccountSpend
+		if err := rows.ScanStruct(&accSpend); err != nil {
+			return nil, err
+		}
+		res = append(res, accSpend)
+	}
+	return res, nil
+}
+
+// InsertCampaignSnapshots implements DbService.
+func (c *clkService) InsertCampaignSnapshots(data []DbCampaignSpend) error {
+	batch, err := c.conn.PrepareBatch(
+		c.ctx, fmt.Sprintf("INSERT INTO %s ", campaignSnapshotsTableName),
+		driver.WithCloseOnFlush(), driver.WithReleaseConnection(),
+	)
+	if err != nil {
+		return err
+	}
+	for _, e := range data {
+		if err := batch.AppendStruct(&e); err != nil {
+			return err
+		}
+	}
+	return batch.Send()
+}
+
+// GetCampaignSnapshots implements DbService.
+func (c *clkService) GetCampaignSnapshots(clientID string, at time.Time) ([]DbCampaignSpend, error) {
+	sb := sqlbuilder.NewSelectBuilder().Select("*").From(campaignSnapshotsTableName)
+	sb.Where(
+		sb.EQ("client_id", clientID),
+		sb.EQ("date_ref", time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)),
+		sb.LTE("updated_at", at),
+	)
+	sb.OrderBy("updated_at").Desc()
+	sb.SQL("LIMIT 1 BY provider_id, account_id, campaign_id")
+	q, args := sb.Build()
+	rows, err := c.conn.Query(c.ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]DbCampaignSpend, 0)
+	for rows.Next() {
+		var campSpend DbCampaignSpend
+		if err := rows.ScanStruct(&campSpend); err != nil {
+			return nil, err
+		}
+		res = append(res, campSpend)
+	}
+	return res, nil
+}
+
+// GetCampaignSnapshotsOfDay implements DbService.
+func (c *clkService) GetCampaignSnapshotsOfDay(clientID string, day time.Time) ([]DbCampaignSpend, error) {
+	sb := sqlbuilder.NewSelectBuilder().Select("*").From(campaignSnapshotsTableName)
+	sb.Where(
+		sb.EQ("client_id", clientID),
+		sb.EQ("date_ref", time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)),
+	)
+	sb.OrderBy("updated_at")
+	q, args := sb.Build()
+	rows, err := c.conn.Query(c.ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]DbCampaignSpend, 0)
+	for rows.Next() {
+		var campSpend DbCampaignSpend
+		if err := rows.ScanStruct(&campSpend); err != nil {
+			return nil, err
+		}
+		res = append(res, campSpend)
+	}
+	return res, nil
+}
+
 // GetAccountSpend implements DbService.
 func (c *clkService) GetAccountSpend(clientID string, start time.Time, end time.Time) ([]DbAccountSpend, error) {
 	sb := sqlbuilder.NewSelectBuilder().Select("*").From(accountsSpendingTableName)
@@ -355,6 +516,18 @@ func (c *clkService) UpdateClient(clientReq *ClientUpdate) (*DbClient, error) {
 	if clientReq.SlackWebhookURL != nil && *clientReq.SlackWebhookURL != "" {
 		client.SlackWebhookURL = *clientReq.SlackWebhookURL
 	}
+	if clientReq.Timezone != nil {
+		client.Timezone = *clientReq.Timezone
+	}
+	if clientReq.QuietHours != nil {
+		client.QuietHours = *clientReq.QuietHours
+	}
+	if clientReq.Routing != nil {
+		client.Routing = *clientReq.Routing
+	}
+	if clientReq.GroupAlerts != nil {
+		client.GroupAlerts = *clientReq.GroupAlerts
+	}
 
 	client.UpdatedAt = time.Now().UTC()
 
@@ -413,12 +586,22 @@ func (c *clkService) InsertClient(client *DbClient) error {
 // GetRuleByID implements DbService.
 func (c *clkService) GetRuleByID(ruleID string) (*DbRule, error) {
 	var r DbRule
-	if err := c.conn.QueryRow(c.ctx, "select * from ? where client_id = ? order by updated_at DESC limit 1", rulesTableName, ruleID).ScanStruct(&r); err != nil {
+	q, args := ruleByIDQuery(ruleID)
+	if err := c.conn.QueryRow(c.ctx, q, args...).ScanStruct(&r); err != nil {
 		return nil, err
 	}
 	return &r, nil
 }
 
+// ruleByIDQuery selects the last version of the rule.
+func ruleByIDQuery(ruleID string) (string, []interface{}) {
+	sb := rulesTable.SelectFrom(rulesTableName)
+	sb.Where(sb.EQ("rule_id", ruleID))
+	sb.OrderBy("updated_at").Desc()
+	sb.Limit(1)
+	return sb.BuildWithFlavor(sqlbuilder.ClickHouse)
+}
+
 // GetRulesByClientID implements DbService.
 func (c *clkService) GetRulesByClientID(clientID string) ([]DbRule, error) {
 
@@ -444,7 +627,7 @@ func (c *clkService) GetRulesByClientID(clientID string) ([]DbRule, error) {
 
 // InsertRule implements DbService.
 func (c *clkService) InsertRule(rule *DbRule) error {
-	sb := rulesTable.InsertInto(clientsTableName, rule)
+	sb := rulesTable.InsertInto(rulesTableName, rule)
 	query, args := sb.BuildWithFlavor(sqlbuilder.ClickHouse)
 
 	if err := c.conn.AsyncInsert(c.ctx, query, true, args...); err != nil {
@@ -453,3 +636,128 @@ func (c *clkService) InsertRule(rule *DbRule) error {
 
 	return nil
 }
+
+// InsertAlert implements DbService.
+func (c *clkService) InsertAlert(alert *DbAlert) error {
+	sb := alertsTable.InsertInto(alertsTableName, alert)
+	query, args := sb.BuildWithFlavor(sqlbuilder.ClickHouse)
+	return c.conn.AsyncInsert(c.ctx, query, true, args...)
+}
+
+// GetAlertsByClientID implements DbService.
+func (c *clkService) GetAlertsByClientID(clientID string, start, end time.Time) ([]DbAlert, error) {
+	sb := alertsTable.SelectFrom(alertsTableName)
+	sb.Where(
+		sb.EQ("client_id", clientID),
+		sb.GTE("toDate(fired_at)", start),
+		sb.LTE("toDate(fired_at)", end),
+	)
+	sb.OrderBy("fired_at").Desc()
+	q, args := sb.BuildWithFlavor(sqlbuilder.ClickHouse)
+	rows, err := c.conn.Query(c.ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]DbAlert, 0)
+	for rows.Next() {
+		var alert DbAlert
+		if err := rows.ScanStruct(&alert); err != nil {
+			return nil, err
+		}
+		res = append(res, alert)
+	}
+	return res, nil
+}
+
+// GetAlertByID implements DbService.
+func (c *clkService) GetAlertByID(alertID string) (*DbAlert, error) {
+	sb := alertsTable.SelectFrom(alertsTableName)
+	sb.Where(sb.EQ("alert_id", alertID))
+	sb.Limit(1)
+	q, args := sb.BuildWithFlavor(sqlbuilder.ClickHouse)
+	var alert DbAlert
+	if err := c.conn.QueryRow(c.ctx, q, args...).ScanStruct(&alert); err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// GetAlertStates implements DbService.
+func (c *clkService) GetAlertStates(clientID string) ([]DbAlertState, error) {
+	sb := alertStatesTable.SelectFrom(alertStatesTableName + " FINAL")
+	sb.Where(sb.EQ("client_id", clientID))
+	q, args := sb.BuildWithFlavor(sqlbuilder.ClickHouse)
+	rows, err := c.conn.Query(c.ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]DbAlertState, 0)
+	for rows.Next() {
+		var state DbAlertState
+		if err := rows.ScanStruct(&state); err != nil {
+			return nil, err
+		}
+		res = append(res, state)
+	}
+	return res, nil
+}
+
+// UpsertAlertStates implements DbService.
+// The states replace the previous ones on merge, GetAlertStates reads them FINAL.
+func (c *clkService) UpsertAlertStates(states []DbAlertState) error {
+	batch, err := c.conn.PrepareBatch(
+		c.ctx, fmt.Sprintf("INSERT INTO %s ", alertStatesTableName),
+		driver.WithCloseOnFlush(), driver.WithReleaseConnection(),
+	)
+	if err != nil {
+		return err
+	}
+	for _, s := range states {
+		if err := batch.AppendStruct(&s); err != nil {
+			return err
+		}
+	}
+	return batch.Send()
+}
+
+// GetQueuedNotifications implements DbService.
+// Only the notifications not delivered yet are returned, oldest first.
+func (c *clkService) GetQueuedNotifications(clientID, queue string) ([]DbQueuedNotification, error) {
+	sb := queuedTable.SelectFrom(queuedNotificationsTableName + " FINAL")
+	sb.Where(sb.EQ("client_id", clientID), sb.EQ("queue", queue), sb.EQ("delivered", false))
+	sb.OrderBy("queued_at")
+	q, args := sb.BuildWithFlavor(sqlbuilder.ClickHouse)
+	rows, err := c.conn.Query(c.ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]DbQueuedNotification, 0)
+	for rows.Next() {
+		var n DbQueuedNotification
+		if err := rows.ScanStruct(&n); err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+// UpsertQueuedNotifications implements DbService.
+func (c *clkService) UpsertQueuedNotifications(notifications []DbQueuedNotification) error {
+	batch, err := c.conn.PrepareBatch(
+		c.ctx, fmt.Sprintf("INSERT INTO %s ", queuedNotificationsTableName),
+		driver.WithCloseOnFlush(), driver.WithReleaseConnection(),
+	)
+	if err != nil {
+		return err
+	}
+	for _, n := range notifications {
+		if err := batch.AppendStruct(&n); err != nil {
+			return err
+		}
+	}
+	return batch.Send()
+}
@@ -10,6 +10,10 @@ CREATE TABLE adszero.clients (
     notification_email String,
     telegram_chat_id String,
     slack_webhook_url String,
+    timezone String default '', /* default timezone of the schedules */
+    quiet_hours String default '', /* JSON schedule during which the notifications are queued */
+    routing String default '', /* JSON channels of each severity, as {"critical": ["telegram", "slack"], "info": ["digest"]} */
+    group_alerts Bool default false, /* combine the alerts of a fetch in one notification per channel */
     inserted_at DateTime64(9) default now64(9),
     updated_at DateTime64(9) default now64(9),
     deleted Bool default false
@@ -21,7 +25,7 @@ ORDER BY (client_id);
 
 CREATE TABLE adszero.providers (
     provider_id FixedString(26) default generateULID(),
-    provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4) default 'INVALID',
+    provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4,'MICROSOFT'=5) default 'INVALID',
     client_id String NOT NULL,
     inserted_at DateTime64(9) default now64(9),
     api_client_id String,
@@ -40,10 +44,15 @@ CREATE TABLE adszero.account_spends (
     business_id String NOT NULL,
     business_name String NOT NULL,
     provider_id FixedString(26) NOT NULL,
-    provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4) default 'INVALID',
+    provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4,'MICROSOFT'=5) default 'INVALID',
     status Enum8('UNKNOWN'=0,'ACTIVE'=1,'INACTIVE'=2) default 'UNKNOWN',
     spend Float64,
+    impressions UInt64 default 0,
+    clicks UInt64 default 0,
+    conversions Float64 default 0,
+    purchase_value Float64 default 0,
     number_of_campaigns UInt16,
+    timezone String default 'UTC', /* IANA timezone of the ad account */
     date_ref Date32 default now(),
     updated_at DateTime64(9) default now64(9)
 )
@@ -60,9 +69,14 @@ CREATE TABLE adszero.campaigns_spend (
     campaign_id String NOT NULL,
     campaign_name String NOT NULL,
     provider_id FixedString(26) NOT NULL,
-    provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4) default 'INVALID',
+    provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4,'MICROSOFT'=5) default 'INVALID',
     status Enum8('UNKNOWN'=0,'ACTIVE'=1,'INACTIVE'=2) default 'UNKNOWN',
     spend Float64,
+    impressions UInt64 default 0,
+    clicks UInt64 default 0,
+    conversions Float64 default 0,
+    purchase_value Float64 default 0,
+    timezone String default 'UTC', /* IANA timezone of the ad account */
     date_ref Date32 default now(),
     updated_at DateTime64(9) default now64(9)
 )
@@ -72,6 +86,58 @@ partition by toMonth(date_ref);
 
 
 
+-- every fetch of the current day is kept, so that rules can compare the
+-- spend with the one observed at the same hour of the previous days
+CREATE TABLE adszero.account_spend_snapshots (
+    client_id String NOT NULL,
+    account_id String NOT NULL,
+    account_name String NOT NULL,
+    account_image String,
+    business_id String NOT NULL,
+    business_name String NOT NULL,
+    provider_id FixedString(26) NOT NULL,
+    provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4,'MICROSOFT'=5) default 'INVALID',
+    status Enum8('UNKNOWN'=0,'ACTIVE'=1,'INACTIVE'=2) default 'UNKNOWN',
+    spend Float64,
+    impressions UInt64 default 0,
+    clicks UInt64 default 0,
+    conversions Float64 default 0,
+    purchase_value Float64 default 0,
+    number_of_campaigns UInt16,
+    timezone String default 'UTC', /* IANA timezone of the ad account */
+    date_ref Date32 default now(),
+    updated_at DateTime64(9) default now64(9)
+)
+ENGINE=MergeTree
+ORDER BY (client_id,date_ref,updated_at,account_id)
+partition by toMonth(date_ref)
+TTL toDate(date_ref) + INTERVAL 35 DAY;
+
+CREATE TABLE adszero.campaign_spend_snapshots (
+    client_id String NOT NULL,
+    account_id String NOT NULL,
+    account_name String NOT NULL,
+    business_id String NOT NULL,
+    business_name String NOT NULL,
+    campaign_id String NOT NULL,
+    campaign_name String NOT NULL,
+    provider_id FixedString(26) NOT NULL,
+    provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4,'MICROSOFT'=5) default 'INVALID',
+    status Enum8('UNKNOWN'=0,'ACTIVE'=1,'INACTIVE'=2) default 'UNKNOWN',
+    spend Float64,
+    impressions UInt64 default 0,
+    clicks UInt64 default 0,
+    conversions Float64 default 0,
+    purchase_value Float64 default 0,
+    timezone String default 'UTC', /* IANA timezone of the ad account */
+    date_ref Date32 default now(),
+    updated_at DateTime64(9) default now64(9)
+)
+ENGINE=MergeTree
+ORDER BY (client_id,date_ref,updated_at,campaign_id)
+partition by toMonth(date_ref)
+TTL toDate(date_ref) + INTERVAL 35 DAY;
+
 CREATE TABLE adszero.fetch_history (
     request_id FixedString(26) NOT NULL,
     client_id String NOT NULL,
@@ -96,9 +162,68 @@ CREATE TABLE adszero.client_rules (
     column String NOT NULL,
     operator String NOT NULL,
     value Float64 NOT NULL,
-    notification_way Enum8('EMAIL'=0,'TELEGRAM'=1,'SLACK'=2) default 'EMAIL',
+    conditions String default '', /* JSON condition tree, takes precedence over column/operator/value */
+    expression String default '', /* textual rule expression, takes precedence over conditions */
+    scope Enum8('CLIENT'=0,'PROVIDER'=1,'BUSINESS'=2,'ACCOUNT'=3,'CAMPAIGN'=4) default 'CLIENT',
+    rule_type Enum8('THRESHOLD'=0,'ANOMALY'=1,'PACING'=2,'STALL'=3) default 'THRESHOLD',
+    params String default '', /* JSON parameters of the non threshold rule types */
+    notification_way Enum8('EMAIL'=0,'TELEGRAM'=1,'SLACK'=2,'DEFAULT'=3) default 'DEFAULT', /* DEFAULT routes by severity */
+    cooldown_minutes UInt32 default 0, /* a new firing is not notified during the cooldown after a notification */
+    renotify_minutes UInt32 default 0, /* re-send interval of a firing alert, 0 never */
+    notify_resolved Bool default false,
+    sustain_evaluations UInt32 default 0, /* consecutive matching evaluations before firing */
+    sustain_minutes UInt32 default 0, /* minimum matching time before firing */
+    schedule String default '', /* JSON schedule the rule is evaluated in, always when empty */
+    severity Enum8('INFO'=0,'WARNING'=1,'CRITICAL'=2) default 'WARNING',
+    destinations String default '', /* JSON addresses of each channel, as {"email": ["a@example.com"], "webhook": ["https://example.com/hook"]} */
     inserted_at DateTime64(9) default now64(9),
     updated_at DateTime64(9) default now64(9)
 )
 ENGINE=ReplacingMergeTree(updated_at)
 ORDER BY (rule_id,client_id);
+CREATE TABLE adszero.rule_alerts (
+    alert_id FixedString(26) NOT NULL,
+    client_id String NOT NULL,
+    rule_id String NOT NULL,
+    rule_name String NOT NULL,
+    entity_type Enum8('PROVIDER'=0,'BUSINESS'=1,'ACCOUNT'=2,'CAMPAIGN'=3,'ADSET'=4,'AD'=5,'CLIENT'=6) default 'CLIENT',
+    entity_id String,
+    entity_name String,
+    threshold String,
+    metric String default '',
+    value String default '',
+    baseline String default '',
+    current_spend Float64,
+    trace String default '', /* JSON trace of the rule evaluation */
+    fired_at DateTime64(9) default now64(9)
+)
+ENGINE=MergeTree
+ORDER BY (client_id,fired_at,alert_id)
+partition by toYYYYMM(fired_at);
+CREATE TABLE adszero.alert_states (
+    client_id String NOT NULL,
+    rule_id String NOT NULL,
+    entity_type Enum8('PROVIDER'=0,'BUSINESS'=1,'ACCOUNT'=2,'CAMPAIGN'=3,'ADSET'=4,'AD'=5,'CLIENT'=6) default 'CLIENT',
+    entity_id String,
+    entity_name String,
+    status Enum8('FIRING'=1,'RESOLVED'=2,'PENDING'=3),
+    fired_at DateTime64(9),
+    notified_at DateTime64(9),
+    resolved_at DateTime64(9),
+    matches UInt32 default 0,
+    matched_since DateTime64(9),
+    updated_at DateTime64(9) default now64(9)
+)
+ENGINE=ReplacingMergeTree(updated_at)
+ORDER BY (client_id,rule_id,entity_type,entity_id);
+CREATE TABLE adszero.queued_notifications (
+    notification_id FixedString(26) NOT NULL,
+    client_id String NOT NULL,
+    queue Enum8('QUIET'=0,'DIGEST'=1) default 'QUIET',
+    notification String NOT NULL, /* JSON notification */
+    queued_at DateTime64(9),
+    delivered Bool default false,
+    updated_at DateTime64(9) default now64(9)
+)
+ENGINE=ReplacingMergeTree(updated_at)
+ORDER BY (client_id,queue,notification_id);
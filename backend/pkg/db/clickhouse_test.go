@@ -0,0 +1,54 @@
+package db
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/huandu/go-sqlbuilder"
+)
+
+func TestRuleByIDQuery(t *testing.T) {
+	q, args := ruleByIDQuery("r1")
+	expected := "SELECT client_rules.rule_id, client_rules.client_id, client_rules.rule_name, client_rules.column, " +
+		"client_rules.operator, client_rules.value, client_rules.conditions, client_rules.expression, client_rules.scope, " +
+		"client_rules.rule_type, client_rules.params, client_rules.notification_way, client_rules.inserted_at, " +
+		"client_rules.updated_at, client_rules.cooldown_minutes, client_rules.renotify_minutes, client_rules.notify_resolved, " +
+		"client_rules.sustain_evaluations, client_rules.sustain_minutes, client_rules.schedule, client_rules.severity, " +
+		"client_rules.destinations FROM client_rules WHERE rule_id = ? ORDER BY updated_at DESC LIMIT 1"
+	if q != expected {
+		t.Fatalf("expected %s, got %s", expected, q)
+	}
+	// only the rule id is bound, the table name is part of the query
+	if len(args) != 1 || args[0] != "r1" {
+		t.Fatalf("unexpected arguments %v", args)
+	}
+}
+
+// assertColumns checks that the columns the builder of the table writes and
+// selects are the ones of the ClickHouse table, not the Go field names.
+func assertColumns(t *testing.T, table *sqlbuilder.Struct, value any) {
+	t.Helper()
+	typ := reflect.TypeOf(value)
+	expected := make([]string, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		if tag := typ.Field(i).Tag.Get("ch"); tag != "" {
+			expected = append(expected, tag)
+		}
+	}
+	if columns := table.Columns(); !slices.Equal(columns, expected) {
+		t.Fatalf("expected the columns %v, got %v", expected, columns)
+	}
+}
+
+func TestAlertColumns(t *testing.T) {
+	assertColumns(t, alertsTable, DbAlert{})
+}
+
+func TestAlertStateColumns(t *testing.T) {
+	assertColumns(t, alertStatesTable, DbAlertState{})
+}
+
+func TestQueuedNotificationColumns(t *testing.T) {
+	assertColumns(t, queuedTable, DbQueuedNotification{})
+}
@@ -93,6 +93,7 @@ const (
 	Google
 	TikTok
 	Taboola
+	Microsoft
 	Invalid
 )
 
@@ -108,6 +109,8 @@ func (s ProviderEnum) String() string {
 		return "TIKTOK"
 	case Taboola:
 		return "TABOOLA"
+	case Microsoft:
+		return "MICROSOFT"
 	default:
 		panic("unreachable: ProviderEnum.ToString()") //TODO: should not be reachable
 	}
@@ -125,6 +128,8 @@ func ProviderFromString(val string) ProviderEnum {
 		return TikTok
 	case "TABOOLA":
 		return Taboola
+	case "MICROSOFT":
+		return Microsoft
 	default:
 		return Invalid //TODO: keep an eye on this
 	}
@@ -157,6 +162,10 @@ type DbClient struct {
 	NotificationEmail string    `ch:"notification_email" json:"notification_email" db:"notification_email"`
 	TelegramChatID    string    `ch:"telegram_chat_id" json:"telegram_chat_id" db:"telegram_chat_id"`
 	SlackWebhookURL   string    `ch:"slack_webhook_url" json:"slack_webhook_url" db:"slack_webhook_url"`
+	Timezone          string    `ch:"timezone" json:"timezone" db:"timezone"`             // default timezone of the schedules
+	QuietHours        string    `ch:"quiet_hours" json:"quiet_hours" db:"quiet_hours"`    // JSON schedule the notifications are queued in
+	Routing           string    `ch:"routing" json:"routing" db:"routing"`                // JSON channels of each severity
+	GroupAlerts       bool      `ch:"group_alerts" json:"group_alerts" db:"group_alerts"` // one notification per channel per fetch
 	InsertedAt        time.Time `ch:"inserted_at" json:"inserted_at" db:"inserted_at"`
 	UpdatedAt         time.Time `ch:"updated_at" json:"updated_at" db:"updated_at"`
 	Deleted           bool      `ch:"deleted" json:"-"`
@@ -190,6 +199,10 @@ type ClientUpdate struct {
 	NotificationEmail        *string `json:"notification_email"`
 	TelegramChatID           *string `json:"telegram_chat_id"`
 	SlackWebhookURL          *string `json:"slack_webhook_url"`
+	Timezone                 *string `json:"timezone"`
+	QuietHours               *string `json:"quiet_hours"`
+	Routing                  *string `json:"routing"`
+	GroupAlerts              *bool   `json:"group_alerts"`
 }
 
 func IsValidEmail(email string) bool {
@@ -208,8 +221,10 @@ type ProviderCreate struct {
 }
 
 func (p *ProviderCreate) IsValid() bool {
+	// taboola authenticates with the client credentials only
 	return p.ProviderType != "" && p.ClientID != "" &&
-		p.APIAccessToken != "" && p.APIClientID != "" && p.APIClientSecret != ""
+		(p.APIAccessToken != "" || ProviderFromString(p.ProviderType) == Taboola) &&
+		p.APIClientID != "" && p.APIClientSecret != ""
 }
 
 func (p ProviderCreate) AsDbProvider() DbProvider {
@@ -240,7 +255,12 @@ type DbAccountSpend struct {
 	ProviderType      ProviderEnum `ch:"provider_type" json:"provider_type"`
 	Status            string       `ch:"status" json:"status"`
 	Spend             float64      `ch:"spend" json:"spend"`
+	Impressions       uint64       `ch:"impressions" json:"impressions"`
+	Clicks            uint64       `ch:"clicks" json:"clicks"`
+	Conversions       float64      `ch:"conversions" json:"conversions"`
+	PurchaseValue     float64      `ch:"purchase_value" json:"purchase_value"`
 	NumberOfCampaigns uint16       `ch:"number_of_campaigns" json:"number_of_campaigns"`
+	Timezone          string       `ch:"timezone" json:"timezone"`
 	DateRef           time.Time    `ch:"date_ref" json:"date_ref"`
 	UpdatedAt         time.Time    `ch:"updated_at" json:"updated_at"`
 }
@@ -256,6 +276,15 @@ type DbAccountSpendGrouped struct {
 	ProviderType      ProviderEnum `ch:"provider_type" json:"provider_type"`
 	Status            string       `ch:"status" json:"status"`
 	Spend             float64      `ch:"spend" json:"spend"`
+	Impressions       uint64       `ch:"impressions" json:"impressions"`
+	Clicks            uint64       `ch:"clicks" json:"clicks"`
+	Conversions       float64      `ch:"conversions" json:"conversions"`
+	PurchaseValue     float64      `ch:"purchase_value" json:"purchase_value"`
+	CPC               float64      `ch:"cpc" json:"cpc"`
+	CPM               float64      `ch:"cpm" json:"cpm"`
+	CTR               float64      `ch:"ctr" json:"ctr"`
+	CPA               float64      `ch:"cpa" json:"cpa"`
+	ROAS              float64      `ch:"roas" json:"roas"`
 	NumberOfCampaigns uint16       `ch:"number_of_campaigns" json:"number_of_campaigns"`
 	DateStart         time.Time    `ch:"date_start" json:"date_start"`
 	DateEnd           time.Time    `ch:"date_end" json:"date_end"`
@@ -268,49 +297,203 @@ type ClientSpendRequest struct {
 	End      time.Time `form:"end" time_format:"2006-01-02"`
 }
 
+// RuleBacktestRequest replays the spend stored for a client between two
+// days (2006-01-02) through a stored rule, or through the given one.
+type RuleBacktestRequest struct {
+	ClientID string  `json:"client_id"`
+	RuleID   string  `json:"rule_id"`
+	Rule     *DbRule `json:"rule"`
+	Start    string  `json:"start"`
+	End      string  `json:"end"`
+	// Mode is "daily" (default) or "snapshots"
+	Mode string `json:"mode"`
+}
+
+func (r *RuleBacktestRequest) IsValid() bool {
+	return r.ClientID != "" && (r.RuleID != "") != (r.Rule != nil) && r.Start != "" && r.End != ""
+}
+
+// RuleExplainRequest evaluates a stored rule, or the given one, on the spend
+// stored for a client on a day (2006-01-02).
+type RuleExplainRequest struct {
+	ClientID string  `json:"client_id"`
+	RuleID   string  `json:"rule_id"`
+	Rule     *DbRule `json:"rule"`
+	Day      string  `json:"day"`
+}
+
+func (r *RuleExplainRequest) IsValid() bool {
+	return r.ClientID != "" && (r.RuleID != "") != (r.Rule != nil) && r.Day != ""
+}
+
+// RuleTemplateRequest creates a rule of a client from a template of the
+// catalog, substituting the parameters.
+type RuleTemplateRequest struct {
+	ClientID string         `json:"client_id"`
+	Template string         `json:"template"`
+	RuleName string         `json:"rule_name"`
+	Params   map[string]any `json:"params"`
+}
+
+func (r *RuleTemplateRequest) IsValid() bool {
+	return r.ClientID != "" && r.Template != ""
+}
+
+// AlertRequest identifies an alert of a client.
+type AlertRequest struct {
+	ClientID string `form:"client_id"`
+	AlertID  string `form:"alert_id"`
+}
+
 type DbCampaignSpend struct {
-	ClientID     string       `ch:"client_id" json:"client_id"`
-	AccountID    string       `ch:"account_id" json:"account_id"`
-	AccountName  string       `ch:"account_name" json:"account_name"`
-	AccountImage string       `ch:"account_image" json:"account_image"`
-	BusinessID   string       `ch:"business_id" json:"business_id"`
-	BusinessName string       `ch:"business_name" json:"business_name"`
-	CampaignID   string       `ch:"campaign_id" json:"campaign_id"`
-	CampaignName string       `ch:"campaign_name" json:"campaign_name"`
-	ProviderID   string       `ch:"provider_id" json:"provider_id"`
-	ProviderType ProviderEnum `ch:"provider_type" json:"provider_type"`
-	Status       string       `ch:"status" json:"status"`
-	Spend        float64      `ch:"spend" json:"spend"`
-	DateRef      time.Time    `ch:"date_ref" json:"date_ref"`
-	UpdatedAt    time.Time    `ch:"updated_at" json:"updated_at"`
+	ClientID      string       `ch:"client_id" json:"client_id"`
+	AccountID     string       `ch:"account_id" json:"account_id"`
+	AccountName   string       `ch:"account_name" json:"account_name"`
+	AccountImage  string       `ch:"account_image" json:"account_image"`
+	BusinessID    string       `ch:"business_id" json:"business_id"`
+	BusinessName  string       `ch:"business_name" json:"business_name"`
+	CampaignID    string       `ch:"campaign_id" json:"campaign_id"`
+	CampaignName  string       `ch:"campaign_name" json:"campaign_name"`
+	ProviderID    string       `ch:"provider_id" json:"provider_id"`
+	ProviderType  ProviderEnum `ch:"provider_type" json:"provider_type"`
+	Status        string       `ch:"status" json:"status"`
+	Spend         float64      `ch:"spend" json:"spend"`
+	Impressions   uint64       `ch:"impressions" json:"impressions"`
+	Clicks        uint64       `ch:"clicks" json:"clicks"`
+	Conversions   float64      `ch:"conversions" json:"conversions"`
+	PurchaseValue float64      `ch:"purchase_value" json:"purchase_value"`
+	Timezone      string       `ch:"timezone" json:"timezone"`
+	DateRef       time.Time    `ch:"date_ref" json:"date_ref"`
+	UpdatedAt     time.Time    `ch:"updated_at" json:"updated_at"`
 }
 
 type DbCampaignSpendGrouped struct {
-	ClientID     string       `ch:"client_id" json:"client_id"`
-	AccountID    string       `ch:"account_id" json:"account_id"`
-	AccountName  string       `ch:"account_name" json:"account_name"`
-	AccountImage string       `ch:"account_image" json:"account_image"`
-	BusinessID   string       `ch:"business_id" json:"business_id"`
-	BusinessName string       `ch:"business_name" json:"business_name"`
-	CampaignID   string       `ch:"campaign_id" json:"campaign_id"`
-	CampaignName string       `ch:"campaign_name" json:"campaign_name"`
-	ProviderID   string       `ch:"provider_id" json:"provider_id"`
-	ProviderType ProviderEnum `ch:"provider_type" json:"provider_type"`
-	Status       string       `ch:"status" json:"status"`
-	Spend        float64      `ch:"spend" json:"spend"`
-	DateStart    time.Time    `ch:"date_start" json:"date_start"`
-	DateEnd      time.Time    `ch:"date_end" json:"date_end"`
-	UpdatedAt    time.Time    `ch:"updated_at" json:"updated_at"`
+	ClientID      string       `ch:"client_id" json:"client_id"`
+	AccountID     string       `ch:"account_id" json:"account_id"`
+	AccountName   string       `ch:"account_name" json:"account_name"`
+	AccountImage  string       `ch:"account_image" json:"account_image"`
+	BusinessID    string       `ch:"business_id" json:"business_id"`
+	BusinessName  string       `ch:"business_name" json:"business_name"`
+	CampaignID    string       `ch:"campaign_id" json:"campaign_id"`
+	CampaignName  string       `ch:"campaign_name" json:"campaign_name"`
+	ProviderID    string       `ch:"provider_id" json:"provider_id"`
+	ProviderType  ProviderEnum `ch:"provider_type" json:"provider_type"`
+	Status        string       `ch:"status" json:"status"`
+	Spend         float64      `ch:"spend" json:"spend"`
+	Impressions   uint64       `ch:"impressions" json:"impressions"`
+	Clicks        uint64       `ch:"clicks" json:"clicks"`
+	Conversions   float64      `ch:"conversions" json:"conversions"`
+	PurchaseValue float64      `ch:"purchase_value" json:"purchase_value"`
+	CPC           float64      `ch:"cpc" json:"cpc"`
+	CPM           float64      `ch:"cpm" json:"cpm"`
+	CTR           float64      `ch:"ctr" json:"ctr"`
+	CPA           float64      `ch:"cpa" json:"cpa"`
+	ROAS          float64      `ch:"roas" json:"roas"`
+	DateStart     time.Time    `ch:"date_start" json:"date_start"`
+	DateEnd       time.Time    `ch:"date_end" json:"date_end"`
+	UpdatedAt     time.Time    `ch:"updated_at" json:"updated_at"`
 }
 
 type DbRule struct {
-	RuleID          string    `ch:"rule_id" json:"rule_id"`
-	ClientID        string    `ch:"client_id" json:"client_id"`
-	RuleName        string    `ch:"rule_name" json:"rule_name"`
-	Column          string    `ch:"column" json:"column"`
-	Operator        string    `ch:"operator" json:"operator"`
-	Value           float64   `ch:"value" json:"value"`
-	NotificationWay string    `ch:"notification_way" json:"notification_way"`
-	InsertedAt      time.Time `ch:"inserted_at" json:"inserted_at"`
-	UpdatedAt       time.Time `ch:"updated_at" json:"updated_at"`
+	RuleID          string    `ch:"rule_id" json:"rule_id" db:"rule_id"`
+	ClientID        string    `ch:"client_id" json:"client_id" db:"client_id"`
+	RuleName        string    `ch:"rule_name" json:"rule_name" db:"rule_name"`
+	Column          string    `ch:"column" json:"column" db:"column"`
+	Operator        string    `ch:"operator" json:"operator" db:"operator"`
+	Value           float64   `ch:"value" json:"value" db:"value"`
+	Conditions      string    `ch:"conditions" json:"conditions" db:"conditions"`
+	Expression      string    `ch:"expression" json:"expression" db:"expression"`
+	Scope           string    `ch:"scope" json:"scope" db:"scope"`
+	RuleType        string    `ch:"rule_type" json:"rule_type" db:"rule_type"`
+	Params          string    `ch:"params" json:"params" db:"params"`
+	NotificationWay string    `ch:"notification_way" json:"notification_way" db:"notification_way"`
+	InsertedAt      time.Time `ch:"inserted_at" json:"inserted_at" db:"inserted_at"`
+	UpdatedAt       time.Time `ch:"updated_at" json:"updated_at" db:"updated_at"`
+
+	// CooldownMinutes is the time after a notification during which a new
+	// firing of the alert is not notified, RenotifyMinutes the interval the
+	// notification is sent again at while the alert keeps firing (0 never).
+	CooldownMinutes uint32 `ch:"cooldown_minutes" json:"cooldown_minutes" db:"cooldown_minutes"`
+	RenotifyMinutes uint32 `ch:"renotify_minutes" json:"renotify_minutes" db:"renotify_minutes"`
+	// NotifyResolved sends a notification when the alert stops firing.
+	NotifyResolved bool `ch:"notify_resolved" json:"notify_resolved" db:"notify_resolved"`
+	// SustainEvaluations and SustainMinutes are the number of consecutive
+	// evaluations, and the time, the rule has to match for before firing.
+	SustainEvaluations uint32 `ch:"sustain_evaluations" json:"sustain_evaluations" db:"sustain_evaluations"`
+	SustainMinutes     uint32 `ch:"sustain_minutes" json:"sustain_minutes" db:"sustain_minutes"`
+	// Schedule is the JSON schedule the rule is evaluated in, always when
+	// empty.
+	Schedule string `ch:"schedule" json:"schedule" db:"schedule"`
+	// Severity is INFO, WARNING or CRITICAL, the notifications are routed
+	// by the severity of their rule.
+	Severity string `ch:"severity" json:"severity" db:"severity"`
+	// Destinations is the JSON addresses of each channel the notifications
+	// of the rule are sent to, instead of the ones of the client.
+	Destinations string `ch:"destinations" json:"destinations" db:"destinations"`
+}
+
+// DbAlert is an alert sent for a rule matching an entity, Trace is the JSON
+// trace of the evaluation explaining why it fired.
+type DbAlert struct {
+	AlertID      string    `ch:"alert_id" json:"alert_id" db:"alert_id"`
+	ClientID     string    `ch:"client_id" json:"client_id" db:"client_id"`
+	RuleID       string    `ch:"rule_id" json:"rule_id" db:"rule_id"`
+	RuleName     string    `ch:"rule_name" json:"rule_name" db:"rule_name"`
+	EntityType   string    `ch:"entity_type" json:"entity_type" db:"entity_type"`
+	EntityID     string    `ch:"entity_id" json:"entity_id" db:"entity_id"`
+	EntityName   string    `ch:"entity_name" json:"entity_name" db:"entity_name"`
+	Threshold    string    `ch:"threshold" json:"threshold" db:"threshold"`
+	Metric       string    `ch:"metric" json:"metric" db:"metric"`
+	Value        string    `ch:"value" json:"value" db:"value"`
+	Baseline     string    `ch:"baseline" json:"baseline" db:"baseline"`
+	CurrentSpend float64   `ch:"current_spend" json:"current_spend" db:"current_spend"`
+	Trace        string    `ch:"trace" json:"-" db:"trace"`
+	FiredAt      time.Time `ch:"fired_at" json:"fired_at" db:"fired_at"`
+}
+
+// Alert statuses, a PENDING alert matches but has not been sustained yet.
+const (
+	AlertFiring   = "FIRING"
+	AlertResolved = "RESOLVED"
+	AlertPending  = "PENDING"
+)
+
+// DbAlertState is the state of the alert of a rule on an entity, the
+// notifications are sent on its transitions.
+type DbAlertState struct {
+	ClientID   string `ch:"client_id" json:"client_id" db:"client_id"`
+	RuleID     string `ch:"rule_id" json:"rule_id" db:"rule_id"`
+	EntityType string `ch:"entity_type" json:"entity_type" db:"entity_type"`
+	EntityID   string `ch:"entity_id" json:"entity_id" db:"entity_id"`
+	EntityName string `ch:"entity_name" json:"entity_name" db:"entity_name"`
+	Status     string `ch:"status" json:"status" db:"status"`
+	// FiredAt is the start of the current, or last, firing period
+	FiredAt time.Time `ch:"fired_at" json:"fired_at" db:"fired_at"`
+	// NotifiedAt is the time the firing was last notified at
+	NotifiedAt time.Time `ch:"notified_at" json:"notified_at" db:"notified_at"`
+	ResolvedAt time.Time `ch:"resolved_at" json:"resolved_at" db:"resolved_at"`
+	// Matches is the number of consecutive evaluations the rule matched at
+	// since MatchedSince, while the alert is pending
+	Matches      uint32    `ch:"matches" json:"matches" db:"matches"`
+	MatchedSince time.Time `ch:"matched_since" json:"matched_since" db:"matched_since"`
+	UpdatedAt    time.Time `ch:"updated_at" json:"updated_at" db:"updated_at"`
+}
+
+// Notification queues, the notifications held during the quiet hours of the
+// client and the ones sent in its daily digest.
+const (
+	QueueQuietHours = "QUIET"
+	QueueDigest     = "DIGEST"
+)
+
+// DbQueuedNotification is a notification held back in a queue of the client,
+// Notification is its JSON.
+type DbQueuedNotification struct {
+	NotificationID string    `ch:"notification_id" json:"notification_id" db:"notification_id"`
+	ClientID       string    `ch:"client_id" json:"client_id" db:"client_id"`
+	Queue          string    `ch:"queue" json:"queue" db:"queue"`
+	Notification   string    `ch:"notification" json:"notification" db:"notification"`
+	QueuedAt       time.Time `ch:"queued_at" json:"queued_at" db:"queued_at"`
+	Delivered      bool      `ch:"delivered" json:"delivered" db:"delivered"`
+	UpdatedAt      time.Time `ch:"updated_at" json:"updated_at" db:"updated_at"`
 }
@@ -24,14 +24,29 @@ type DbService interface {
 	GetAccountSpend(clientID string, start, end time.Time) ([]DbAccountSpend, error)
 	GetAccountSpendGrouped(clientID string, start, end time.Time) ([]DbAccountSpendGrouped, error)
 	InsertAccountSpend(data []DbAccountSpend) error
+	InsertAccountSnapshots(data []DbAccountSpend) error
+	GetAccountSnapshots(clientID string, at time.Time) ([]DbAccountSpend, error)
+	GetAccountSnapshotsOfDay(clientID string, day time.Time) ([]DbAccountSpend, error)
 
 	//campaign spend
 	GetCampaignSpend(clientID string, start, end time.Time) ([]DbCampaignSpend, error)
 	GetCampaignSpendGrouped(clientID string, start, end time.Time) ([]DbCampaignSpendGrouped, error)
 	InsertCampaignSpend(data []DbCampaignSpend) error
+	InsertCampaignSnapshots(data []DbCampaignSpend) error
+	GetCampaignSnapshots(clientID string, at time.Time) ([]DbCampaignSpend, error)
+	GetCampaignSnapshotsOfDay(clientID string, day time.Time) ([]DbCampaignSpend, error)
 
 	//rule
 	InsertRule(rule *DbRule) error
 	GetRulesByClientID(clientID string) ([]DbRule, error)
 	GetRuleByID(ruleID string) (*DbRule, error)
+
+	//alert
+	InsertAlert(alert *DbAlert) error
+	GetAlertsByClientID(clientID string, start, end time.Time) ([]DbAlert, error)
+	GetAlertByID(alertID string) (*DbAlert, error)
+	GetAlertStates(clientID string) ([]DbAlertState, error)
+	UpsertAlertStates(states []DbAlertState) error
+	GetQueuedNotifications(clientID, queue string) ([]DbQueuedNotification, error)
+	UpsertQueuedNotifications(notifications []DbQueuedNotification) error
 }
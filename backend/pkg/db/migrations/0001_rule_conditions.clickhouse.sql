@@ -0,0 +1,3 @@
+-- adds the JSON condition tree of the rules
+
+ALTER TABLE adszero.client_rules ADD COLUMN IF NOT EXISTS conditions String default '' AFTER value;
@@ -0,0 +1,3 @@
+-- adds the textual expression of the rules
+
+ALTER TABLE adszero.client_rules ADD COLUMN IF NOT EXISTS expression String default '' AFTER conditions;
@@ -0,0 +1,4 @@
+-- adds the scope of the rules, the existing rules keep being evaluated on
+-- the whole client
+
+ALTER TABLE adszero.client_rules ADD COLUMN IF NOT EXISTS scope Enum8('CLIENT'=0,'PROVIDER'=1,'BUSINESS'=2,'ACCOUNT'=3,'CAMPAIGN'=4) default 'CLIENT' AFTER expression;
@@ -0,0 +1,14 @@
+-- adds the delivery metrics of the accounts and campaigns, the stored days
+-- have none
+
+ALTER TABLE adszero.account_spends
+    ADD COLUMN IF NOT EXISTS impressions UInt64 default 0 AFTER spend,
+    ADD COLUMN IF NOT EXISTS clicks UInt64 default 0 AFTER impressions,
+    ADD COLUMN IF NOT EXISTS conversions Float64 default 0 AFTER clicks,
+    ADD COLUMN IF NOT EXISTS purchase_value Float64 default 0 AFTER conversions;
+
+ALTER TABLE adszero.campaigns_spend
+    ADD COLUMN IF NOT EXISTS impressions UInt64 default 0 AFTER spend,
+    ADD COLUMN IF NOT EXISTS clicks UInt64 default 0 AFTER impressions,
+    ADD COLUMN IF NOT EXISTS conversions Float64 default 0 AFTER clicks,
+    ADD COLUMN IF NOT EXISTS purchase_value Float64 default 0 AFTER conversions;
@@ -0,0 +1,50 @@
+-- adds the snapshots of every fetch of the current day, the baselines of
+-- the period-over-period operators
+
+CREATE TABLE IF NOT EXISTS adszero.account_spend_snapshots (
+    client_id String NOT NULL,
+    account_id String NOT NULL,
+    account_name String NOT NULL,
+    account_image String,
+    business_id String NOT NULL,
+    business_name String NOT NULL,
+    provider_id FixedString(26) NOT NULL,
+    provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4) default 'INVALID',
+    status Enum8('UNKNOWN'=0,'ACTIVE'=1,'INACTIVE'=2) default 'UNKNOWN',
+    spend Float64,
+    impressions UInt64 default 0,
+    clicks UInt64 default 0,
+    conversions Float64 default 0,
+    purchase_value Float64 default 0,
+    number_of_campaigns UInt16,
+    date_ref Date32 default now(),
+    updated_at DateTime64(9) default now64(9)
+)
+ENGINE=MergeTree
+ORDER BY (client_id,date_ref,updated_at,account_id)
+partition by toMonth(date_ref)
+TTL toDate(date_ref) + INTERVAL 35 DAY;
+
+CREATE TABLE IF NOT EXISTS adszero.campaign_spend_snapshots (
+    client_id String NOT NULL,
+    account_id String NOT NULL,
+    account_name String NOT NULL,
+    business_id String NOT NULL,
+    business_name String NOT NULL,
+    campaign_id String NOT NULL,
+    campaign_name String NOT NULL,
+    provider_id FixedString(26) NOT NULL,
+    provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4) default 'INVALID',
+    status Enum8('UNKNOWN'=0,'ACTIVE'=1,'INACTIVE'=2) default 'UNKNOWN',
+    spend Float64,
+    impressions UInt64 default 0,
+    clicks UInt64 default 0,
+    conversions Float64 default 0,
+    purchase_value Float64 default 0,
+    date_ref Date32 default now(),
+    updated_at DateTime64(9) default now64(9)
+)
+ENGINE=MergeTree
+ORDER BY (client_id,date_ref,updated_at,campaign_id)
+partition by toMonth(date_ref)
+TTL toDate(date_ref) + INTERVAL 35 DAY;
@@ -0,0 +1,5 @@
+-- adds the type of the rules and the parameters of the non threshold ones
+
+ALTER TABLE adszero.client_rules
+    ADD COLUMN IF NOT EXISTS rule_type Enum8('THRESHOLD'=0,'ANOMALY'=1) default 'THRESHOLD' AFTER scope,
+    ADD COLUMN IF NOT EXISTS params String default '' AFTER rule_type;
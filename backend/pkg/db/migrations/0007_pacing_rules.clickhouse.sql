@@ -0,0 +1,9 @@
+-- adds the timezone of the ad accounts, the stored rows are in UTC, and the
+-- PACING rule type
+
+ALTER TABLE adszero.account_spends ADD COLUMN IF NOT EXISTS timezone String default 'UTC' AFTER number_of_campaigns;
+ALTER TABLE adszero.campaigns_spend ADD COLUMN IF NOT EXISTS timezone String default 'UTC' AFTER purchase_value;
+ALTER TABLE adszero.account_spend_snapshots ADD COLUMN IF NOT EXISTS timezone String default 'UTC' AFTER number_of_campaigns;
+ALTER TABLE adszero.campaign_spend_snapshots ADD COLUMN IF NOT EXISTS timezone String default 'UTC' AFTER purchase_value;
+
+ALTER TABLE adszero.client_rules MODIFY COLUMN rule_type Enum8('THRESHOLD'=0,'ANOMALY'=1,'PACING'=2) default 'THRESHOLD';
@@ -0,0 +1,21 @@
+-- adds the alerts sent for the rules, with the trace of their evaluation
+
+CREATE TABLE IF NOT EXISTS adszero.rule_alerts (
+    alert_id FixedString(26) NOT NULL,
+    client_id String NOT NULL,
+    rule_id String NOT NULL,
+    rule_name String NOT NULL,
+    entity_type Enum8('PROVIDER'=0,'BUSINESS'=1,'ACCOUNT'=2,'CAMPAIGN'=3,'ADSET'=4,'AD'=5,'CLIENT'=6) default 'CLIENT',
+    entity_id String,
+    entity_name String,
+    threshold String,
+    metric String default '',
+    value String default '',
+    baseline String default '',
+    current_spend Float64,
+    trace String default '',
+    fired_at DateTime64(9) default now64(9)
+)
+ENGINE=MergeTree
+ORDER BY (client_id,fired_at,alert_id)
+partition by toYYYYMM(fired_at);
@@ -0,0 +1,22 @@
+-- adds the notification settings of the rules and the state of their
+-- alerts
+
+ALTER TABLE adszero.client_rules
+    ADD COLUMN IF NOT EXISTS cooldown_minutes UInt32 default 0 AFTER notification_way,
+    ADD COLUMN IF NOT EXISTS renotify_minutes UInt32 default 0 AFTER cooldown_minutes,
+    ADD COLUMN IF NOT EXISTS notify_resolved Bool default false AFTER renotify_minutes;
+
+CREATE TABLE IF NOT EXISTS adszero.alert_states (
+    client_id String NOT NULL,
+    rule_id String NOT NULL,
+    entity_type Enum8('PROVIDER'=0,'BUSINESS'=1,'ACCOUNT'=2,'CAMPAIGN'=3,'ADSET'=4,'AD'=5,'CLIENT'=6) default 'CLIENT',
+    entity_id String,
+    entity_name String,
+    status Enum8('FIRING'=1,'RESOLVED'=2),
+    fired_at DateTime64(9),
+    notified_at DateTime64(9),
+    resolved_at DateTime64(9),
+    updated_at DateTime64(9) default now64(9)
+)
+ENGINE=ReplacingMergeTree(updated_at)
+ORDER BY (client_id,rule_id,entity_type,entity_id);
@@ -0,0 +1,11 @@
+-- adds the time the rules have to match for before firing, and the pending
+-- alerts counting their matches
+
+ALTER TABLE adszero.client_rules
+    ADD COLUMN IF NOT EXISTS sustain_evaluations UInt32 default 0 AFTER notify_resolved,
+    ADD COLUMN IF NOT EXISTS sustain_minutes UInt32 default 0 AFTER sustain_evaluations;
+
+ALTER TABLE adszero.alert_states
+    MODIFY COLUMN status Enum8('FIRING'=1,'RESOLVED'=2,'PENDING'=3),
+    ADD COLUMN IF NOT EXISTS matches UInt32 default 0 AFTER resolved_at,
+    ADD COLUMN IF NOT EXISTS matched_since DateTime64(9) AFTER matches;
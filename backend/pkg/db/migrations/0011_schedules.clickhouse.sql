@@ -0,0 +1,19 @@
+-- adds the schedules of the rules, the quiet hours of the clients and the
+-- queue of the notifications held during the quiet hours
+
+ALTER TABLE adszero.clients
+    ADD COLUMN IF NOT EXISTS timezone String default '' AFTER slack_webhook_url,
+    ADD COLUMN IF NOT EXISTS quiet_hours String default '' AFTER timezone;
+
+ALTER TABLE adszero.client_rules ADD COLUMN IF NOT EXISTS schedule String default '' AFTER sustain_minutes;
+
+CREATE TABLE IF NOT EXISTS adszero.queued_notifications (
+    notification_id FixedString(26) NOT NULL,
+    client_id String NOT NULL,
+    notification String NOT NULL,
+    queued_at DateTime64(9),
+    delivered Bool default false,
+    updated_at DateTime64(9) default now64(9)
+)
+ENGINE=ReplacingMergeTree(updated_at)
+ORDER BY (client_id,notification_id);
@@ -0,0 +1,3 @@
+-- adds the quiet hours of the clients
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT '';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS quiet_hours TEXT NOT NULL DEFAULT '';
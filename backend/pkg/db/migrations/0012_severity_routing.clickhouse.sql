@@ -0,0 +1,26 @@
+-- adds the severity of the rules, the routing of the clients and the queue
+-- of the notifications. The queue is part of the sorting key, so the table
+-- of the queued notifications is rebuilt, the queued ones are QUIET.
+
+ALTER TABLE adszero.clients ADD COLUMN IF NOT EXISTS routing String default '' AFTER quiet_hours;
+
+ALTER TABLE adszero.client_rules ADD COLUMN IF NOT EXISTS severity Enum8('INFO'=0,'WARNING'=1,'CRITICAL'=2) default 'WARNING' AFTER schedule;
+
+CREATE TABLE adszero.queued_notifications_v2 (
+    notification_id FixedString(26) NOT NULL,
+    client_id String NOT NULL,
+    queue Enum8('QUIET'=0,'DIGEST'=1) default 'QUIET',
+    notification String NOT NULL,
+    queued_at DateTime64(9),
+    delivered Bool default false,
+    updated_at DateTime64(9) default now64(9)
+)
+ENGINE=ReplacingMergeTree(updated_at)
+ORDER BY (client_id,queue,notification_id);
+
+INSERT INTO adszero.queued_notifications_v2 (notification_id, client_id, queue, notification, queued_at, delivered, updated_at)
+SELECT notification_id, client_id, 'QUIET', notification, queued_at, delivered, updated_at
+FROM adszero.queued_notifications FINAL;
+
+EXCHANGE TABLES adszero.queued_notifications AND adszero.queued_notifications_v2;
+DROP TABLE adszero.queued_notifications_v2;
@@ -0,0 +1,2 @@
+-- adds the routing of the severities of the clients
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS routing TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+-- adds the DEFAULT notification way, routing the notifications of the rule
+-- by its severity, and the destinations of the rules.
+--
+-- The notification way of the rules wasn't honored before and EMAIL was its
+-- default, so the existing EMAIL rules are moved to DEFAULT: they keep being
+-- notified on the channels of the client instead of switching to email only.
+-- The rules that should only notify by email have to be updated afterwards.
+
+ALTER TABLE adszero.client_rules
+    MODIFY COLUMN notification_way Enum8('EMAIL'=0,'TELEGRAM'=1,'SLACK'=2,'DEFAULT'=3) default 'DEFAULT',
+    ADD COLUMN IF NOT EXISTS destinations String default '' AFTER severity;
+
+ALTER TABLE adszero.client_rules UPDATE notification_way = 'DEFAULT' WHERE notification_way = 'EMAIL'
+SETTINGS mutations_sync = 2;
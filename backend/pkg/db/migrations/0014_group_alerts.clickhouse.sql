@@ -0,0 +1,3 @@
+-- adds the option of the clients combining the alerts of a fetch
+
+ALTER TABLE adszero.clients ADD COLUMN IF NOT EXISTS group_alerts Bool default false AFTER routing;
@@ -0,0 +1,2 @@
+-- adds the option of the clients combining the alerts of a fetch
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS group_alerts BOOLEAN NOT NULL DEFAULT FALSE;
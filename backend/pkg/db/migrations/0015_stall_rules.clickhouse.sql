@@ -0,0 +1,3 @@
+-- adds the STALL rule type
+
+ALTER TABLE adszero.client_rules MODIFY COLUMN rule_type Enum8('THRESHOLD'=0,'ANOMALY'=1,'PACING'=2,'STALL'=3) default 'THRESHOLD';
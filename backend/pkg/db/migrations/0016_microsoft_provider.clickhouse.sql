@@ -0,0 +1,8 @@
+-- adds the MICROSOFT provider type to the existing tables, the new values
+-- are appended so that the stored ones keep their number
+
+ALTER TABLE adszero.providers MODIFY COLUMN provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4,'MICROSOFT'=5) default 'INVALID';
+ALTER TABLE adszero.account_spends MODIFY COLUMN provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4,'MICROSOFT'=5) default 'INVALID';
+ALTER TABLE adszero.campaigns_spend MODIFY COLUMN provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4,'MICROSOFT'=5) default 'INVALID';
+ALTER TABLE adszero.account_spend_snapshots MODIFY COLUMN provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4,'MICROSOFT'=5) default 'INVALID';
+ALTER TABLE adszero.campaign_spend_snapshots MODIFY COLUMN provider_type Enum8('INVALID'=0,'FACEBOOK'=1,'GOOGLE'=2,'TIKTOK'=3,'TABOOLA'=4,'MICROSOFT'=5) default 'INVALID';
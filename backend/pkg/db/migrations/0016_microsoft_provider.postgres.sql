@@ -0,0 +1,2 @@
+-- adds the MICROSOFT provider type to the existing databases
+ALTER TYPE PROVIDER_TYPE ADD VALUE IF NOT EXISTS 'MICROSOFT';
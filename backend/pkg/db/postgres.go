@@ -51,6 +51,36 @@ func (p *pgService) GetAccountSpendGrouped(clientID string, start time.Time, end
 	panic("unimplemented")
 }
 
+// InsertAccountSnapshots implements DbService.
+func (p *pgService) InsertAccountSnapshots(data []DbAccountSpend) error {
+	panic("unimplemented")
+}
+
+// GetAccountSnapshots implements DbService.
+func (p *pgService) GetAccountSnapshots(clientID string, at time.Time) ([]DbAccountSpend, error) {
+	panic("unimplemented")
+}
+
+// GetAccountSnapshotsOfDay implements DbService.
+func (p *pgService) GetAccountSnapshotsOfDay(clientID string, day time.Time) ([]DbAccountSpend, error) {
+	panic("unimplemented")
+}
+
+// InsertCampaignSnapshots implements DbService.
+func (p *pgService) InsertCampaignSnapshots(data []DbCampaignSpend) error {
+	panic("unimplemented")
+}
+
+// GetCampaignSnapshots implements DbService.
+func (p *pgService) GetCampaignSnapshots(clientID string, at time.Time) ([]DbCampaignSpend, error) {
+	panic("unimplemented")
+}
+
+// GetCampaignSnapshotsOfDay implements DbService.
+func (p *pgService) GetCampaignSnapshotsOfDay(clientID string, day time.Time) ([]DbCampaignSpend, error) {
+	panic("unimplemented")
+}
+
 // GetCampaignSpend implements DbService.
 func (p *pgService) GetCampaignSpend(clientID string, start time.Time, end time.Time) ([]DbCampaignSpend, error) {
 	panic("unimplemented")
@@ -104,8 +134,8 @@ func (p *pgService) InsertClient(client *DbClient) error {
 		return err
 	}
 	q := `
-	insert into clients (client_id, user_email, user_type, stripe_customer_id, stripe_subscription_id, stripe_subscription_status, notification_email, telegram_chat_id, slack_webhook_url)
-	values (:client_id,:user_email,:user_type,:stripe_customer_id,:stripe_subscription_id,:stripe_subscription_status,:notification_email,:telegram_chat_id,:slack_webhook_url);
+	insert into clients (client_id, user_email, user_type, stripe_customer_id, stripe_subscription_id, stripe_subscription_status, notification_email, telegram_chat_id, slack_webhook_url, timezone, quiet_hours, routing, group_alerts)
+	values (:client_id,:user_email,:user_type,:stripe_customer_id,:stripe_subscription_id,:stripe_subscription_status,:notification_email,:telegram_chat_id,:slack_webhook_url,:timezone,:quiet_hours,:routing,:group_alerts);
 	`
 	_, err = tx.NamedExecContext(p.ctx, q, client)
 	if err != nil {
@@ -161,3 +191,38 @@ func NewPostgreService() (DbService, error) {
 	// p.conn.TypeMap().RegisterTypes(types)
 	return &p, nil
 }
+
+// InsertAlert implements DbService.
+func (p *pgService) InsertAlert(alert *DbAlert) error {
+	panic("unimplemented")
+}
+
+// GetAlertsByClientID implements DbService.
+func (p *pgService) GetAlertsByClientID(clientID string, start, end time.Time) ([]DbAlert, error) {
+	panic("unimplemented")
+}
+
+// GetAlertByID implements DbService.
+func (p *pgService) GetAlertByID(alertID string) (*DbAlert, error) {
+	panic("unimplemented")
+}
+
+// GetAlertStates implements DbService.
+func (p *pgService) GetAlertStates(clientID string) ([]DbAlertState, error) {
+	panic("unimplemented")
+}
+
+// UpsertAlertStates implements DbService.
+func (p *pgService) UpsertAlertStates(states []DbAlertState) error {
+	panic("unimplemented")
+}
+
+// GetQueuedNotifications implements DbService.
+func (p *pgService) GetQueuedNotifications(clientID, queue string) ([]DbQueuedNotification, error) {
+	panic("unimplemented")
+}
+
+// UpsertQueuedNotifications implements DbService.
+func (p *pgService) UpsertQueuedNotifications(notifications []DbQueuedNotification) error {
+	panic("unimplemented")
+}
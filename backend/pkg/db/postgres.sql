@@ -1,6 +1,6 @@
 CREATE TYPE USERTYPE AS ENUM ('UNKNOWN', 'FREE', 'TIER1', 'TIER2', 'TIER3');
 CREATE TYPE SUB_STATUS AS ENUM ('UNKNOWN','ACTIVE', 'INACTIVE');
-CREATE TYPE PROVIDER_TYPE AS ENUM ('INVALID','FACEBOOK','GOOGLE','TIKTOK','TABOOLA');
+CREATE TYPE PROVIDER_TYPE AS ENUM ('INVALID','FACEBOOK','GOOGLE','TIKTOK','TABOOLA','MICROSOFT');
 
 
 CREATE TABLE clients (
@@ -13,6 +13,10 @@ CREATE TABLE clients (
     notification_email VARCHAR(255)  CHECK (user_email ~* '^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$'),
     telegram_chat_id TEXT,
     slack_webhook_url TEXT,
+    timezone TEXT NOT NULL DEFAULT '',
+    quiet_hours TEXT NOT NULL DEFAULT '',
+    routing TEXT NOT NULL DEFAULT '',
+    group_alerts BOOLEAN NOT NULL DEFAULT FALSE,
     inserted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
@@ -1,12 +1,17 @@
 package fetcher
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/oklog/ulid/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/s0und0fs1lence/ads-zero/pkg/alert"
 	"github.com/s0und0fs1lence/ads-zero/pkg/common"
 	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/notifier"
 	"github.com/s0und0fs1lence/ads-zero/pkg/rule"
 )
 
@@ -20,6 +25,9 @@ type clientInfo struct {
 	user               *db.DbClient
 	connectedProviders []Provider
 	rules              []rule.Rule
+	policies           map[string]alert.Policy
+	quietHours         *alert.Schedule
+	routing            alert.Routing
 	err                error
 }
 
@@ -27,7 +35,7 @@ type clientInfo struct {
 func (c *clientInfo) GetNotificationChannel() (tp string, value string) {
 	if c.user != nil {
 		if c.user.NotificationEmail != "" {
-			return "email", c.user.NotificationEmail
+			return "mail", c.user.NotificationEmail
 		}
 		if c.user.TelegramChatID != "" {
 			return "telegram", c.user.TelegramChatID
@@ -39,24 +47,201 @@ func (c *clientInfo) GetNotificationChannel() (tp string, value string) {
 	return "", ""
 }
 
+// GetNotificationDestinations implements Client.
+// The destinations of the rule take precedence, then its notification way and
+// the routing of the client by severity. The channels of the client without
+// an address are skipped, and without any the default channel is used.
+func (c *clientInfo) GetNotificationDestinations(result *common.RuleResult) []notifier.Destination {
+	if c.user == nil {
+		return nil
+	}
+	policy := c.policies[result.RuleID]
+	if len(policy.Destinations) > 0 {
+		return policy.Destinations
+	}
+	channels := c.routing.Channels(result.Severity)
+	if policy.Way != "" {
+		channels = []string{policy.Way}
+	}
+	res := make([]notifier.Destination, 0)
+	mailAddress := c.user.NotificationEmail
+	if mailAddress == "" {
+		mailAddress = c.user.UserEmail
+	}
+	for _, channel := range channels {
+		switch channel {
+		case alert.ChannelEmail:
+			res = append(res, notifier.Destination{Type: "mail", Dest: mailAddress})
+		case alert.ChannelDigest:
+			res = append(res, notifier.Destination{Type: alert.ChannelDigest, Dest: mailAddress})
+		case alert.ChannelTelegram:
+			if c.user.TelegramChatID != "" {
+				res = append(res, notifier.Destination{Type: "telegram", Dest: c.user.TelegramChatID})
+			}
+		case alert.ChannelSlack:
+			if c.user.SlackWebhookURL != "" {
+				res = append(res, notifier.Destination{Type: "slack", Dest: c.user.SlackWebhookURL})
+			}
+		}
+	}
+	if len(res) == 0 {
+		if tp, value := c.GetNotificationChannel(); tp != "" {
+			res = append(res, notifier.Destination{Type: tp, Dest: value})
+		}
+	}
+	return res
+}
+
 // ExecuteRules implements Client.
-func (c *clientInfo) ExecuteRules(task common.Event) ([]*common.RuleResult, error) {
-	res := make([]*common.RuleResult, len(c.rules))
-	for idx, r := range c.rules {
-		ruleRes, err := r.Exec(task)
+func (c *clientInfo) ExecuteRules(task *common.FetchTask) ([]*common.RuleResult, error) {
+	if c.dbSvc != nil && c.user != nil {
+		// windowed conditions look at the spend stored by the previous fetches
+		task.WithHistory(c.user.ClientID, c.dbSvc)
+	}
+	// every entity is evaluated, the alerts of the ones that stopped
+	// matching are resolved
+	res := make([]*common.RuleResult, 0, len(c.rules))
+	for _, r := range c.rules {
+		// the rules are only evaluated in their schedule
+		if !c.policies[r.Id()].Schedule.Active(task.End) {
+			continue
+		}
+		ruleRes, err := rule.Evaluate(r, task)
 		if err != nil {
 			return nil, err
 		}
-		res[idx] = &common.RuleResult{
-			RuleName:  r.Name(),
-			RuleID:    r.Id(),
-			Result:    ruleRes,
-			Threshold: r.Value(),
+		for _, result := range ruleRes {
+			result.Severity = c.policies[r.Id()].Severity
+			if result.Trace != nil && result.Trace.Error != "" {
+				log.Error().Str("rule_id", result.RuleID).Str("entity_id", result.EntityID).Str("error", result.Trace.Error).Msg("could not evaluate the rule")
+			}
+		}
+		res = append(res, ruleRes...)
+	}
+	return res, nil
+}
+
+// ProcessAlerts implements Client.
+// The alerts of the entities missing from a task with fetch errors are left
+// unchanged, as the entities may only have failed to be fetched.
+func (c *clientInfo) ProcessAlerts(task *common.FetchTask, results []*common.RuleResult) ([]alert.Event, error) {
+	if c.user == nil {
+		return nil, unitializedClient
+	}
+	return alert.Process(c.dbSvc, c.user.ClientID, c.policies, results, len(task.Errors) > 0, task.End)
+}
+
+// GroupAlerts implements Client.
+func (c *clientInfo) GroupAlerts() bool {
+	return c.user != nil && c.user.GroupAlerts
+}
+
+// QuietHours implements Client.
+func (c *clientInfo) QuietHours(at time.Time) bool {
+	return c.quietHours.Contains(at)
+}
+
+// DayStart implements Client.
+func (c *clientInfo) DayStart(at time.Time) time.Time {
+	location := time.UTC
+	if c.user != nil {
+		if l, err := alert.LoadLocation(c.user.Timezone); err == nil {
+			location = l
+		}
+	}
+	local := at.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+}
+
+// QueueNotification implements Client.
+func (c *clientInfo) QueueNotification(queue string, n *notifier.Notification, at time.Time) error {
+	if c.user == nil {
+		return unitializedClient
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("could not encode the notification: %w", err)
+	}
+	return c.dbSvc.UpsertQueuedNotifications([]db.DbQueuedNotification{{
+		NotificationID: ulid.Make().String(),
+		ClientID:       c.user.ClientID,
+		Queue:          queue,
+		Notification:   string(data),
+		QueuedAt:       at,
+		UpdatedAt:      at,
+	}})
+}
+
+// DequeueNotifications implements Client.
+// The notifications stay in the queue until they are marked as delivered, so
+// that the ones that could not be sent are retried by the next fetch. The
+// ones that cannot be decoded are dropped.
+func (c *clientInfo) DequeueNotifications(queue string, before, at time.Time) ([]QueuedNotification, error) {
+	if c.user == nil {
+		return nil, unitializedClient
+	}
+	all, err := c.dbSvc.GetQueuedNotifications(c.user.ClientID, queue)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]QueuedNotification, 0, len(all))
+	broken := make([]string, 0)
+	for _, q := range all {
+		if !q.QueuedAt.Before(before) {
+			continue
+		}
+		var n notifier.Notification
+		if err := json.Unmarshal([]byte(q.Notification), &n); err != nil {
+			log.Error().Err(err).Str("notification_id", q.NotificationID).Msg("could not decode the queued notification")
+			broken = append(broken, q.NotificationID)
+			continue
 		}
+		res = append(res, QueuedNotification{ID: q.NotificationID, Notification: &n})
+	}
+	if err := c.markDelivered(all, broken, at); err != nil {
+		return nil, err
 	}
 	return res, nil
 }
 
+// MarkDelivered implements Client.
+func (c *clientInfo) MarkDelivered(queue string, ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if c.user == nil {
+		return unitializedClient
+	}
+	all, err := c.dbSvc.GetQueuedNotifications(c.user.ClientID, queue)
+	if err != nil {
+		return err
+	}
+	return c.markDelivered(all, ids, at)
+}
+
+// markDelivered stores the queued notifications with the ids as delivered.
+func (c *clientInfo) markDelivered(queued []db.DbQueuedNotification, ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	delivered := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		delivered[id] = true
+	}
+	rows := make([]db.DbQueuedNotification, 0, len(ids))
+	for _, q := range queued {
+		if delivered[q.NotificationID] {
+			q.Delivered = true
+			q.UpdatedAt = at
+			rows = append(rows, q)
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return c.dbSvc.UpsertQueuedNotifications(rows)
+}
+
 // FetchData implements Client.
 func (c *clientInfo) FetchData(start time.Time, end time.Time) (task *common.FetchTask, err error) {
 	// create a global task wrapper that will have all the data about the underlying tasks
@@ -88,12 +273,54 @@ func (c *clientInfo) FetchData(start time.Time, end time.Time) (task *common.Fet
 }
 
 // SaveData implements Client.
+// The data is also kept as an intraday snapshot, for the period over period rules.
 func (c *clientInfo) SaveAccountData(data []db.DbAccountSpend) error {
-	return c.dbSvc.InsertAccountSpend(data)
+	if err := c.dbSvc.InsertAccountSpend(data); err != nil {
+		return err
+	}
+	return c.dbSvc.InsertAccountSnapshots(data)
+}
+
+// SaveAlert implements Client.
+func (c *clientInfo) SaveAlert(result *common.RuleResult, firedAt time.Time) (*db.DbAlert, error) {
+	if c.user == nil {
+		return nil, unitializedClient
+	}
+	trace, err := json.Marshal(result.Trace)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode the trace: %w", err)
+	}
+	alert := &db.DbAlert{
+		AlertID:      ulid.Make().String(),
+		ClientID:     c.user.ClientID,
+		RuleID:       result.RuleID,
+		RuleName:     result.RuleName,
+		EntityType:   result.EntityType.String(),
+		EntityID:     result.EntityID,
+		EntityName:   result.EntityName,
+		Threshold:    fmt.Sprint(result.Threshold),
+		Metric:       result.Metric,
+		CurrentSpend: result.CurrentSpend,
+		Trace:        string(trace),
+		FiredAt:      firedAt,
+	}
+	if result.Value != nil {
+		alert.Value = fmt.Sprint(result.Value)
+	}
+	if result.Baseline != nil {
+		alert.Baseline = fmt.Sprint(result.Baseline)
+	}
+	if err := c.dbSvc.InsertAlert(alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
 }
 
 func (c *clientInfo) SaveCampaignData(data []db.DbCampaignSpend) error {
-	return c.dbSvc.InsertCampaignSpend(data)
+	if err := c.dbSvc.InsertCampaignSpend(data); err != nil {
+		return err
+	}
+	return c.dbSvc.InsertCampaignSnapshots(data)
 }
 
 // GetError implements Client.
@@ -140,11 +367,29 @@ func (c *clientInfo) WithUserId(userId string) Client {
 		return c
 	}
 	for _, r := range dbRules {
-		newRule := rule.NewSimpleRule(
-			rule.ColumnFromString(r.Column), rule.OperatorFromString(r.Operator),
-			r.Value, r.RuleName, r.RuleID,
-		)
+		newRule, err := rule.FromDbRule(r)
+		if err != nil {
+			// a broken rule should not prevent the other ones from running
+			log.Error().Err(err).Str("client_id", dbClient.ClientID).Str("rule_id", r.RuleID).Msg("could not load the rule")
+			continue
+		}
+		policy, err := alert.PolicyFromRule(r, dbClient.Timezone)
+		if err != nil {
+			log.Error().Err(err).Str("client_id", dbClient.ClientID).Str("rule_id", r.RuleID).Msg("could not load the rule policy")
+			continue
+		}
 		c.rules = append(c.rules, newRule)
+		c.policies[r.RuleID] = policy
+	}
+	c.quietHours, err = alert.ParseSchedule(dbClient.QuietHours, dbClient.Timezone)
+	if err != nil {
+		// the notifications are not held back when the quiet hours are broken
+		log.Error().Err(err).Str("client_id", dbClient.ClientID).Msg("could not load the quiet hours")
+	}
+	c.routing, err = alert.ParseRouting(dbClient.Routing)
+	if err != nil {
+		// everything is sent to the default channel
+		log.Error().Err(err).Str("client_id", dbClient.ClientID).Msg("could not load the notification routing")
 	}
 
 	//here we should load the client information from the db, and if it's invalid, popolate the error field
@@ -164,7 +409,8 @@ func (c *clientInfo) WithDbSvc(conn db.DbService) Client {
 func NewClient() Client {
 
 	return &clientInfo{
-		dbSvc: nil,
-		err:   unitializedClient,
+		dbSvc:    nil,
+		policies: make(map[string]alert.Policy),
+		err:      unitializedClient,
 	}
 }
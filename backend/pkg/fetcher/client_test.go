@@ -0,0 +1,60 @@
+package fetcher
+
+import (
+	"testing"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/alert"
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+func TestNotificationDestinations(t *testing.T) {
+	routing, err := alert.ParseRouting(`{"critical": ["telegram", "slack"], "info": ["digest"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := func(r db.DbRule) alert.Policy {
+		p, err := alert.PolicyFromRule(r, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	c := &clientInfo{
+		user: &db.DbClient{
+			ClientID:          "c1",
+			UserEmail:         "user@example.com",
+			NotificationEmail: "alerts@example.com",
+			TelegramChatID:    "42",
+		},
+		routing: routing,
+		policies: map[string]alert.Policy{
+			"routed":  policy(db.DbRule{}),
+			"way":     policy(db.DbRule{NotificationWay: "TELEGRAM"}),
+			"targets": policy(db.DbRule{NotificationWay: "SLACK", Destinations: `{"email": ["a@example.com", "b@example.com"], "webhook": ["https://example.com/hook"]}`}),
+		},
+	}
+	cases := []struct {
+		ruleID   string
+		severity common.Severity
+		expected []string
+	}{
+		// the client has no slack webhook
+		{ruleID: "routed", severity: common.CRITICAL, expected: []string{"telegram:42"}},
+		{ruleID: "routed", severity: common.INFO, expected: []string{"digest:alerts@example.com"}},
+		{ruleID: "routed", severity: common.WARNING, expected: []string{"mail:alerts@example.com"}},
+		{ruleID: "way", severity: common.INFO, expected: []string{"telegram:42"}},
+		{ruleID: "targets", severity: common.CRITICAL, expected: []string{"mail:a@example.com", "mail:b@example.com", "webhook:https://example.com/hook"}},
+	}
+	for _, tc := range cases {
+		dests := c.GetNotificationDestinations(&common.RuleResult{RuleID: tc.ruleID, Severity: tc.severity})
+		if len(dests) != len(tc.expected) {
+			t.Fatalf("%s %s: expected %v, got %v", tc.ruleID, tc.severity, tc.expected, dests)
+		}
+		for idx, dest := range dests {
+			if dest.Type+":"+dest.Dest != tc.expected[idx] {
+				t.Fatalf("%s %s: expected %v, got %v", tc.ruleID, tc.severity, tc.expected, dests)
+			}
+		}
+	}
+}
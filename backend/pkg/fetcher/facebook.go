@@ -22,10 +22,30 @@ type fbRequest struct {
 	params fb.Params
 }
 
+// the action type used for conversions and purchase value
+const purchaseActionType = "purchase"
+
+// insightsMetrics are the performance fields read from the insights endpoint
+type insightsMetrics struct {
+	spend         float64
+	impressions   uint64
+	clicks        uint64
+	conversions   float64
+	purchaseValue float64
+}
+
+func (m *insightsMetrics) add(other insightsMetrics) {
+	m.spend += other.spend
+	m.impressions += other.impressions
+	m.clicks += other.clicks
+	m.conversions += other.conversions
+	m.purchaseValue += other.purchaseValue
+}
+
 type campaignInsights struct {
 	campaignID   string
 	campaignName string
-	spend        float64
+	insightsMetrics
 }
 type accountInsights struct {
 	account   accountInfo
@@ -33,7 +53,7 @@ type accountInsights struct {
 	end       time.Time
 	dateRef   time.Time
 	campaigns map[string]campaignInsights
-	spend     float64
+	insightsMetrics
 }
 
 var (
@@ -90,6 +110,7 @@ type businessManagerInfo struct {
 
 	CreatedTime        time.Time `facebook:"created_time"`
 	VerificationStatus string    `facebook:"verification_time"`
+	TimezoneName       string    `facebook:"timezone_name"`
 }
 
 type tRange struct {
@@ -109,6 +130,7 @@ type accountInfo struct {
 	Currency           string
 	CreatedTime        time.Time `facebook:"created_time"`
 	VerificationStatus string    `facebook:"verification_time"`
+	TimezoneName       string    `facebook:"timezone_name"`
 }
 
 func fetchAllPages(paging *fb.PagingResult) []fb.Result {
@@ -387,6 +409,67 @@ func getDateRef(start, end interface{}) (*time.Time, error) {
 	return nil, fmt.Errorf("the date differ")
 }
 
+// parseNumber reads a numeric field of the insights reply, the graph api
+// returns numbers as strings. Missing fields are reported as 0.
+func parseNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("unexpected numeric value %v", value)
+}
+
+// parseActionValue sums the values of the given action type in an
+// `actions` or `action_values` list.
+func parseActionValue(value interface{}, actionType string) (float64, error) {
+	actions, ok := value.([]interface{})
+	if !ok {
+		return 0, nil
+	}
+	res := float64(0)
+	for _, a := range actions {
+		action, ok := a.(map[string]interface{})
+		if !ok || action["action_type"] != actionType {
+			continue
+		}
+		v, err := parseNumber(action["value"])
+		if err != nil {
+			return 0, err
+		}
+		res += v
+	}
+	return res, nil
+}
+
+func parseInsightsMetrics(pg fb.Result) (res insightsMetrics, err error) {
+	if res.spend, err = parseNumber(pg.GetField("spend")); err != nil {
+		return res, err
+	}
+	impressions, err := parseNumber(pg.GetField("impressions"))
+	if err != nil {
+		return res, err
+	}
+	res.impressions = uint64(impressions)
+	clicks, err := parseNumber(pg.GetField("clicks"))
+	if err != nil {
+		return res, err
+	}
+	res.clicks = uint64(clicks)
+	if res.conversions, err = parseActionValue(pg.GetField("actions"), purchaseActionType); err != nil {
+		return res, err
+	}
+	if res.purchaseValue, err = parseActionValue(pg.GetField("action_values"), purchaseActionType); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
 func fetchAccountSpend(session *fb.Session, accountInfo []accountInfo, start, end time.Time) ([]accountInsights, error) {
 	g := new(errgroup.Group)
 	mx := sync.Mutex{}
@@ -403,7 +486,7 @@ func fetchAccountSpend(session *fb.Session, accountInfo []accountInfo, start, en
 				req := fbRequest{
 					bUrl: fmt.Sprintf("%s/insights", info.Id),
 					params: fb.Params{
-						"fields":     "spend,campaign_id,campaign_name", //TODO: add more fields if needed
+						"fields":     "spend,impressions,clicks,actions,action_values,campaign_id,campaign_name",
 						"time_range": fmt.Sprintf("{'since':'%s','until': '%s'}", timeRange.Since, timeRange.Until),
 						"level":      "campaign",
 						"limit":      500000,
@@ -422,10 +505,8 @@ func fetchAccountSpend(session *fb.Session, accountInfo []accountInfo, start, en
 					start:     start,
 					end:       end,
 					campaigns: make(map[string]campaignInsights),
-					spend:     float64(0),
 				}
 
-				finalSpend := float64(0)
 				data := fetchAllPages(paging)
 
 				date_ref, err := getDateRef(start.Format(time.DateOnly), end.Format(time.DateOnly))
@@ -441,20 +522,12 @@ func fetchAccountSpend(session *fb.Session, accountInfo []accountInfo, start, en
 						return err
 					}
 					toRet.dateRef = *date_ref
-					sp := pg.GetField("spend")
-					var currSpend float64
-					if sp != nil {
-						strRep, ok := sp.(string)
-						if !ok {
-							continue
-						}
-						currSpend, err = strconv.ParseFloat(strRep, 64)
-						if err != nil {
-							return err
-						}
-						finalSpend += currSpend
-
+					metrics, err := parseInsightsMetrics(pg)
+					if err != nil {
+						return err
 					}
+					toRet.add(metrics)
+
 					campaign_id := pg.GetField("campaign_id")
 					if campaign_id != nil {
 						campaign_name := pg.GetField("campaign_name")
@@ -463,20 +536,19 @@ func fetchAccountSpend(session *fb.Session, accountInfo []accountInfo, start, en
 
 							c.campaignName = campaign_name.(string)
 							c.campaignID = campaign_id.(string)
-							c.spend = currSpend
+							c.insightsMetrics = metrics
 							toRet.campaigns[campaign_id.(string)] = c
 						} else {
 							i := campaignInsights{
-								campaignID:   campaign_id.(string),
-								campaignName: campaign_name.(string),
-								spend:        currSpend,
+								campaignID:      campaign_id.(string),
+								campaignName:    campaign_name.(string),
+								insightsMetrics: metrics,
 							}
 							toRet.campaigns[campaign_id.(string)] = i
 						}
 
 					}
 				}
-				toRet.spend = finalSpend
 
 				mx.Lock()
 				res = append(res, toRet)
@@ -532,27 +604,37 @@ func facebookFetcher(accessToken, appID, appSecret string, start, end time.Time)
 		accBase.ProviderType = db.Facebook
 		accBase.Status = accInfo.Status
 		accBase.Spend = s.spend
+		accBase.Impressions = s.impressions
+		accBase.Clicks = s.clicks
+		accBase.Conversions = s.conversions
+		accBase.PurchaseValue = s.purchaseValue
 		accBase.AccountName = accInfo.Name
 		accBase.BusinessID = accInfo.Business.Id
 		accBase.BusinessName = accInfo.Business.Name
 		accBase.DateRef = s.dateRef
+		accBase.Timezone = accInfo.TimezoneName
 		accBase.NumberOfCampaigns = uint16(len(s.campaigns))
 		accBase.UpdatedAt = time.Now().UTC()
 		//TODO: add all the necessary fields
 		task.Accounts = append(task.Accounts, accBase)
 		for _, v := range s.campaigns {
 			campBase := db.DbCampaignSpend{
-				AccountID:    accBase.AccountID,
-				ProviderType: db.Facebook,
-				AccountName:  accInfo.Name,
-				BusinessID:   accInfo.Business.Id,
-				BusinessName: accInfo.Business.Name,
-				CampaignID:   v.campaignID,
-				CampaignName: v.campaignName,
-				Spend:        v.spend,
-				DateRef:      s.dateRef,
-				UpdatedAt:    time.Now().UTC(),
-				Status:       db.UnknownStatus.String(),
+				AccountID:     accBase.AccountID,
+				ProviderType:  db.Facebook,
+				AccountName:   accInfo.Name,
+				BusinessID:    accInfo.Business.Id,
+				BusinessName:  accInfo.Business.Name,
+				CampaignID:    v.campaignID,
+				CampaignName:  v.campaignName,
+				Spend:         v.spend,
+				Impressions:   v.impressions,
+				Clicks:        v.clicks,
+				Conversions:   v.conversions,
+				PurchaseValue: v.purchaseValue,
+				DateRef:       s.dateRef,
+				Timezone:      accInfo.TimezoneName,
+				UpdatedAt:     time.Now().UTC(),
+				Status:        db.UnknownStatus.String(),
 			}
 			task.Campaigns = append(task.Campaigns, campBase)
 		}
@@ -1,35 +1,437 @@
 package fetcher
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/revealbot/google-ads-go/ads"
-	"github.com/revealbot/google-ads-go/services"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+const (
+	googleAdsEndpoint   = "https://googleads.googleapis.com/v18/"
+	googleTokenEndpoint = "https://oauth2.googleapis.com/token"
 )
 
-func GoogleFetcher(accessToken string, start, end time.Time) (totalSpend uint64, err error) {
-	// Create a client from credentials file
-	ads.NewClient(&ads.GoogleAdsClientParams{})
-	client, err := ads.NewClientFromStorage("google-ads.json")
+// the hierarchy of a customer, the managers (MCC) included
+const googleCustomerClientQuery = `SELECT customer_client.id, customer_client.descriptive_name, customer_client.manager,
+	customer_client.level, customer_client.status, customer_client.time_zone, customer_client.currency_code
+	FROM customer_client WHERE customer_client.status = 'ENABLED'`
+
+const googleAccountQuery = `SELECT customer.id, customer.descriptive_name, customer.status, customer.time_zone,
+	segments.date, metrics.cost_micros, metrics.impressions, metrics.clicks, metrics.conversions, metrics.conversions_value
+	FROM customer WHERE segments.date BETWEEN '%s' AND '%s'`
+
+const googleCampaignQuery = `SELECT campaign.id, campaign.name, campaign.status,
+	segments.date, metrics.cost_micros, metrics.impressions, metrics.clicks, metrics.conversions, metrics.conversions_value
+	FROM campaign WHERE segments.date BETWEEN '%s' AND '%s'`
+
+// googleInt64 is an int64 of the Google Ads REST API, encoded as a string.
+type googleInt64 int64
+
+func (v *googleInt64) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*v = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid google ads integer %s: %w", data, err)
+	}
+	*v = googleInt64(n)
+	return nil
+}
+
+type googleCustomer struct {
+	ID              googleInt64 `json:"id"`
+	DescriptiveName string      `json:"descriptiveName"`
+	Status          string      `json:"status"`
+	TimeZone        string      `json:"timeZone"`
+}
+
+type googleCustomerClient struct {
+	ID              googleInt64 `json:"id"`
+	DescriptiveName string      `json:"descriptiveName"`
+	Manager         bool        `json:"manager"`
+	Level           googleInt64 `json:"level"`
+	Status          string      `json:"status"`
+	TimeZone        string      `json:"timeZone"`
+	CurrencyCode    string      `json:"currencyCode"`
+}
+
+type googleCampaign struct {
+	ID     googleInt64 `json:"id"`
+	Name   string      `json:"name"`
+	Status string      `json:"status"`
+}
+
+type googleMetrics struct {
+	CostMicros       googleInt64 `json:"costMicros"`
+	Impressions      googleInt64 `json:"impressions"`
+	Clicks           googleInt64 `json:"clicks"`
+	Conversions      float64     `json:"conversions"`
+	ConversionsValue float64     `json:"conversionsValue"`
+}
+
+// spend returns the cost in currency units, the API reports it in micros.
+func (m googleMetrics) spend() float64 {
+	return float64(m.CostMicros) / 1e6
+}
+
+func (m googleMetrics) empty() bool {
+	return m.CostMicros == 0 && m.Impressions == 0 && m.Clicks == 0 && m.Conversions == 0
+}
+
+type googleAdsRow struct {
+	Customer       *googleCustomer       `json:"customer"`
+	CustomerClient *googleCustomerClient `json:"customerClient"`
+	Campaign       *googleCampaign       `json:"campaign"`
+	Segments       struct {
+		Date string `json:"date"`
+	} `json:"segments"`
+	Metrics googleMetrics `json:"metrics"`
+}
+
+type googleSearchResponse struct {
+	Results       []googleAdsRow `json:"results"`
+	NextPageToken string         `json:"nextPageToken"`
+}
+
+type googleErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// googleAccount is an ad account (a customer that is not a manager), with
+// the customer it's accessed through.
+type googleAccount struct {
+	id              string
+	name            string
+	timezone        string
+	status          string
+	loginCustomerID string
+	businessID      string
+	businessName    string
+}
+
+// googleAdsAPI is a client of the REST interface of the Google Ads API.
+type googleAdsAPI struct {
+	endpoint       string
+	developerToken string
+	accessToken    string
+	client         *http.Client
+}
+
+func (g *googleAdsAPI) do(method, path, loginCustomerID string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, g.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.accessToken)
+	req.Header.Set("developer-token", g.developerToken)
+	if loginCustomerID != "" {
+		req.Header.Set("login-customer-id", loginCustomerID)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr googleErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error.Message == "" {
+			return fmt.Errorf("google ads replied with status %d", resp.StatusCode)
+		}
+		return fmt.Errorf("google ads: %s (%s)", apiErr.Error.Message, apiErr.Error.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// listAccessibleCustomers returns the ids of the customers the user of the
+// token can access directly.
+func (g *googleAdsAPI) listAccessibleCustomers() ([]string, error) {
+	var res struct {
+		ResourceNames []string `json:"resourceNames"`
+	}
+	if err := g.do(http.MethodGet, "customers:listAccessibleCustomers", "", nil, &res); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(res.ResourceNames))
+	for _, name := range res.ResourceNames {
+		ids = append(ids, strings.TrimPrefix(name, "customers/"))
+	}
+	return ids, nil
+}
+
+// search runs the GAQL query on the customer and returns the rows of every
+// page.
+func (g *googleAdsAPI) search(customerID, loginCustomerID, query string) ([]googleAdsRow, error) {
+	rows := make([]googleAdsRow, 0)
+	body := map[string]string{"query": query}
+	for {
+		var page googleSearchResponse
+		if err := g.do(http.MethodPost, fmt.Sprintf("customers/%s/googleAds:search", customerID), loginCustomerID, body, &page); err != nil {
+			return nil, err
+		}
+		rows = append(rows, page.Results...)
+		if page.NextPageToken == "" {
+			return rows, nil
+		}
+		body = map[string]string{"query": query, "pageToken": page.NextPageToken}
+	}
+}
+
+// listAccounts returns the ad accounts of the hierarchies of the accessible
+// customers. An account reachable through a manager is accessed through it,
+// and its manager is used as business.
+func (g *googleAdsAPI) listAccounts() ([]googleAccount, error) {
+	ids, err := g.listAccessibleCustomers()
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	res := make([]googleAccount, 0)
+	byID := make(map[string]int)
+	var lastErr error
+	for _, id := range ids {
+		rows, err := g.search(id, id, googleCustomerClientQuery)
+		if err != nil {
+			// the disabled customers stay accessible but cannot be queried
+			log.Warn().Err(err).Str("customer_id", id).Msg("could not list the google ads customer hierarchy")
+			lastErr = err
+			continue
+		}
+		root := googleCustomerClient{}
+		for _, r := range rows {
+			if r.CustomerClient != nil && r.CustomerClient.Level == 0 {
+				root = *r.CustomerClient
+			}
+		}
+		for _, r := range rows {
+			c := r.CustomerClient
+			if c == nil || c.Manager {
+				continue
+			}
+			account := googleAccount{
+				id:              strconv.FormatInt(int64(c.ID), 10),
+				name:            c.DescriptiveName,
+				timezone:        c.TimeZone,
+				status:          c.Status,
+				loginCustomerID: id,
+				businessID:      id,
+				businessName:    root.DescriptiveName,
+			}
+			idx, ok := byID[account.id]
+			if !ok {
+				byID[account.id] = len(res)
+				res = append(res, account)
+				continue
+			}
+			if res[idx].loginCustomerID == res[idx].id && root.Manager {
+				res[idx] = account
+			}
+		}
 	}
+	if len(res) == 0 && lastErr != nil {
+		// none of the customers could be listed, the credentials are wrong
+		return nil, lastErr
+	}
+	return res, nil
+}
 
-	// Load the "GoogleAds" service
-	googleAdsService := services.NewGoogleAdsServiceClient(client.Conn())
+// refreshGoogleToken exchanges the refresh token of the provider for an
+// access token.
+func refreshGoogleToken(client *http.Client, tokenEndpoint, clientID, clientSecret, refreshToken string) (string, error) {
+	resp, err := client.PostForm(tokenEndpoint, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("could not decode the google token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("could not refresh the google token: %s %s", token.Error, token.ErrorDescription)
+	}
+	return token.AccessToken, nil
+}
 
-	// Create a search request
-	request := services.SearchGoogleAdsRequest{
-		CustomerId: "2984242032",
-		Query:      "SELECT campaign.id, campaign.name FROM campaign ORDER BY campaign.id",
+func googleStatus(status string) string {
+	switch status {
+	case "ENABLED":
+		return db.Active.String()
+	case "":
+		return db.UnknownStatus.String()
 	}
+	return db.Inactive.String()
+}
 
-	// Get the results
-	response, err := googleAdsService.Search(client.Context(), &request)
-	for _, row := range response.Results {
-		campaign := row.Campaign
-		fmt.Printf("id: %d, name: %s\n", campaign.Id, *campaign.Name)
+// newGoogleFetcher returns the fetcher of the Google Ads API. The access
+// token of the provider is the OAuth refresh token, the app id and secret
+// are the ones of the OAuth client.
+func newGoogleFetcher(endpoint, tokenEndpoint, developerToken string) fetchFunc {
+	return func(refreshToken, clientID, clientSecret string, start, end time.Time) (*common.FetchTask, error) {
+		task := common.NewFetchTask(start, end)
+		httpClient := &http.Client{Timeout: 60 * time.Second}
+		accessToken, err := refreshGoogleToken(httpClient, tokenEndpoint, clientID, clientSecret, refreshToken)
+		if err != nil {
+			return task, err
+		}
+		api := &googleAdsAPI{
+			endpoint:       endpoint,
+			developerToken: developerToken,
+			accessToken:    accessToken,
+			client:         httpClient,
+		}
+		accounts, err := api.listAccounts()
+		if err != nil {
+			return task, err
+		}
+
+		since, until := start.Format(time.DateOnly), end.Format(time.DateOnly)
+		g := new(errgroup.Group)
+		g.SetLimit(5)
+		for _, account := range accounts {
+			g.Go(func() error {
+				accRows, err := api.search(account.id, account.loginCustomerID, fmt.Sprintf(googleAccountQuery, since, until))
+				if err != nil {
+					return fmt.Errorf("account %s: %w", account.id, err)
+				}
+				campRows, err := api.search(account.id, account.loginCustomerID, fmt.Sprintf(googleCampaignQuery, since, until))
+				if err != nil {
+					return fmt.Errorf("account %s: %w", account.id, err)
+				}
+				accountsSpend, campaignsSpend, err := googleSpend(account, accRows, campRows, start, end)
+				if err != nil {
+					return fmt.Errorf("account %s: %w", account.id, err)
+				}
+				task.Lock()
+				task.Accounts = append(task.Accounts, accountsSpend...)
+				task.Campaigns = append(task.Campaigns, campaignsSpend...)
+				task.Unlock()
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return task, err
+		}
+		return task, nil
+	}
+}
+
+// googleSpend converts the rows of the account and campaign queries into
+// one row per day of the account and of each delivering campaign. The
+// queries leave out the days without metrics, the account has a zero row
+// for them.
+func googleSpend(account googleAccount, accRows, campRows []googleAdsRow, start, end time.Time) ([]db.DbAccountSpend, []db.DbCampaignSpend, error) {
+	now := time.Now().UTC()
+	campaigns := make([]db.DbCampaignSpend, 0, len(campRows))
+	campaignsByDay := make(map[string]uint16)
+	for _, r := range campRows {
+		if r.Campaign == nil || r.Metrics.empty() {
+			continue
+		}
+		dateRef, err := time.Parse(time.DateOnly, r.Segments.Date)
+		if err != nil {
+			return nil, nil, err
+		}
+		campaignsByDay[r.Segments.Date]++
+		campaigns = append(campaigns, db.DbCampaignSpend{
+			AccountID:     account.id,
+			AccountName:   account.name,
+			BusinessID:    account.businessID,
+			BusinessName:  account.businessName,
+			CampaignID:    strconv.FormatInt(int64(r.Campaign.ID), 10),
+			CampaignName:  r.Campaign.Name,
+			ProviderType:  db.Google,
+			Status:        googleStatus(r.Campaign.Status),
+			Spend:         r.Metrics.spend(),
+			Impressions:   uint64(r.Metrics.Impressions),
+			Clicks:        uint64(r.Metrics.Clicks),
+			Conversions:   r.Metrics.Conversions,
+			PurchaseValue: r.Metrics.ConversionsValue,
+			Timezone:      account.timezone,
+			DateRef:       dateRef,
+			UpdatedAt:     now,
+		})
+	}
+
+	accounts := make([]db.DbAccountSpend, 0, len(accRows))
+	byDay := make(map[time.Time]int)
+	addDay := func(dateRef time.Time) int {
+		byDay[dateRef] = len(accounts)
+		accounts = append(accounts, db.DbAccountSpend{
+			AccountID:    account.id,
+			AccountName:  account.name,
+			BusinessID:   account.businessID,
+			BusinessName: account.businessName,
+			ProviderType: db.Google,
+			Status:       googleStatus(account.status),
+			Timezone:     account.timezone,
+			DateRef:      dateRef,
+			UpdatedAt:    now,
+		})
+		return byDay[dateRef]
+	}
+	for _, dateRef := range periodDays(start, end) {
+		addDay(dateRef)
+	}
+	for _, r := range accRows {
+		dateRef, err := time.Parse(time.DateOnly, r.Segments.Date)
+		if err != nil {
+			return nil, nil, err
+		}
+		idx, ok := byDay[dateRef]
+		if !ok {
+			idx = addDay(dateRef)
+		}
+		acc := &accounts[idx]
+		acc.Spend = r.Metrics.spend()
+		acc.Impressions = uint64(r.Metrics.Impressions)
+		acc.Clicks = uint64(r.Metrics.Clicks)
+		acc.Conversions = r.Metrics.Conversions
+		acc.PurchaseValue = r.Metrics.ConversionsValue
+		acc.NumberOfCampaigns = campaignsByDay[r.Segments.Date]
+		if r.Customer != nil {
+			acc.Status = googleStatus(r.Customer.Status)
+			if r.Customer.TimeZone != "" {
+				acc.Timezone = r.Customer.TimeZone
+			}
+		}
 	}
-	return totalSpend, fmt.Errorf("UNIMPLEMENTED")
+	return accounts, campaigns, nil
 }
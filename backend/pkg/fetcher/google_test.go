@@ -0,0 +1,167 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// googleAdsServer stands in for the Google Ads REST API: the manager 111
+// manages the accounts 222 and 333, and 222 is also directly accessible.
+func googleAdsServer(t *testing.T) *httptest.Server {
+	hierarchies := map[string]string{
+		"111": `{"results":[
+			{"customerClient":{"id":"111","descriptiveName":"Agency MCC","manager":true,"level":"0","status":"ENABLED"}},
+			{"customerClient":{"id":"222","descriptiveName":"Shop","manager":false,"level":"1","status":"ENABLED","timeZone":"Europe/Rome"}},
+			{"customerClient":{"id":"333","descriptiveName":"Blog","manager":false,"level":"1","status":"ENABLED","timeZone":"Europe/Rome"}}
+		]}`,
+		"222": `{"results":[
+			{"customerClient":{"id":"222","descriptiveName":"Shop","manager":false,"level":"0","status":"ENABLED","timeZone":"Europe/Rome"}}
+		]}`,
+	}
+	accounts := map[string]string{
+		"222": `{"results":[
+			{"customer":{"id":"222","descriptiveName":"Shop","status":"ENABLED","timeZone":"Europe/Rome"},"segments":{"date":"2024-05-19"},
+			 "metrics":{"costMicros":"12340000","impressions":"1000","clicks":"40","conversions":2,"conversionsValue":150.5}},
+			{"customer":{"id":"222","descriptiveName":"Shop","status":"ENABLED","timeZone":"Europe/Rome"},"segments":{"date":"2024-05-20"},
+			 "metrics":{"costMicros":"5000000","impressions":"300","clicks":"10"}}
+		]}`,
+		"333": `{"results":[]}`,
+	}
+	campaignPages := map[string][]string{
+		"222": {
+			`{"results":[
+				{"campaign":{"id":"9001","name":"Search","status":"ENABLED"},"segments":{"date":"2024-05-19"},
+				 "metrics":{"costMicros":"10000000","impressions":"800","clicks":"30","conversions":2,"conversionsValue":150.5}},
+				{"campaign":{"id":"9002","name":"Display","status":"PAUSED"},"segments":{"date":"2024-05-19"},
+				 "metrics":{"costMicros":"2340000","impressions":"200","clicks":"10"}}
+			],"nextPageToken":"page2"}`,
+			`{"results":[
+				{"campaign":{"id":"9001","name":"Search","status":"ENABLED"},"segments":{"date":"2024-05-20"},
+				 "metrics":{"costMicros":"5000000","impressions":"300","clicks":"10"}},
+				{"campaign":{"id":"9003","name":"Old","status":"REMOVED"},"segments":{"date":"2024-05-20"},"metrics":{}}
+			]}`,
+		},
+		"333": {`{}`},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("refresh_token") != "refresh" || r.PostForm.Get("client_id") != "oauth-id" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Bad Request"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"access","expires_in":3599}`)
+	})
+	mux.HandleFunc("/customers:listAccessibleCustomers", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"resourceNames":["customers/222","customers/111"]}`)
+	})
+	mux.HandleFunc("/customers/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" || r.Header.Get("developer-token") != "dev" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"code":401,"message":"Request is missing required authentication credential.","status":"UNAUTHENTICATED"}}`)
+			return
+		}
+		customerID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/customers/"), "/googleAds:search")
+		var body struct {
+			Query     string `json:"query"`
+			PageToken string `json:"pageToken"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid search body: %v", err)
+		}
+		login := r.Header.Get("login-customer-id")
+		switch {
+		case strings.Contains(body.Query, "FROM customer_client"):
+			if login != customerID {
+				t.Errorf("hierarchy of %s queried through %s", customerID, login)
+			}
+			fmt.Fprint(w, hierarchies[customerID])
+			return
+		case login != "111":
+			t.Errorf("account %s queried through %s instead of its manager", customerID, login)
+		case !strings.Contains(body.Query, "BETWEEN '2024-05-19' AND '2024-05-20'"):
+			t.Errorf("unexpected period in %s", body.Query)
+		}
+		if strings.Contains(body.Query, "FROM campaign") {
+			page := 0
+			if body.PageToken == "page2" {
+				page = 1
+			}
+			fmt.Fprint(w, campaignPages[customerID][page])
+			return
+		}
+		fmt.Fprint(w, accounts[customerID])
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestGoogleFetcher(t *testing.T) {
+	server := googleAdsServer(t)
+	defer server.Close()
+
+	fetch := newGoogleFetcher(server.URL+"/", server.URL+"/token", "dev")
+	start := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	task, err := fetch("refresh", "oauth-id", "oauth-secret", start, start.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the accounts have a row for every day, with or without delivery
+	if len(task.Accounts) != 4 {
+		t.Fatalf("expected 4 account rows, got %d", len(task.Accounts))
+	}
+	accounts := accountsByDay(task.Accounts)
+	first := accounts["222|2024-05-19"]
+	if first.AccountID != "222" || first.BusinessID != "111" || first.BusinessName != "Agency MCC" ||
+		first.Status != "ACTIVE" || first.Timezone != "Europe/Rome" || first.ProviderType.String() != "GOOGLE" {
+		t.Fatalf("unexpected account %+v", first)
+	}
+	if math.Abs(first.Spend-12.34) > 1e-9 || first.Impressions != 1000 || first.Clicks != 40 ||
+		first.Conversions != 2 || first.PurchaseValue != 150.5 || first.NumberOfCampaigns != 2 ||
+		!first.DateRef.Equal(start) {
+		t.Fatalf("unexpected account metrics %+v", first)
+	}
+	if acc := accounts["222|2024-05-20"]; acc.Spend != 5 || acc.NumberOfCampaigns != 1 {
+		t.Fatalf("unexpected account metrics %+v", acc)
+	}
+	// the account without delivery
+	for _, day := range []string{"2024-05-19", "2024-05-20"} {
+		acc, ok := accounts["333|"+day]
+		if !ok || acc.AccountName != "Blog" || acc.BusinessID != "111" || acc.Spend != 0 || acc.NumberOfCampaigns != 0 ||
+			acc.Status != "ACTIVE" || acc.Timezone != "Europe/Rome" {
+			t.Fatalf("unexpected account without delivery %+v", acc)
+		}
+	}
+
+	// the campaign without metrics is not reported
+	if len(task.Campaigns) != 3 {
+		t.Fatalf("expected 3 campaign rows, got %d", len(task.Campaigns))
+	}
+	display := task.Campaigns[1]
+	if display.CampaignID != "9002" || display.CampaignName != "Display" || display.Status != "INACTIVE" ||
+		math.Abs(display.Spend-2.34) > 1e-9 || display.AccountID != "222" || display.BusinessID != "111" {
+		t.Fatalf("unexpected campaign %+v", display)
+	}
+}
+
+func TestGoogleFetcherErrors(t *testing.T) {
+	server := googleAdsServer(t)
+	defer server.Close()
+
+	start := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	fetch := newGoogleFetcher(server.URL+"/", server.URL+"/token", "dev")
+	if _, err := fetch("expired", "oauth-id", "oauth-secret", start, start); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("expected a token error, got %v", err)
+	}
+	fetch = newGoogleFetcher(server.URL+"/", server.URL+"/token", "wrong")
+	// every hierarchy is refused
+	if _, err := fetch("refresh", "oauth-id", "oauth-secret", start, start); err == nil || !strings.Contains(err.Error(), "UNAUTHENTICATED") {
+		t.Fatalf("expected an authentication error, got %v", err)
+	}
+}
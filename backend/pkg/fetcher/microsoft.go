@@ -0,0 +1,568 @@
+package fetcher
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+// microsoftEndpoints are the services of the Microsoft Advertising API.
+type microsoftEndpoints struct {
+	token     string
+	customer  string
+	reporting string
+}
+
+var microsoftProduction = microsoftEndpoints{
+	token:     "https://login.microsoftonline.com/common/oauth2/v2.0/token",
+	customer:  "https://clientcenter.api.bingads.microsoft.com/CustomerManagement/v13/",
+	reporting: "https://reporting.api.bingads.microsoft.com/Reporting/v13/",
+}
+
+const microsoftScope = "https://ads.microsoft.com/msads.manage offline_access"
+
+// microsoftPollAttempts bounds the time waited for a report to be generated.
+const microsoftPollAttempts = 60
+
+// microsoftReportColumns are the columns of the daily campaign performance
+// report, the account rows are the sum of their campaigns.
+var microsoftReportColumns = []string{
+	"TimePeriod", "AccountId", "AccountName", "CampaignId", "CampaignName", "CampaignStatus",
+	"Spend", "Impressions", "Clicks", "Conversions", "Revenue",
+}
+
+type microsoftAccount struct {
+	ID                     int64  `json:"Id"`
+	Name                   string `json:"Name"`
+	ParentCustomerID       int64  `json:"ParentCustomerId"`
+	AccountLifeCycleStatus string `json:"AccountLifeCycleStatus"`
+	TimeZone               string `json:"TimeZone"`
+}
+
+// microsoftFault is the body of the errors of the API.
+type microsoftFault struct {
+	Message string `json:"Message"`
+	Errors  []struct {
+		ErrorCode string `json:"ErrorCode"`
+		Message   string `json:"Message"`
+	} `json:"Errors"`
+	OperationErrors []struct {
+		ErrorCode string `json:"ErrorCode"`
+		Message   string `json:"Message"`
+	} `json:"OperationErrors"`
+}
+
+func (f microsoftFault) Error() string {
+	for _, e := range append(f.Errors, f.OperationErrors...) {
+		return fmt.Sprintf("microsoft advertising: %s (%s)", e.Message, e.ErrorCode)
+	}
+	return fmt.Sprintf("microsoft advertising: %s", f.Message)
+}
+
+type microsoftDate struct {
+	Day   int `json:"Day"`
+	Month int `json:"Month"`
+	Year  int `json:"Year"`
+}
+
+func newMicrosoftDate(t time.Time) microsoftDate {
+	return microsoftDate{Day: t.Day(), Month: int(t.Month()), Year: t.Year()}
+}
+
+// microsoftAPI is a client of the REST interface of the Microsoft
+// Advertising API.
+type microsoftAPI struct {
+	endpoints      microsoftEndpoints
+	developerToken string
+	accessToken    string
+	pollInterval   time.Duration
+	client         *http.Client
+}
+
+// authenticate exchanges the refresh token for an access token.
+func (m *microsoftAPI) authenticate(clientID, clientSecret, refreshToken string) error {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {clientID},
+		"refresh_token": {refreshToken},
+		"scope":         {microsoftScope},
+	}
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+	resp, err := m.client.PostForm(m.endpoints.token, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("could not decode the microsoft token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return fmt.Errorf("could not refresh the microsoft token: %s %s", token.Error, token.ErrorDescription)
+	}
+	m.accessToken = token.AccessToken
+	return nil
+}
+
+func (m *microsoftAPI) post(u, customerID string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+	req.Header.Set("DeveloperToken", m.developerToken)
+	req.Header.Set("Content-Type", "application/json")
+	if customerID != "" {
+		req.Header.Set("CustomerId", customerID)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var fault microsoftFault
+		if err := json.NewDecoder(resp.Body).Decode(&fault); err != nil || (fault.Message == "" && len(fault.Errors)+len(fault.OperationErrors) == 0) {
+			return fmt.Errorf("microsoft advertising replied with status %d", resp.StatusCode)
+		}
+		return fault
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// listAccounts returns the accounts the user of the token can access, in
+// every customer.
+func (m *microsoftAPI) listAccounts() ([]microsoftAccount, error) {
+	var user struct {
+		User struct {
+			ID int64 `json:"Id"`
+		} `json:"User"`
+	}
+	if err := m.post(m.endpoints.customer+"User/Query", "", map[string]any{"UserId": nil}, &user); err != nil {
+		return nil, err
+	}
+	res := make([]microsoftAccount, 0)
+	for page := 0; ; page++ {
+		var accounts struct {
+			Accounts []microsoftAccount `json:"Accounts"`
+		}
+		body := map[string]any{
+			"Predicates": []map[string]string{{"Field": "UserId", "Operator": "Equals", "Value": strconv.FormatInt(user.User.ID, 10)}},
+			"PageInfo":   map[string]int{"Index": page, "Size": 1000},
+		}
+		if err := m.post(m.endpoints.customer+"Accounts/Search", "", body, &accounts); err != nil {
+			return nil, err
+		}
+		res = append(res, accounts.Accounts...)
+		if len(accounts.Accounts) < 1000 {
+			return res, nil
+		}
+	}
+}
+
+// submitReport submits the daily campaign performance report of the
+// accounts of a customer and returns its id.
+func (m *microsoftAPI) submitReport(customerID string, accountIDs []int64, start, end time.Time) (string, error) {
+	request := map[string]any{
+		"ReportRequest": map[string]any{
+			"Type":                   "CampaignPerformanceReportRequest",
+			"Format":                 "Csv",
+			"ReportName":             "ads-zero campaign performance",
+			"ReturnOnlyCompleteData": false,
+			"ExcludeReportHeader":    true,
+			"ExcludeReportFooter":    true,
+			"Aggregation":            "Daily",
+			"Columns":                microsoftReportColumns,
+			"Scope":                  map[string]any{"AccountIds": accountIDs},
+			"Time": map[string]any{
+				"CustomDateRangeStart": newMicrosoftDate(start),
+				"CustomDateRangeEnd":   newMicrosoftDate(end),
+			},
+		},
+	}
+	var res struct {
+		ReportRequestID string `json:"ReportRequestId"`
+	}
+	if err := m.post(m.endpoints.reporting+"GenerateReport/Submit", customerID, request, &res); err != nil {
+		return "", err
+	}
+	return res.ReportRequestID, nil
+}
+
+// pollReport waits for the report to be generated and returns its download
+// url, empty when the report has no rows.
+func (m *microsoftAPI) pollReport(customerID, reportID string) (string, error) {
+	for attempt := 0; attempt < microsoftPollAttempts; attempt++ {
+		var res struct {
+			ReportRequestStatus struct {
+				Status            string `json:"Status"`
+				ReportDownloadURL string `json:"ReportDownloadUrl"`
+			} `json:"ReportRequestStatus"`
+		}
+		if err := m.post(m.endpoints.reporting+"GenerateReport/Poll", customerID, map[string]string{"ReportRequestId": reportID}, &res); err != nil {
+			return "", err
+		}
+		switch res.ReportRequestStatus.Status {
+		case "Success":
+			return res.ReportRequestStatus.ReportDownloadURL, nil
+		case "Pending":
+			time.Sleep(m.pollInterval)
+		default:
+			return "", fmt.Errorf("report %s failed with status %s", reportID, res.ReportRequestStatus.Status)
+		}
+	}
+	return "", fmt.Errorf("report %s is still pending", reportID)
+}
+
+// downloadReport downloads the zipped CSV of the report and returns its
+// rows by column name.
+func (m *microsoftAPI) downloadReport(downloadURL string) ([]map[string]string, error) {
+	resp, err := m.client.Get(downloadURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not download the report, status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid report archive: %w", err)
+	}
+	if len(archive.File) == 0 {
+		return nil, fmt.Errorf("empty report archive")
+	}
+	f, err := archive.File[0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMicrosoftReport(f)
+}
+
+// parseMicrosoftReport reads the CSV of a report, which starts with a byte
+// order mark.
+func parseMicrosoftReport(r io.Reader) ([]map[string]string, error) {
+	buf := bufio.NewReader(r)
+	if bom, err := buf.Peek(3); err == nil && bytes.Equal(bom, []byte("\ufeff")) {
+		buf.Discard(3)
+	}
+	reader := csv.NewReader(buf)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid report csv: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for idx, name := range header {
+			if idx < len(record) {
+				row[name] = record[idx]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func microsoftStatus(status string) string {
+	switch status {
+	case "Active":
+		return db.Active.String()
+	case "":
+		return db.UnknownStatus.String()
+	}
+	return db.Inactive.String()
+}
+
+// microsoftTimezones are the IANA names of the time zones of the accounts.
+var microsoftTimezones = map[string]string{
+	"AbuDhabiMuscat":                         "Asia/Dubai",
+	"Adelaide":                               "Australia/Adelaide",
+	"Alaska":                                 "America/Anchorage",
+	"AlmatyNovosibirsk":                      "Asia/Almaty",
+	"AmsterdamBerlinBernRomeStockholmVienna": "Europe/Berlin",
+	"Arizona":                                "America/Phoenix",
+	"AstanaDhaka":                            "Asia/Dhaka",
+	"AthensBuckarestIstanbul":                "Europe/Athens",
+	"AtlanticTimeCanada":                     "America/Halifax",
+	"AucklandWellington":                     "Pacific/Auckland",
+	"Azores":                                 "Atlantic/Azores",
+	"Baghdad":                                "Asia/Baghdad",
+	"BakuTbilisiYerevan":                     "Asia/Baku",
+	"BangkokHanoiJakarta":                    "Asia/Bangkok",
+	"BeijingChongqingHongKongUrumqi":         "Asia/Shanghai",
+	"BelgradeBratislavaBudapestLjubljanaPrague": "Europe/Budapest",
+	"BogotaLimaQuito":               "America/Bogota",
+	"Brasilia":                      "America/Sao_Paulo",
+	"Brisbane":                      "Australia/Brisbane",
+	"BrusselsCopenhagenMadridParis": "Europe/Paris",
+	"Bucharest":                     "Europe/Bucharest",
+	"BuenosAiresGeorgetown":         "America/Argentina/Buenos_Aires",
+	"Cairo":                         "Africa/Cairo",
+	"CanberraMelbourneSydney":       "Australia/Sydney",
+	"CapeVerdeIsland":               "Atlantic/Cape_Verde",
+	"CaracasLaPaz":                  "America/Caracas",
+	"CasablancaMonrovia":            "Africa/Casablanca",
+	"CentralAmerica":                "America/Guatemala",
+	"CentralTimeUSCanada":           "America/Chicago",
+	"ChennaiKolkataMumbaiNewDelhi":  "Asia/Kolkata",
+	"ChihuahuaLaPazMazatlan":        "America/Chihuahua",
+	"Darwin":                        "Australia/Darwin",
+	"EasternTimeUSCanada":           "America/New_York",
+	"Ekaterinburg":                  "Asia/Yekaterinburg",
+	"FijiKamchatkaMarshallIsland":   "Pacific/Fiji",
+	"Greenland":                     "America/Nuuk",
+	"GreenwichMeanTimeDublinEdinburghLisbonLondon": "Europe/London",
+	"GuadalajaraMexicoCityMonterrey":               "America/Mexico_City",
+	"GuamPortMoresby":                              "Pacific/Guam",
+	"HararePretoria":                               "Africa/Johannesburg",
+	"Hawaii":                                       "Pacific/Honolulu",
+	"HelsinkiKyivRigaSofiaTallinnVilnius":          "Europe/Helsinki",
+	"Hobart":                                       "Australia/Hobart",
+	"IndianaEast":                                  "America/Indiana/Indianapolis",
+	"InternationalDateLineWest":                    "Etc/GMT+12",
+	"IrkutskUlaanBataar":                           "Asia/Irkutsk",
+	"IslamabadKarachiTashkent":                     "Asia/Karachi",
+	"Jerusalem":                                    "Asia/Jerusalem",
+	"Kabul":                                        "Asia/Kabul",
+	"Kathmandu":                                    "Asia/Kathmandu",
+	"Krasnoyarsk":                                  "Asia/Krasnoyarsk",
+	"KualaLumpurSingapore":                         "Asia/Singapore",
+	"KuwaitRiyadh":                                 "Asia/Riyadh",
+	"MagadanSolomonIslandNewCaledonia":             "Asia/Magadan",
+	"MidAtlantic":                                  "Atlantic/South_Georgia",
+	"MidwayIslandAndSamoa":                         "Pacific/Pago_Pago",
+	"MoscowStPetersburgVolgograd":                  "Europe/Moscow",
+	"MountainTimeUSCanada":                         "America/Denver",
+	"Nairobi":                                      "Africa/Nairobi",
+	"Newfoundland":                                 "America/St_Johns",
+	"Nukualofa":                                    "Pacific/Tongatapu",
+	"OsakaSapporoTokyo":                            "Asia/Tokyo",
+	"PacificTimeUSCanadaTijuana":                   "America/Los_Angeles",
+	"Perth":                                        "Australia/Perth",
+	"Rangoon":                                      "Asia/Yangon",
+	"Santiago":                                     "America/Santiago",
+	"SarajevoSkopjeWarsawZagreb":                   "Europe/Warsaw",
+	"Saskatchewan":                                 "America/Regina",
+	"Seoul":                                        "Asia/Seoul",
+	"SriJayawardenepura":                           "Asia/Colombo",
+	"Taipei":                                       "Asia/Taipei",
+	"Tehran":                                       "Asia/Tehran",
+	"Vladivostok":                                  "Asia/Vladivostok",
+	"WestCentralAfrica":                            "Africa/Lagos",
+	"Yakutsk":                                      "Asia/Yakutsk",
+}
+
+// microsoftTimezone returns the IANA name of the time zone of an account,
+// UTC when it is unknown.
+func microsoftTimezone(timeZone string) string {
+	if name, ok := microsoftTimezones[timeZone]; ok {
+		return name
+	}
+	return "UTC"
+}
+
+// newMicrosoftFetcher returns the fetcher of the Microsoft Advertising API.
+// The access token of the provider is the OAuth refresh token, the app id
+// and secret are the ones of the OAuth client.
+func newMicrosoftFetcher(endpoints microsoftEndpoints, developerToken string, pollInterval time.Duration) fetchFunc {
+	return func(refreshToken, clientID, clientSecret string, start, end time.Time) (*common.FetchTask, error) {
+		task := common.NewFetchTask(start, end)
+		api := &microsoftAPI{
+			endpoints:      endpoints,
+			developerToken: developerToken,
+			pollInterval:   pollInterval,
+			client:         &http.Client{Timeout: 60 * time.Second},
+		}
+		if err := api.authenticate(clientID, clientSecret, refreshToken); err != nil {
+			return task, err
+		}
+		accounts, err := api.listAccounts()
+		if err != nil {
+			return task, err
+		}
+
+		// one report per customer, with the accounts of the customer
+		customers := make([]int64, 0)
+		byCustomer := make(map[int64][]microsoftAccount)
+		for _, account := range accounts {
+			if _, ok := byCustomer[account.ParentCustomerID]; !ok {
+				customers = append(customers, account.ParentCustomerID)
+			}
+			byCustomer[account.ParentCustomerID] = append(byCustomer[account.ParentCustomerID], account)
+		}
+		for _, customer := range customers {
+			customerID := strconv.FormatInt(customer, 10)
+			ids := make([]int64, 0, len(byCustomer[customer]))
+			for _, account := range byCustomer[customer] {
+				ids = append(ids, account.ID)
+			}
+			reportID, err := api.submitReport(customerID, ids, start, end)
+			if err != nil {
+				return task, fmt.Errorf("customer %s: %w", customerID, err)
+			}
+			downloadURL, err := api.pollReport(customerID, reportID)
+			if err != nil {
+				return task, fmt.Errorf("customer %s: %w", customerID, err)
+			}
+			// without a download url the report has no rows
+			var rows []map[string]string
+			if downloadURL != "" {
+				rows, err = api.downloadReport(downloadURL)
+				if err != nil {
+					return task, fmt.Errorf("customer %s: %w", customerID, err)
+				}
+			}
+			accountsSpend, campaignsSpend, err := microsoftSpend(customerID, byCustomer[customer], rows, start, end)
+			if err != nil {
+				return task, fmt.Errorf("customer %s: %w", customerID, err)
+			}
+			task.Accounts = append(task.Accounts, accountsSpend...)
+			task.Campaigns = append(task.Campaigns, campaignsSpend...)
+		}
+		return task, nil
+	}
+}
+
+// parseMicrosoftDay parses the time period of a daily report.
+func parseMicrosoftDay(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("1/2/2006", value)
+}
+
+func parseMicrosoftNumber(row map[string]string, column string) (float64, error) {
+	value := strings.ReplaceAll(strings.TrimSpace(row[column]), ",", "")
+	if value == "" || value == "--" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", column, row[column])
+	}
+	return f, nil
+}
+
+// microsoftSpend converts the rows of the report into one row per day of
+// each delivering campaign, and their sum per day for the account. Every
+// account of the customer has a row for every day of the period, without
+// spend when none of its campaigns delivered.
+func microsoftSpend(customerID string, accounts []microsoftAccount, rows []map[string]string, start, end time.Time) ([]db.DbAccountSpend, []db.DbCampaignSpend, error) {
+	now := time.Now().UTC()
+	campaigns := make([]db.DbCampaignSpend, 0, len(rows))
+	accountsSpend := make([]db.DbAccountSpend, 0, len(accounts))
+	byDay := make(map[string]int)
+	timezones := make(map[string]string, len(accounts))
+	addDay := func(account microsoftAccount, dateRef time.Time) int {
+		accountID := strconv.FormatInt(account.ID, 10)
+		k := accountID + "|" + dateRef.Format(time.DateOnly)
+		byDay[k] = len(accountsSpend)
+		accountsSpend = append(accountsSpend, db.DbAccountSpend{
+			AccountID:    accountID,
+			AccountName:  account.Name,
+			BusinessID:   customerID,
+			ProviderType: db.Microsoft,
+			Status:       microsoftStatus(account.AccountLifeCycleStatus),
+			Timezone:     timezones[accountID],
+			DateRef:      dateRef,
+			UpdatedAt:    now,
+		})
+		return byDay[k]
+	}
+	for _, account := range accounts {
+		timezones[strconv.FormatInt(account.ID, 10)] = microsoftTimezone(account.TimeZone)
+		for _, dateRef := range periodDays(start, end) {
+			addDay(account, dateRef)
+		}
+	}
+
+	for _, row := range rows {
+		dateRef, err := parseMicrosoftDay(row["TimePeriod"])
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid time period %q", row["TimePeriod"])
+		}
+		values := make([]float64, 0, 5)
+		for _, column := range []string{"Spend", "Impressions", "Clicks", "Conversions", "Revenue"} {
+			v, err := parseMicrosoftNumber(row, column)
+			if err != nil {
+				return nil, nil, err
+			}
+			values = append(values, v)
+		}
+		spend, impressions, clicks, conversions, revenue := values[0], values[1], values[2], values[3], values[4]
+		if spend == 0 && impressions == 0 && clicks == 0 && conversions == 0 {
+			continue
+		}
+		accountID := row["AccountId"]
+		timezone, ok := timezones[accountID]
+		if !ok {
+			timezone = "UTC"
+			timezones[accountID] = timezone
+		}
+		campaigns = append(campaigns, db.DbCampaignSpend{
+			AccountID:     accountID,
+			AccountName:   row["AccountName"],
+			BusinessID:    customerID,
+			CampaignID:    row["CampaignId"],
+			CampaignName:  row["CampaignName"],
+			ProviderType:  db.Microsoft,
+			Status:        microsoftStatus(row["CampaignStatus"]),
+			Spend:         spend,
+			Impressions:   uint64(impressions),
+			Clicks:        uint64(clicks),
+			Conversions:   conversions,
+			PurchaseValue: revenue,
+			Timezone:      timezone,
+			DateRef:       dateRef,
+			UpdatedAt:     now,
+		})
+
+		idx, ok := byDay[accountID+"|"+dateRef.Format(time.DateOnly)]
+		if !ok {
+			id, err := strconv.ParseInt(accountID, 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid account id %q", accountID)
+			}
+			idx = addDay(microsoftAccount{ID: id, Name: row["AccountName"]}, dateRef)
+		}
+		accountsSpend[idx].Spend += spend
+		accountsSpend[idx].Impressions += uint64(impressions)
+		accountsSpend[idx].Clicks += uint64(clicks)
+		accountsSpend[idx].Conversions += conversions
+		accountsSpend[idx].PurchaseValue += revenue
+		accountsSpend[idx].NumberOfCampaigns++
+	}
+	return accountsSpend, campaigns, nil
+}
@@ -0,0 +1,213 @@
+package fetcher
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// microsoftReport is the CSV of the campaign performance report, as the
+// Reporting service writes it.
+const microsoftReport = "\ufeff\"TimePeriod\",\"AccountId\",\"AccountName\",\"CampaignId\",\"CampaignName\",\"CampaignStatus\",\"Spend\",\"Impressions\",\"Clicks\",\"Conversions\",\"Revenue\"\r\n" +
+	"\"2024-05-19\",\"180001\",\"Shop\",\"601\",\"Search\",\"Active\",\"30.25\",\"1,200\",\"45\",\"3\",\"120.00\"\r\n" +
+	"\"2024-05-19\",\"180001\",\"Shop\",\"602\",\"Shopping\",\"Paused\",\"9.75\",\"800\",\"15\",\"1\",\"40.00\"\r\n" +
+	"\"2024-05-20\",\"180001\",\"Shop\",\"601\",\"Search\",\"Active\",\"12.00\",\"500\",\"20\",\"0\",\"0.00\"\r\n" +
+	"\"2024-05-20\",\"180001\",\"Shop\",\"602\",\"Shopping\",\"Paused\",\"0.00\",\"0\",\"0\",\"0\",\"0.00\"\r\n"
+
+// microsoftServer stands in for the Microsoft Advertising API: the user can
+// access the accounts 180001 and 180002 of the customer 9100, the report of
+// 180002 is empty.
+func microsoftServer(t *testing.T) *httptest.Server {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	f, err := zw.Create("report.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(microsoftReport))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	polls := 0
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("refresh_token") != "refresh" || r.PostForm.Get("client_id") != "oauth-id" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"AADSTS70000: The provided grant has expired."}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"access","expires_in":3599}`)
+	})
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer access" || r.Header.Get("DeveloperToken") != "dev" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"TrackingId":"t","Type":"AdApiFaultDetail","Errors":[{"Code":105,"ErrorCode":"InvalidCredentials","Message":"Authentication failed."}]}`)
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("/customer/User/Query", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			fmt.Fprint(w, `{"User":{"Id":5001,"UserName":"agency"}}`)
+		}
+	})
+	mux.HandleFunc("/customer/Accounts/Search", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		if body, _ := readBody(r); !strings.Contains(body, `"Value":"5001"`) {
+			t.Errorf("accounts not searched by user: %s", body)
+		}
+		fmt.Fprint(w, `{"Accounts":[
+			{"Id":180001,"Name":"Shop","ParentCustomerId":9100,"AccountLifeCycleStatus":"Active","TimeZone":"EasternTimeUSCanada"},
+			{"Id":180002,"Name":"Blog","ParentCustomerId":9100,"AccountLifeCycleStatus":"Pause","TimeZone":"Unknown"}
+		]}`)
+	})
+	mux.HandleFunc("/reporting/GenerateReport/Submit", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		if r.Header.Get("CustomerId") != "9100" {
+			t.Errorf("report submitted for customer %q", r.Header.Get("CustomerId"))
+		}
+		var body struct {
+			ReportRequest struct {
+				Type        string
+				Aggregation string
+				Scope       struct{ AccountIds []int64 }
+				Time        struct{ CustomDateRangeStart, CustomDateRangeEnd microsoftDate }
+			}
+		}
+		data, _ := readBody(r)
+		if err := json.Unmarshal([]byte(data), &body); err != nil {
+			t.Errorf("invalid report request: %v", err)
+		}
+		req := body.ReportRequest
+		if req.Type != "CampaignPerformanceReportRequest" || req.Aggregation != "Daily" || len(req.Scope.AccountIds) != 2 ||
+			req.Time.CustomDateRangeStart != (microsoftDate{Day: 19, Month: 5, Year: 2024}) ||
+			req.Time.CustomDateRangeEnd != (microsoftDate{Day: 20, Month: 5, Year: 2024}) {
+			t.Errorf("unexpected report request %s", data)
+		}
+		fmt.Fprint(w, `{"ReportRequestId":"30000000001"}`)
+	})
+	mux.HandleFunc("/reporting/GenerateReport/Poll", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		polls++
+		if polls == 1 {
+			fmt.Fprint(w, `{"ReportRequestStatus":{"Status":"Pending","ReportDownloadUrl":null}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"ReportRequestStatus":{"Status":"Success","ReportDownloadUrl":"%s/download/report.zip"}}`, server.URL)
+	})
+	mux.HandleFunc("/download/report.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(archive.Bytes())
+	})
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func readBody(r *http.Request) (string, error) {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(r.Body)
+	return buf.String(), err
+}
+
+func microsoftTestEndpoints(server *httptest.Server) microsoftEndpoints {
+	return microsoftEndpoints{
+		token:     server.URL + "/token",
+		customer:  server.URL + "/customer/",
+		reporting: server.URL + "/reporting/",
+	}
+}
+
+func TestMicrosoftFetcher(t *testing.T) {
+	server := microsoftServer(t)
+	defer server.Close()
+
+	fetch := newMicrosoftFetcher(microsoftTestEndpoints(server), "dev", time.Millisecond)
+	start := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	task, err := fetch("refresh", "oauth-id", "", start, start.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the accounts have a row for every day, with or without delivery
+	if len(task.Accounts) != 4 {
+		t.Fatalf("expected 4 account rows, got %d", len(task.Accounts))
+	}
+	accounts := accountsByDay(task.Accounts)
+	acc := accounts["180001|2024-05-19"]
+	if acc.AccountID != "180001" || acc.AccountName != "Shop" || acc.BusinessID != "9100" ||
+		acc.Status != "ACTIVE" || acc.Timezone != "America/New_York" || acc.ProviderType.String() != "MICROSOFT" {
+		t.Fatalf("unexpected account %+v", acc)
+	}
+	// the sum of the campaigns of the day
+	if acc.Spend != 40 || acc.Impressions != 2000 || acc.Clicks != 60 || acc.Conversions != 4 ||
+		acc.PurchaseValue != 160 || acc.NumberOfCampaigns != 2 || !acc.DateRef.Equal(start) {
+		t.Fatalf("unexpected account metrics %+v", acc)
+	}
+	if acc := accounts["180001|2024-05-20"]; acc.Spend != 12 || acc.NumberOfCampaigns != 1 {
+		t.Fatalf("unexpected account metrics %+v", acc)
+	}
+	// the account without delivery, in UTC as its time zone is unknown
+	for _, day := range []string{"2024-05-19", "2024-05-20"} {
+		acc, ok := accounts["180002|"+day]
+		if !ok || acc.AccountName != "Blog" || acc.Spend != 0 || acc.NumberOfCampaigns != 0 ||
+			acc.Status != "INACTIVE" || acc.Timezone != "UTC" {
+			t.Fatalf("unexpected account without delivery %+v", acc)
+		}
+	}
+
+	// the campaign without delivery is not reported
+	if len(task.Campaigns) != 3 {
+		t.Fatalf("expected 3 campaign rows, got %d", len(task.Campaigns))
+	}
+	camp := task.Campaigns[1]
+	if camp.CampaignID != "602" || camp.CampaignName != "Shopping" || camp.Status != "INACTIVE" || camp.Timezone != "America/New_York" ||
+		camp.Spend != 9.75 || camp.Impressions != 800 || camp.PurchaseValue != 40 || camp.BusinessID != "9100" {
+		t.Fatalf("unexpected campaign %+v", camp)
+	}
+}
+
+func TestMicrosoftFetcherErrors(t *testing.T) {
+	server := microsoftServer(t)
+	defer server.Close()
+
+	start := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	fetch := newMicrosoftFetcher(microsoftTestEndpoints(server), "dev", time.Millisecond)
+	if _, err := fetch("expired", "oauth-id", "", start, start); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("expected a token error, got %v", err)
+	}
+	fetch = newMicrosoftFetcher(microsoftTestEndpoints(server), "wrong", time.Millisecond)
+	if _, err := fetch("refresh", "oauth-id", "", start, start); err == nil || !strings.Contains(err.Error(), "InvalidCredentials") {
+		t.Fatalf("expected an authentication error, got %v", err)
+	}
+}
+
+func TestMicrosoftSpendWithoutRows(t *testing.T) {
+	start := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	accounts := []microsoftAccount{{ID: 180001, Name: "Shop", AccountLifeCycleStatus: "Active", TimeZone: "GreenwichMeanTimeDublinEdinburghLisbonLondon"}}
+	// the report of a customer without delivery has no download url
+	accountsSpend, campaignsSpend, err := microsoftSpend("9100", accounts, nil, start, start.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accountsSpend) != 3 || len(campaignsSpend) != 0 {
+		t.Fatalf("expected 3 account rows and no campaign, got %d and %d", len(accountsSpend), len(campaignsSpend))
+	}
+	for idx, acc := range accountsSpend {
+		if acc.Spend != 0 || acc.Timezone != "Europe/London" || !acc.DateRef.Equal(start.AddDate(0, 0, idx)) {
+			t.Fatalf("unexpected account %+v", acc)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/configuration"
 	"github.com/s0und0fs1lence/ads-zero/pkg/db"
 )
 
@@ -76,9 +77,19 @@ func (p *provider) withType(pType db.ProviderEnum) Provider {
 		p.baseEndpoint = "https://graph.facebook.com/v21.0/"
 		p.fetcher = facebookFetcher
 	case db.Google:
-		panic("define google base endpoint")
+		p.baseEndpoint = googleAdsEndpoint
+		p.fetcher = newGoogleFetcher(p.baseEndpoint, googleTokenEndpoint,
+			configuration.Config().GetString(configuration.GoogleDeveloperToken))
+	case db.TikTok:
+		p.baseEndpoint = tiktokEndpoint
+		p.fetcher = newTikTokFetcher(p.baseEndpoint)
 	case db.Taboola:
-		panic("define taboola base endpoint")
+		p.baseEndpoint = taboolaEndpoint
+		p.fetcher = newTaboolaFetcher(p.baseEndpoint)
+	case db.Microsoft:
+		p.baseEndpoint = microsoftProduction.reporting
+		p.fetcher = newMicrosoftFetcher(microsoftProduction,
+			configuration.Config().GetString(configuration.MicrosoftDeveloperToken), 5*time.Second)
 	}
 	return p
 }
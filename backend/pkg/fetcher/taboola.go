@@ -0,0 +1,276 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+const taboolaEndpoint = "https://backstage.taboola.com/backstage/"
+
+type taboolaAccount struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	AccountID    string `json:"account_id"`
+	Type         string `json:"type"`
+	TimeZoneName string `json:"time_zone_name"`
+}
+
+type taboolaReportRow struct {
+	Date             string  `json:"date"`
+	Campaign         string  `json:"campaign"`
+	CampaignName     string  `json:"campaign_name"`
+	Clicks           float64 `json:"clicks"`
+	Impressions      float64 `json:"impressions"`
+	Spent            float64 `json:"spent"`
+	CpaActionsNum    float64 `json:"cpa_actions_num"`
+	ConversionsValue float64 `json:"conversions_value"`
+}
+
+func (r taboolaReportRow) empty() bool {
+	return r.Spent == 0 && r.Impressions == 0 && r.Clicks == 0 && r.CpaActionsNum == 0
+}
+
+// day returns the day of the row, reported as a time at midnight.
+func (r taboolaReportRow) day() (time.Time, error) {
+	return time.Parse(time.DateOnly, strings.Split(r.Date, " ")[0])
+}
+
+// taboolaAPI is a client of the Taboola Backstage API.
+type taboolaAPI struct {
+	endpoint    string
+	accessToken string
+	client      *http.Client
+}
+
+// authenticate gets an access token with the client credentials.
+func (t *taboolaAPI) authenticate(clientID, clientSecret string) error {
+	resp, err := t.client.PostForm(t.endpoint+"oauth/token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("could not decode the taboola token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return fmt.Errorf("could not authenticate to taboola: %s (status %d)", token.Error, resp.StatusCode)
+	}
+	t.accessToken = token.AccessToken
+	return nil
+}
+
+func (t *taboolaAPI) get(path string, params url.Values, out any) error {
+	u := t.endpoint + "api/1.0/" + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.accessToken)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			HTTPStatus int    `json:"http_status"`
+			Message    string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Message == "" {
+			return fmt.Errorf("taboola replied with status %d", resp.StatusCode)
+		}
+		return fmt.Errorf("taboola: %s (%d)", apiErr.Message, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// listAccounts returns the accounts to fetch and the account of the
+// credentials: the sub-accounts of a network account, the account itself
+// otherwise.
+func (t *taboolaAPI) listAccounts() ([]taboolaAccount, taboolaAccount, error) {
+	var current taboolaAccount
+	if err := t.get("users/current/account", nil, &current); err != nil {
+		return nil, current, err
+	}
+	if current.Type != "NETWORK" {
+		return []taboolaAccount{current}, current, nil
+	}
+	var advertisers struct {
+		Results []taboolaAccount `json:"results"`
+	}
+	if err := t.get(url.PathEscape(current.AccountID)+"/advertisers", nil, &advertisers); err != nil {
+		return nil, current, err
+	}
+	return advertisers.Results, current, nil
+}
+
+// campaignReport returns the campaign summary of the account by day.
+func (t *taboolaAPI) campaignReport(accountID string, start, end time.Time) ([]taboolaReportRow, error) {
+	var report struct {
+		Results []taboolaReportRow `json:"results"`
+	}
+	params := url.Values{
+		"start_date": {start.Format(time.DateOnly)},
+		"end_date":   {end.Format(time.DateOnly)},
+	}
+	path := url.PathEscape(accountID) + "/reports/campaign-summary/dimensions/campaign_day_breakdown"
+	if err := t.get(path, params, &report); err != nil {
+		return nil, err
+	}
+	return report.Results, nil
+}
+
+// newTaboolaFetcher returns the fetcher of the Taboola Backstage API, it
+// authenticates with the app id and secret as client credentials.
+func newTaboolaFetcher(endpoint string) fetchFunc {
+	return func(_, clientID, clientSecret string, start, end time.Time) (*common.FetchTask, error) {
+		task := common.NewFetchTask(start, end)
+		api := &taboolaAPI{
+			endpoint: endpoint,
+			client:   &http.Client{Timeout: 60 * time.Second},
+		}
+		if err := api.authenticate(clientID, clientSecret); err != nil {
+			return task, err
+		}
+		accounts, network, err := api.listAccounts()
+		if err != nil {
+			return task, err
+		}
+		g := new(errgroup.Group)
+		g.SetLimit(5)
+		for _, account := range accounts {
+			g.Go(func() error {
+				rows, err := api.campaignReport(account.AccountID, start, end)
+				if err != nil {
+					return fmt.Errorf("account %s: %w", account.AccountID, err)
+				}
+				accountsSpend, campaignsSpend, err := taboolaSpend(network, account, rows, start, end)
+				if err != nil {
+					return fmt.Errorf("account %s: %w", account.AccountID, err)
+				}
+				task.Lock()
+				task.Accounts = append(task.Accounts, accountsSpend...)
+				task.Campaigns = append(task.Campaigns, campaignsSpend...)
+				task.Unlock()
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return task, err
+		}
+		return task, nil
+	}
+}
+
+// taboolaSpend converts the campaign summary into one row per day of each
+// delivering campaign, and their sum per day for the account. The account
+// has a row for every day of the period, without spend when none of its
+// campaigns delivered.
+func taboolaSpend(network, account taboolaAccount, rows []taboolaReportRow, start, end time.Time) ([]db.DbAccountSpend, []db.DbCampaignSpend, error) {
+	now := time.Now().UTC()
+	timezone := taboolaTimezone(network, account)
+	campaigns := make([]db.DbCampaignSpend, 0, len(rows))
+	accounts := make([]db.DbAccountSpend, 0)
+	byDay := make(map[time.Time]int)
+	addDay := func(dateRef time.Time) int {
+		byDay[dateRef] = len(accounts)
+		accounts = append(accounts, db.DbAccountSpend{
+			AccountID:    account.AccountID,
+			AccountName:  account.Name,
+			BusinessID:   network.AccountID,
+			BusinessName: network.Name,
+			ProviderType: db.Taboola,
+			Status:       db.UnknownStatus.String(),
+			Timezone:     timezone,
+			DateRef:      dateRef,
+			UpdatedAt:    now,
+		})
+		return byDay[dateRef]
+	}
+	for _, dateRef := range periodDays(start, end) {
+		addDay(dateRef)
+	}
+	for _, r := range rows {
+		if r.empty() {
+			continue
+		}
+		dateRef, err := r.day()
+		if err != nil {
+			return nil, nil, err
+		}
+		campaigns = append(campaigns, db.DbCampaignSpend{
+			AccountID:     account.AccountID,
+			AccountName:   account.Name,
+			BusinessID:    network.AccountID,
+			BusinessName:  network.Name,
+			CampaignID:    r.Campaign,
+			CampaignName:  r.CampaignName,
+			ProviderType:  db.Taboola,
+			Status:        db.UnknownStatus.String(),
+			Spend:         r.Spent,
+			Impressions:   uint64(r.Impressions),
+			Clicks:        uint64(r.Clicks),
+			Conversions:   r.CpaActionsNum,
+			PurchaseValue: r.ConversionsValue,
+			Timezone:      timezone,
+			DateRef:       dateRef,
+			UpdatedAt:     now,
+		})
+
+		idx, ok := byDay[dateRef]
+		if !ok {
+			idx = addDay(dateRef)
+		}
+		accounts[idx].Spend += r.Spent
+		accounts[idx].Impressions += uint64(r.Impressions)
+		accounts[idx].Clicks += uint64(r.Clicks)
+		accounts[idx].Conversions += r.CpaActionsNum
+		accounts[idx].PurchaseValue += r.ConversionsValue
+		accounts[idx].NumberOfCampaigns++
+	}
+	return accounts, campaigns, nil
+}
+
+// taboolaTimezone returns the timezone of the account, the one of the
+// network for the advertisers without one.
+func taboolaTimezone(network, account taboolaAccount) string {
+	if account.TimeZoneName != "" {
+		return account.TimeZoneName
+	}
+	if network.TimeZoneName != "" {
+		return network.TimeZoneName
+	}
+	return "UTC"
+}
+
+// periodDays returns the days of the fetched period, at midnight UTC as the
+// days of the reports.
+func periodDays(start, end time.Time) []time.Time {
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	last := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	res := make([]time.Time, 0)
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		res = append(res, day)
+	}
+	return res
+}
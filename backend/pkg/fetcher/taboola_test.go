@@ -0,0 +1,139 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+// taboolaServer stands in for the Backstage API: the network account
+// acme-network has the advertisers acme-shop and acme-blog.
+func taboolaServer(t *testing.T, network bool) *httptest.Server {
+	reports := map[string]string{
+		"acme-shop": `{"timezone":"EST","recordCount":3,"results":[
+			{"date":"2024-05-19 00:00:00.0","campaign":"501","campaign_name":"Summer","clicks":120,"impressions":40000,"spent":60.5,"cpa_actions_num":4,"conversions_value":220.0,"currency":"USD"},
+			{"date":"2024-05-19 00:00:00.0","campaign":"502","campaign_name":"Brand","clicks":30,"impressions":9000,"spent":14.5,"cpa_actions_num":1,"conversions_value":35.0,"currency":"USD"},
+			{"date":"2024-05-20 00:00:00.0","campaign":"501","campaign_name":"Summer","clicks":50,"impressions":15000,"spent":25.0,"cpa_actions_num":0,"conversions_value":0,"currency":"USD"},
+			{"date":"2024-05-20 00:00:00.0","campaign":"502","campaign_name":"Brand","clicks":0,"impressions":0,"spent":0,"cpa_actions_num":0,"conversions_value":0,"currency":"USD"}
+		]}`,
+		"acme-blog": `{"timezone":"EST","recordCount":0,"results":[]}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" ||
+			r.PostForm.Get("client_id") != "client" || r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"access","token_type":"bearer","expires_in":43200}`)
+	})
+	mux.HandleFunc("/api/1.0/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"http_status":401,"message":"Unauthorized"}`)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/api/1.0/")
+		switch {
+		case path == "users/current/account" && network:
+			fmt.Fprint(w, `{"id":1001,"name":"Acme Network","account_id":"acme-network","type":"NETWORK","partner_types":["ADVERTISER"],"time_zone_name":"US/Eastern"}`)
+		case path == "users/current/account":
+			fmt.Fprint(w, `{"id":1002,"name":"Acme Shop","account_id":"acme-shop","type":"PARTNER","partner_types":["ADVERTISER"],"time_zone_name":"Europe/Rome"}`)
+		case path == "acme-network/advertisers":
+			fmt.Fprint(w, `{"results":[
+				{"id":1002,"name":"Acme Shop","account_id":"acme-shop","type":"PARTNER","time_zone_name":"Europe/Rome"},
+				{"id":1003,"name":"Acme Blog","account_id":"acme-blog","type":"PARTNER"}
+			]}`)
+		case strings.HasSuffix(path, "/reports/campaign-summary/dimensions/campaign_day_breakdown"):
+			q := r.URL.Query()
+			if q.Get("start_date") != "2024-05-19" || q.Get("end_date") != "2024-05-20" {
+				t.Errorf("unexpected period %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, reports[strings.Split(path, "/")[0]])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"http_status":404,"message":"Account %s not found"}`, path)
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+// accountsByDay indexes the account rows by account and day.
+func accountsByDay(rows []db.DbAccountSpend) map[string]db.DbAccountSpend {
+	res := make(map[string]db.DbAccountSpend, len(rows))
+	for _, row := range rows {
+		res[row.AccountID+"|"+row.DateRef.Format(time.DateOnly)] = row
+	}
+	return res
+}
+
+func TestTaboolaFetcher(t *testing.T) {
+	server := taboolaServer(t, true)
+	defer server.Close()
+
+	fetch := newTaboolaFetcher(server.URL + "/")
+	start := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	task, err := fetch("", "client", "secret", start, start.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the accounts have a row for every day, with or without delivery
+	if len(task.Accounts) != 4 {
+		t.Fatalf("expected 4 account rows, got %d", len(task.Accounts))
+	}
+	accounts := accountsByDay(task.Accounts)
+	acc := accounts["acme-shop|2024-05-19"]
+	if acc.AccountID != "acme-shop" || acc.AccountName != "Acme Shop" || acc.BusinessID != "acme-network" ||
+		acc.BusinessName != "Acme Network" || acc.ProviderType.String() != "TABOOLA" || acc.Timezone != "Europe/Rome" {
+		t.Fatalf("unexpected account %+v", acc)
+	}
+	// the sum of the campaigns of the day
+	if acc.Spend != 75 || acc.Impressions != 49000 || acc.Clicks != 150 || acc.Conversions != 5 ||
+		acc.PurchaseValue != 255 || acc.NumberOfCampaigns != 2 || !acc.DateRef.Equal(start) {
+		t.Fatalf("unexpected account metrics %+v", acc)
+	}
+	if acc := accounts["acme-shop|2024-05-20"]; acc.Spend != 25 || acc.NumberOfCampaigns != 1 {
+		t.Fatalf("unexpected account metrics %+v", acc)
+	}
+	// the advertiser without delivery, in the timezone of the network
+	for _, day := range []string{"2024-05-19", "2024-05-20"} {
+		acc, ok := accounts["acme-blog|"+day]
+		if !ok || acc.Spend != 0 || acc.NumberOfCampaigns != 0 || acc.Timezone != "US/Eastern" || acc.BusinessID != "acme-network" {
+			t.Fatalf("unexpected account without delivery %+v", acc)
+		}
+	}
+	if len(task.Campaigns) != 3 {
+		t.Fatalf("expected 3 campaign rows, got %d", len(task.Campaigns))
+	}
+	camp := task.Campaigns[1]
+	if camp.CampaignID != "502" || camp.CampaignName != "Brand" || camp.AccountID != "acme-shop" || camp.Timezone != "Europe/Rome" ||
+		camp.Spend != 14.5 || camp.Conversions != 1 || camp.PurchaseValue != 35 {
+		t.Fatalf("unexpected campaign %+v", camp)
+	}
+}
+
+func TestTaboolaFetcherAccount(t *testing.T) {
+	server := taboolaServer(t, false)
+	defer server.Close()
+
+	fetch := newTaboolaFetcher(server.URL + "/")
+	start := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	task, err := fetch("", "client", "secret", start, start.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an account that is not a network is fetched alone, as its own business
+	if len(task.Accounts) != 2 || task.Accounts[0].AccountID != "acme-shop" || task.Accounts[0].BusinessID != "acme-shop" {
+		t.Fatalf("unexpected accounts %+v", task.Accounts)
+	}
+
+	if _, err := fetch("", "client", "wrong", start, start); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Fatalf("expected an authentication error, got %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+const tiktokEndpoint = "https://business-api.tiktok.com/open_api/v1.3/"
+
+// tiktokPageSize is the maximum page size of the reports.
+const tiktokPageSize = 1000
+
+var tiktokReportMetrics = []string{"spend", "impressions", "clicks", "conversion"}
+
+// tiktokNumber is a metric of the TikTok reports, encoded as a string.
+type tiktokNumber float64
+
+func (v *tiktokNumber) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" || s == "-" {
+		*v = 0
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid tiktok number %s: %w", data, err)
+	}
+	*v = tiktokNumber(f)
+	return nil
+}
+
+type tiktokResponse struct {
+	Code      int             `json:"code"`
+	Message   string          `json:"message"`
+	RequestID string          `json:"request_id"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type tiktokAdvertiser struct {
+	AdvertiserID    string `json:"advertiser_id"`
+	Name            string `json:"name"`
+	Status          string `json:"status"`
+	Timezone        string `json:"timezone"`
+	DisplayTimezone string `json:"display_timezone"`
+	OwnerBcID       string `json:"owner_bc_id"`
+}
+
+type tiktokReportRow struct {
+	Dimensions struct {
+		AdvertiserID string `json:"advertiser_id"`
+		CampaignID   string `json:"campaign_id"`
+		StatTimeDay  string `json:"stat_time_day"`
+	} `json:"dimensions"`
+	Metrics struct {
+		CampaignName string       `json:"campaign_name"`
+		Spend        tiktokNumber `json:"spend"`
+		Impressions  tiktokNumber `json:"impressions"`
+		Clicks       tiktokNumber `json:"clicks"`
+		Conversion   tiktokNumber `json:"conversion"`
+	} `json:"metrics"`
+}
+
+func (r tiktokReportRow) empty() bool {
+	return r.Metrics.Spend == 0 && r.Metrics.Impressions == 0 && r.Metrics.Clicks == 0 && r.Metrics.Conversion == 0
+}
+
+// day returns the day of the row, reported as a time at midnight.
+func (r tiktokReportRow) day() (time.Time, error) {
+	return time.Parse(time.DateOnly, strings.Split(r.Dimensions.StatTimeDay, " ")[0])
+}
+
+type tiktokReportPage struct {
+	List     []tiktokReportRow `json:"list"`
+	PageInfo struct {
+		Page      int `json:"page"`
+		TotalPage int `json:"total_page"`
+	} `json:"page_info"`
+}
+
+// tiktokAPI is a client of the TikTok Marketing API.
+type tiktokAPI struct {
+	endpoint    string
+	accessToken string
+	client      *http.Client
+}
+
+func (t *tiktokAPI) get(path string, params url.Values, out any) error {
+	req, err := http.NewRequest(http.MethodGet, t.endpoint+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Access-Token", t.accessToken)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("tiktok replied with status %d", resp.StatusCode)
+	}
+	var res tiktokResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("could not decode the tiktok response: %w", err)
+	}
+	// the errors are reported with a 200 status and a non zero code
+	if res.Code != 0 {
+		return fmt.Errorf("tiktok: %s (%d)", res.Message, res.Code)
+	}
+	return json.Unmarshal(res.Data, out)
+}
+
+func jsonList(values ...string) string {
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// listAdvertisers returns the advertisers authorized to the access token.
+func (t *tiktokAPI) listAdvertisers(appID, appSecret string) ([]tiktokAdvertiser, error) {
+	var authorized struct {
+		List []struct {
+			AdvertiserID string `json:"advertiser_id"`
+		} `json:"list"`
+	}
+	if err := t.get("oauth2/advertiser/get/", url.Values{"app_id": {appID}, "secret": {appSecret}}, &authorized); err != nil {
+		return nil, err
+	}
+	res := make([]tiktokAdvertiser, 0, len(authorized.List))
+	// the info endpoint accepts up to 100 advertisers
+	for start := 0; start < len(authorized.List); start += 100 {
+		ids := make([]string, 0, 100)
+		for _, a := range authorized.List[start:min(start+100, len(authorized.List))] {
+			ids = append(ids, a.AdvertiserID)
+		}
+		var info struct {
+			List []tiktokAdvertiser `json:"list"`
+		}
+		params := url.Values{
+			"advertiser_ids": {jsonList(ids...)},
+			"fields":         {jsonList("advertiser_id", "name", "status", "timezone", "display_timezone", "owner_bc_id")},
+		}
+		if err := t.get("advertiser/info/", params, &info); err != nil {
+			return nil, err
+		}
+		res = append(res, info.List...)
+	}
+	return res, nil
+}
+
+// report returns every page of the daily integrated report of the
+// advertiser, at the advertiser or campaign level.
+func (t *tiktokAPI) report(advertiserID string, campaigns bool, start, end time.Time) ([]tiktokReportRow, error) {
+	level, dimensions, metrics := "AUCTION_ADVERTISER", []string{"advertiser_id", "stat_time_day"}, tiktokReportMetrics
+	if campaigns {
+		level, dimensions = "AUCTION_CAMPAIGN", []string{"campaign_id", "stat_time_day"}
+		metrics = append([]string{"campaign_name"}, tiktokReportMetrics...)
+	}
+	rows := make([]tiktokReportRow, 0)
+	for page := 1; ; page++ {
+		params := url.Values{
+			"advertiser_id": {advertiserID},
+			"report_type":   {"BASIC"},
+			"data_level":    {level},
+			"dimensions":    {jsonList(dimensions...)},
+			"metrics":       {jsonList(metrics...)},
+			"start_date":    {start.Format(time.DateOnly)},
+			"end_date":      {end.Format(time.DateOnly)},
+			"page":          {strconv.Itoa(page)},
+			"page_size":     {strconv.Itoa(tiktokPageSize)},
+		}
+		var res tiktokReportPage
+		if err := t.get("report/integrated/get/", params, &res); err != nil {
+			return nil, err
+		}
+		rows = append(rows, res.List...)
+		if page >= res.PageInfo.TotalPage {
+			return rows, nil
+		}
+	}
+}
+
+func tiktokStatus(status string) string {
+	switch status {
+	case "STATUS_ENABLE":
+		return db.Active.String()
+	case "":
+		return db.UnknownStatus.String()
+	}
+	return db.Inactive.String()
+}
+
+// newTikTokFetcher returns the fetcher of the TikTok Marketing API, the app
+// id and secret are the ones of the developer app the token was issued to.
+func newTikTokFetcher(endpoint string) fetchFunc {
+	return func(accessToken, appID, appSecret string, start, end time.Time) (*common.FetchTask, error) {
+		task := common.NewFetchTask(start, end)
+		api := &tiktokAPI{
+			endpoint:    endpoint,
+			accessToken: accessToken,
+			client:      &http.Client{Timeout: 60 * time.Second},
+		}
+		advertisers, err := api.listAdvertisers(appID, appSecret)
+		if err != nil {
+			return task, err
+		}
+		g := new(errgroup.Group)
+		g.SetLimit(5)
+		for _, advertiser := range advertisers {
+			g.Go(func() error {
+				accRows, err := api.report(advertiser.AdvertiserID, false, start, end)
+				if err != nil {
+					return fmt.Errorf("advertiser %s: %w", advertiser.AdvertiserID, err)
+				}
+				campRows, err := api.report(advertiser.AdvertiserID, true, start, end)
+				if err != nil {
+					return fmt.Errorf("advertiser %s: %w", advertiser.AdvertiserID, err)
+				}
+				accounts, campaigns, err := tiktokSpend(advertiser, accRows, campRows, start, end)
+				if err != nil {
+					return fmt.Errorf("advertiser %s: %w", advertiser.AdvertiserID, err)
+				}
+				task.Lock()
+				task.Accounts = append(task.Accounts, accounts...)
+				task.Campaigns = append(task.Campaigns, campaigns...)
+				task.Unlock()
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return task, err
+		}
+		return task, nil
+	}
+}
+
+// tiktokSpend converts the report rows into one row per day of the
+// advertiser and of each delivering campaign. The report leaves out the days
+// without metrics, the advertiser has a zero row for them.
+func tiktokSpend(advertiser tiktokAdvertiser, accRows, campRows []tiktokReportRow, start, end time.Time) ([]db.DbAccountSpend, []db.DbCampaignSpend, error) {
+	now := time.Now().UTC()
+	timezone := advertiser.DisplayTimezone
+	if timezone == "" {
+		timezone = advertiser.Timezone
+	}
+	campaigns := make([]db.DbCampaignSpend, 0, len(campRows))
+	campaignsByDay := make(map[time.Time]uint16)
+	for _, r := range campRows {
+		if r.empty() {
+			continue
+		}
+		dateRef, err := r.day()
+		if err != nil {
+			return nil, nil, err
+		}
+		campaignsByDay[dateRef]++
+		campaigns = append(campaigns, db.DbCampaignSpend{
+			AccountID:    advertiser.AdvertiserID,
+			AccountName:  advertiser.Name,
+			BusinessID:   advertiser.OwnerBcID,
+			CampaignID:   r.Dimensions.CampaignID,
+			CampaignName: r.Metrics.CampaignName,
+			ProviderType: db.TikTok,
+			Status:       db.UnknownStatus.String(),
+			Spend:        float64(r.Metrics.Spend),
+			Impressions:  uint64(r.Metrics.Impressions),
+			Clicks:       uint64(r.Metrics.Clicks),
+			Conversions:  float64(r.Metrics.Conversion),
+			Timezone:     timezone,
+			DateRef:      dateRef,
+			UpdatedAt:    now,
+		})
+	}
+
+	accounts := make([]db.DbAccountSpend, 0, len(accRows))
+	byDay := make(map[time.Time]int)
+	addDay := func(dateRef time.Time) int {
+		byDay[dateRef] = len(accounts)
+		accounts = append(accounts, db.DbAccountSpend{
+			AccountID:    advertiser.AdvertiserID,
+			AccountName:  advertiser.Name,
+			BusinessID:   advertiser.OwnerBcID,
+			ProviderType: db.TikTok,
+			Status:       tiktokStatus(advertiser.Status),
+			Timezone:     timezone,
+			DateRef:      dateRef,
+			UpdatedAt:    now,
+		})
+		return byDay[dateRef]
+	}
+	for _, dateRef := range periodDays(start, end) {
+		addDay(dateRef)
+	}
+	for _, r := range accRows {
+		dateRef, err := r.day()
+		if err != nil {
+			return nil, nil, err
+		}
+		idx, ok := byDay[dateRef]
+		if !ok {
+			idx = addDay(dateRef)
+		}
+		acc := &accounts[idx]
+		acc.Spend = float64(r.Metrics.Spend)
+		acc.Impressions = uint64(r.Metrics.Impressions)
+		acc.Clicks = uint64(r.Metrics.Clicks)
+		acc.Conversions = float64(r.Metrics.Conversion)
+		acc.NumberOfCampaigns = campaignsByDay[dateRef]
+	}
+	return accounts, campaigns, nil
+}
@@ -0,0 +1,128 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tiktokServer replays the responses recorded from the TikTok Marketing API
+// in testdata/tiktok.
+func tiktokServer(t *testing.T) *httptest.Server {
+	replay := func(w http.ResponseWriter, name string) {
+		data, err := os.ReadFile(filepath.Join("testdata", "tiktok", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Access-Token") != "token" {
+			replay(w, "invalid_token.json")
+			return
+		}
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/oauth2/advertiser/get/":
+			if q.Get("app_id") != "app" || q.Get("secret") != "secret" {
+				t.Errorf("unexpected app credentials %s", r.URL.RawQuery)
+			}
+			replay(w, "advertiser_get.json")
+		case "/advertiser/info/":
+			if q.Get("advertiser_ids") != `["7010000000000000001"]` {
+				t.Errorf("unexpected advertisers %s", q.Get("advertiser_ids"))
+			}
+			replay(w, "advertiser_info.json")
+		case "/report/integrated/get/":
+			if q.Get("start_date") != "2024-05-19" || q.Get("end_date") != "2024-05-20" || q.Get("report_type") != "BASIC" {
+				t.Errorf("unexpected report %s", r.URL.RawQuery)
+			}
+			switch q.Get("data_level") {
+			case "AUCTION_ADVERTISER":
+				replay(w, "report_advertiser.json")
+			case "AUCTION_CAMPAIGN":
+				if !strings.Contains(q.Get("metrics"), "campaign_name") {
+					t.Errorf("campaign names not requested")
+				}
+				replay(w, "report_campaign_"+q.Get("page")+".json")
+			default:
+				t.Errorf("unexpected data level %s", q.Get("data_level"))
+			}
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestTikTokFetcher(t *testing.T) {
+	server := tiktokServer(t)
+	defer server.Close()
+
+	fetch := newTikTokFetcher(server.URL + "/")
+	start := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	task, err := fetch("token", "app", "secret", start, start.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(task.Accounts) != 2 {
+		t.Fatalf("expected 2 account rows, got %d", len(task.Accounts))
+	}
+	acc := task.Accounts[0]
+	if acc.AccountID != "7010000000000000001" || acc.AccountName != "Shop US" || acc.BusinessID != "7020000000000000001" ||
+		acc.Status != "ACTIVE" || acc.Timezone != "America/Los_Angeles" || acc.ProviderType.String() != "TIKTOK" {
+		t.Fatalf("unexpected account %+v", acc)
+	}
+	if acc.Spend != 120.5 || acc.Impressions != 15000 || acc.Clicks != 300 || acc.Conversions != 12 ||
+		acc.NumberOfCampaigns != 2 || !acc.DateRef.Equal(start) {
+		t.Fatalf("unexpected account metrics %+v", acc)
+	}
+	if task.Accounts[1].Spend != 40 || task.Accounts[1].NumberOfCampaigns != 1 {
+		t.Fatalf("unexpected account metrics %+v", task.Accounts[1])
+	}
+
+	// both pages are read, the campaign without delivery is not reported
+	if len(task.Campaigns) != 3 {
+		t.Fatalf("expected 3 campaign rows, got %d", len(task.Campaigns))
+	}
+	camp := task.Campaigns[2]
+	if camp.CampaignID != "1790000000000000001" || camp.CampaignName != "Spring sale" || camp.AccountID != acc.AccountID ||
+		camp.Spend != 40 || camp.Impressions != 5000 || !camp.DateRef.Equal(start.AddDate(0, 0, 1)) {
+		t.Fatalf("unexpected campaign %+v", camp)
+	}
+}
+
+func TestTikTokFetcherErrors(t *testing.T) {
+	server := tiktokServer(t)
+	defer server.Close()
+
+	fetch := newTikTokFetcher(server.URL + "/")
+	start := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	_, err := fetch("revoked", "app", "secret", start, start)
+	if err == nil || !strings.Contains(err.Error(), "40105") {
+		t.Fatalf("expected the api error, got %v", err)
+	}
+}
+
+func TestTikTokSpendWithoutRows(t *testing.T) {
+	start := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	advertiser := tiktokAdvertiser{AdvertiserID: "7010000000000000002", Name: "Blog", Status: "STATUS_ENABLE", Timezone: "Europe/Rome"}
+	// the report of an advertiser without delivery has no rows
+	accounts, campaigns, err := tiktokSpend(advertiser, nil, nil, start, start.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 3 || len(campaigns) != 0 {
+		t.Fatalf("expected 3 account rows and no campaign, got %d and %d", len(accounts), len(campaigns))
+	}
+	for idx, acc := range accounts {
+		if acc.Spend != 0 || acc.Status != "ACTIVE" || acc.Timezone != "Europe/Rome" || !acc.DateRef.Equal(start.AddDate(0, 0, idx)) {
+			t.Fatalf("unexpected account %+v", acc)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package fetcher
 import (
 	"time"
 
+	"github.com/s0und0fs1lence/ads-zero/pkg/alert"
 	"github.com/s0und0fs1lence/ads-zero/pkg/common"
 	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/notifier"
 )
 
 type Client interface {
@@ -15,8 +17,37 @@ type Client interface {
 	IsValid() bool
 	GetError() error
 	FetchData(start, end time.Time) (task *common.FetchTask, err error)
-	ExecuteRules(task common.Event) ([]*common.RuleResult, error)
+	// ExecuteRules evaluates the rules on every entity of the task.
+	ExecuteRules(task *common.FetchTask) ([]*common.RuleResult, error)
+	// ProcessAlerts updates the alert states with the results of the rules
+	// on the task, and returns the transitions to notify.
+	ProcessAlerts(task *common.FetchTask, results []*common.RuleResult) ([]alert.Event, error)
+	// SaveAlert stores the alert sent for a rule result, with its trace.
+	SaveAlert(result *common.RuleResult, firedAt time.Time) (*db.DbAlert, error)
 	GetNotificationChannel() (tp string, value string)
+	// GetNotificationDestinations returns where the notifications of the
+	// rule result are sent to.
+	GetNotificationDestinations(result *common.RuleResult) []notifier.Destination
+	// GroupAlerts reports whether the notifications of a fetch are combined
+	// in one per destination.
+	GroupAlerts() bool
+	// QuietHours reports whether the notifications of the client are held
+	// back at the time, QueueNotification holds one back in a queue,
+	// DequeueNotifications returns the ones queued before a time to deliver
+	// and MarkDelivered removes the delivered ones from the queue.
+	QuietHours(at time.Time) bool
+	QueueNotification(queue string, n *notifier.Notification, at time.Time) error
+	DequeueNotifications(queue string, before, at time.Time) ([]QueuedNotification, error)
+	MarkDelivered(queue string, ids []string, at time.Time) error
+	// DayStart returns the start of the day of the time for the client.
+	DayStart(at time.Time) time.Time
+}
+
+// QueuedNotification is a notification held back in a queue of the client,
+// ID identifies it in the queue.
+type QueuedNotification struct {
+	ID string
+	*notifier.Notification
 }
 
 type Provider interface {
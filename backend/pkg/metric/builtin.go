@@ -0,0 +1,215 @@
+package metric
+
+import "github.com/s0und0fs1lence/ads-zero/pkg/db"
+
+func init() {
+	// descriptive fields
+	MustRegister(Metric{
+		Name:        "PROVIDER_ID",
+		Description: "ID of the connected provider",
+		Type:        TypeString,
+		Aggregation: AggUnique,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.ProviderID },
+			func(c *db.DbCampaignSpend) any { return c.ProviderID },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "PROVIDER_TYPE",
+		Description: "Advertising platform (FACEBOOK, GOOGLE...)",
+		Type:        TypeString,
+		Aggregation: AggUnique,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.ProviderType.String() },
+			func(c *db.DbCampaignSpend) any { return c.ProviderType.String() },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "BUSINESS_ID",
+		Description: "ID of the business owning the account",
+		Type:        TypeString,
+		Aggregation: AggUnique,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.BusinessID },
+			func(c *db.DbCampaignSpend) any { return c.BusinessID },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "BUSINESS_NAME",
+		Description: "Name of the business owning the account",
+		Type:        TypeString,
+		Aggregation: AggUnique,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.BusinessName },
+			func(c *db.DbCampaignSpend) any { return c.BusinessName },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "ACCOUNT_ID",
+		Description: "ID of the ad account",
+		Type:        TypeString,
+		Aggregation: AggUnique,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.AccountID },
+			func(c *db.DbCampaignSpend) any { return c.AccountID },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "ACCOUNT_NAME",
+		Description: "Name of the ad account",
+		Type:        TypeString,
+		Aggregation: AggUnique,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.AccountName },
+			func(c *db.DbCampaignSpend) any { return c.AccountName },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "CAMPAIGN_ID",
+		Description: "ID of the campaign",
+		Type:        TypeString,
+		Aggregation: AggUnique,
+		Extract:     fromCampaign(func(c *db.DbCampaignSpend) any { return c.CampaignID }),
+	})
+	MustRegister(Metric{
+		Name:        "CAMPAIGN_NAME",
+		Description: "Name of the campaign",
+		Type:        TypeString,
+		Aggregation: AggUnique,
+		Extract:     fromCampaign(func(c *db.DbCampaignSpend) any { return c.CampaignName }),
+	})
+	MustRegister(Metric{
+		Name:        "TIMEZONE",
+		Description: "Timezone of the ad account",
+		Type:        TypeString,
+		Aggregation: AggUnique,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.Timezone },
+			func(c *db.DbCampaignSpend) any { return c.Timezone },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "STATUS",
+		Description: "Status of the account or of the campaign",
+		Type:        TypeString,
+		Aggregation: AggUnique,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.Status },
+			func(c *db.DbCampaignSpend) any { return c.Status },
+		),
+	})
+
+	// performance metrics
+	MustRegister(Metric{
+		Name:        "DAILY_SPEND",
+		Description: "Amount spent in the day",
+		Type:        TypeNumber,
+		Unit:        UnitCurrency,
+		Aggregation: AggSum,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.Spend },
+			func(c *db.DbCampaignSpend) any { return c.Spend },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "NUMBER_OF_CAMPAIGNS",
+		Description: "Number of campaigns with delivery in the day",
+		Type:        TypeNumber,
+		Unit:        UnitCount,
+		Aggregation: AggSum,
+		Extract:     fromAccount(func(a *db.DbAccountSpend) any { return a.NumberOfCampaigns }),
+	})
+	MustRegister(Metric{
+		Name:        "DAILY_IMPRESSIONS",
+		Description: "Impressions served in the day",
+		Type:        TypeNumber,
+		Unit:        UnitCount,
+		Aggregation: AggSum,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.Impressions },
+			func(c *db.DbCampaignSpend) any { return c.Impressions },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "DAILY_CLICKS",
+		Description: "Clicks received in the day",
+		Type:        TypeNumber,
+		Unit:        UnitCount,
+		Aggregation: AggSum,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.Clicks },
+			func(c *db.DbCampaignSpend) any { return c.Clicks },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "DAILY_CONVERSIONS",
+		Description: "Conversions (purchases) attributed in the day",
+		Type:        TypeNumber,
+		Unit:        UnitCount,
+		Aggregation: AggSum,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.Conversions },
+			func(c *db.DbCampaignSpend) any { return c.Conversions },
+		),
+	})
+	MustRegister(Metric{
+		Name:        "DAILY_PURCHASE_VALUE",
+		Description: "Value of the conversions attributed in the day",
+		Type:        TypeNumber,
+		Unit:        UnitCurrency,
+		Aggregation: AggSum,
+		Extract: fromBoth(
+			func(a *db.DbAccountSpend) any { return a.PurchaseValue },
+			func(c *db.DbCampaignSpend) any { return c.PurchaseValue },
+		),
+	})
+
+	// derived metrics, computed on the sums so that they're weighted by volume
+	MustRegister(Metric{
+		Name:        "AVG_CPC",
+		Description: "Average cost per click",
+		Type:        TypeNumber,
+		Unit:        UnitCurrency,
+		Aggregation: AggRatio,
+		Numerator:   "DAILY_SPEND",
+		Denominator: "DAILY_CLICKS",
+	})
+	MustRegister(Metric{
+		Name:        "AVG_CPM",
+		Description: "Average cost per thousand impressions",
+		Type:        TypeNumber,
+		Unit:        UnitCurrency,
+		Aggregation: AggRatio,
+		Numerator:   "DAILY_SPEND",
+		Denominator: "DAILY_IMPRESSIONS",
+		Scale:       1000,
+	})
+	MustRegister(Metric{
+		Name:        "CTR",
+		Description: "Click-through rate",
+		Type:        TypeNumber,
+		Unit:        UnitPercent,
+		Aggregation: AggRatio,
+		Numerator:   "DAILY_CLICKS",
+		Denominator: "DAILY_IMPRESSIONS",
+		Scale:       100,
+	})
+	MustRegister(Metric{
+		Name:        "CPA",
+		Description: "Cost per conversion",
+		Type:        TypeNumber,
+		Unit:        UnitCurrency,
+		Aggregation: AggRatio,
+		Numerator:   "DAILY_SPEND",
+		Denominator: "DAILY_CONVERSIONS",
+	})
+	MustRegister(Metric{
+		Name:        "ROAS",
+		Description: "Return on ad spend (purchase value / spend)",
+		Type:        TypeNumber,
+		Unit:        UnitNone,
+		Aggregation: AggRatio,
+		Numerator:   "DAILY_PURCHASE_VALUE",
+		Denominator: "DAILY_SPEND",
+	})
+}
@@ -0,0 +1,217 @@
+package metric
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metric describes a field that rules can be evaluated on.
+type Metric struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Type        Type        `json:"type"`
+	Unit        Unit        `json:"unit"`
+	Aggregation Aggregation `json:"aggregation"`
+	// Extract reads the metric from a single row, ok is false when the row
+	// doesn't carry the metric (e.g. a campaign name on an account row).
+	// It's not used by AggRatio metrics.
+	Extract func(r Row) (value any, ok bool) `json:"-"`
+	// Numerator and Denominator are the names of the metrics an AggRatio
+	// metric is computed from, the result is multiplied by Scale if set.
+	Numerator   string  `json:"numerator,omitempty"`
+	Denominator string  `json:"denominator,omitempty"`
+	Scale       float64 `json:"scale,omitempty"`
+}
+
+var (
+	registryMx sync.RWMutex
+	registry   = make(map[string]*Metric)
+)
+
+// Register adds a metric to the registry, metric names are case insensitive.
+func Register(m Metric) error {
+	m.Name = strings.ToUpper(m.Name)
+	if m.Name == "" {
+		return fmt.Errorf("metric name cannot be empty")
+	}
+	switch m.Aggregation {
+	case AggRatio:
+		if m.Type != TypeNumber {
+			return fmt.Errorf("metric %s: ratios must be numeric", m.Name)
+		}
+		if m.Numerator == "" || m.Denominator == "" {
+			return fmt.Errorf("metric %s: ratios require a numerator and a denominator", m.Name)
+		}
+		m.Numerator = strings.ToUpper(m.Numerator)
+		m.Denominator = strings.ToUpper(m.Denominator)
+	case AggSum, AggAvg:
+		if m.Type != TypeNumber {
+			return fmt.Errorf("metric %s: %s requires a numeric metric", m.Name, m.Aggregation)
+		}
+		fallthrough
+	default:
+		if m.Extract == nil {
+			return fmt.Errorf("metric %s: missing extractor", m.Name)
+		}
+	}
+
+	registryMx.Lock()
+	defer registryMx.Unlock()
+	if _, exist := registry[m.Name]; exist {
+		return fmt.Errorf("metric %s is already registered", m.Name)
+	}
+	if m.Aggregation == AggRatio {
+		for _, dep := range []string{m.Numerator, m.Denominator} {
+			d, exist := registry[dep]
+			if !exist {
+				return fmt.Errorf("metric %s: unknown metric %s", m.Name, dep)
+			}
+			if d.Type != TypeNumber {
+				return fmt.Errorf("metric %s: %s is not numeric", m.Name, dep)
+			}
+		}
+	}
+	registry[m.Name] = &m
+	return nil
+}
+
+// MustRegister is like Register but panics on error, it's meant to be used
+// from init functions.
+func MustRegister(m Metric) {
+	if err := Register(m); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the metric with the given (case insensitive) name.
+func Lookup(name string) (*Metric, bool) {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+	m, ok := registry[strings.ToUpper(name)]
+	return m, ok
+}
+
+// All returns every registered metric, sorted by name.
+func All() []Metric {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+	res := make([]Metric, 0, len(registry))
+	for _, m := range registry {
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// Compute aggregates the metric over the given rows.
+// Numeric metrics always return a float64, string metrics a string. A ratio
+// without denominator is 0 when its numerator is 0 too, and infinite
+// otherwise: a spend without conversions is an infinite CPA.
+func (m *Metric) Compute(rows []Row) (any, error) {
+	switch m.Aggregation {
+	case AggRatio:
+		num, err := valueOf(m.Numerator, rows)
+		if err != nil {
+			return nil, err
+		}
+		den, err := valueOf(m.Denominator, rows)
+		if err != nil {
+			return nil, err
+		}
+		if den == 0 {
+			switch {
+			case num > 0:
+				return math.Inf(1), nil
+			case num < 0:
+				return math.Inf(-1), nil
+			}
+			return float64(0), nil
+		}
+		scale := m.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		return num / den * scale, nil
+	case AggSum, AggAvg:
+		res, count := float64(0), 0
+		for _, r := range rows {
+			v, ok := m.Extract(r)
+			if !ok {
+				continue
+			}
+			f, err := toFloat64(v)
+			if err != nil {
+				return nil, fmt.Errorf("metric %s: %w", m.Name, err)
+			}
+			res += f
+			count++
+		}
+		if len(rows) > 0 && count == 0 {
+			return nil, fmt.Errorf("field %s is not available for this entity", m.Name)
+		}
+		if m.Aggregation == AggAvg && count > 0 {
+			res /= float64(count)
+		}
+		return res, nil
+	case AggUnique:
+		var res any
+		for idx, r := range rows {
+			v, ok := m.Extract(r)
+			if !ok {
+				return nil, fmt.Errorf("field %s is not available for this entity", m.Name)
+			}
+			if idx > 0 && v != res {
+				return nil, fmt.Errorf("field %s has several values for this entity", m.Name)
+			}
+			res = v
+		}
+		if res == nil {
+			if m.Type == TypeString {
+				return "", nil
+			}
+			return float64(0), nil
+		}
+		if m.Type == TypeNumber {
+			return toFloat64(res)
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("metric %s: unknown aggregation", m.Name)
+}
+
+func valueOf(name string, rows []Row) (float64, error) {
+	dep, ok := Lookup(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown metric %s", name)
+	}
+	v, err := dep.Compute(rows)
+	if err != nil {
+		return 0, err
+	}
+	return toFloat64(v)
+}
+
+func toFloat64(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint16:
+		return float64(n), nil
+	case uint32:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
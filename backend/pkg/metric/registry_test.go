@@ -0,0 +1,132 @@
+package metric
+
+import (
+	"math"
+	"testing"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+func TestLookup(t *testing.T) {
+	m, ok := Lookup("daily_spend")
+	if !ok {
+		t.Fatal("expected DAILY_SPEND to be registered")
+	}
+	if m.Name != "DAILY_SPEND" || m.Type != TypeNumber || m.Unit != UnitCurrency {
+		t.Fatalf("unexpected metric %+v", m)
+	}
+	if _, ok := Lookup("unknown"); ok {
+		t.Fatal("unexpected metric")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(Metric{Name: "daily_spend", Type: TypeNumber, Extract: func(r Row) (any, bool) { return 0, true }}); err == nil {
+		t.Fatal("expected an error for a duplicated metric")
+	}
+	if err := Register(Metric{Name: "TEST_NO_EXTRACTOR", Type: TypeNumber}); err == nil {
+		t.Fatal("expected an error for a metric without extractor")
+	}
+	if err := Register(Metric{Name: "TEST_RATIO", Type: TypeNumber, Aggregation: AggRatio, Numerator: "DAILY_SPEND", Denominator: "MISSING"}); err == nil {
+		t.Fatal("expected an error for a ratio of an unknown metric")
+	}
+	if err := Register(Metric{Name: "TEST_STRING_SUM", Type: TypeString, Aggregation: AggSum, Extract: func(r Row) (any, bool) { return "", true }}); err == nil {
+		t.Fatal("expected an error for a string metric summed up")
+	}
+}
+
+func TestCompute(t *testing.T) {
+	MustRegister(Metric{
+		Name:        "TEST_SPEND_PER_CAMPAIGN",
+		Type:        TypeNumber,
+		Aggregation: AggRatio,
+		Numerator:   "DAILY_SPEND",
+		Denominator: "NUMBER_OF_CAMPAIGNS",
+	})
+	MustRegister(Metric{
+		Name:        "TEST_AVG_SPEND",
+		Type:        TypeNumber,
+		Aggregation: AggAvg,
+		Extract:     fromAccount(func(a *db.DbAccountSpend) any { return a.Spend }),
+	})
+	rows := AccountRows([]db.DbAccountSpend{
+		{AccountID: "a1", BusinessID: "b1", Spend: 90, NumberOfCampaigns: 1},
+		{AccountID: "a2", BusinessID: "b1", Spend: 10, NumberOfCampaigns: 9},
+	})
+
+	cases := []struct {
+		name     string
+		expected any
+	}{
+		{name: "DAILY_SPEND", expected: float64(100)},
+		{name: "TEST_AVG_SPEND", expected: float64(50)},
+		// weighted by the number of campaigns, not the average of 90 and 1.11
+		{name: "TEST_SPEND_PER_CAMPAIGN", expected: float64(10)},
+		{name: "BUSINESS_ID", expected: "b1"},
+	}
+	for _, c := range cases {
+		m, _ := Lookup(c.name)
+		v, err := m.Compute(rows)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if v != c.expected {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.expected, v)
+		}
+	}
+
+	for _, name := range []string{"ACCOUNT_ID", "CAMPAIGN_NAME"} {
+		m, _ := Lookup(name)
+		if _, err := m.Compute(rows); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDerivedMetrics(t *testing.T) {
+	rows := AccountRows([]db.DbAccountSpend{
+		{AccountID: "a1", Spend: 100, Impressions: 10000, Clicks: 50, Conversions: 4, PurchaseValue: 300},
+		{AccountID: "a2", Spend: 20, Impressions: 30000, Clicks: 10, Conversions: 1, PurchaseValue: 0},
+		{AccountID: "a3"},
+	})
+	cases := []struct {
+		name     string
+		expected float64
+	}{
+		// weighted on the sums, not averaged per account
+		{name: "AVG_CPC", expected: 2},
+		{name: "AVG_CPM", expected: 3},
+		{name: "CTR", expected: 0.15},
+		{name: "CPA", expected: 24},
+		{name: "ROAS", expected: 2.5},
+	}
+	for _, c := range cases {
+		m, ok := Lookup(c.name)
+		if !ok {
+			t.Fatalf("%s is not registered", c.name)
+		}
+		v, err := m.Compute(rows)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if f := v.(float64); f < c.expected-1e-9 || f > c.expected+1e-9 {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.expected, f)
+		}
+	}
+
+	// no delivery means no ratio rather than a division by zero
+	m, _ := Lookup("AVG_CPC")
+	v, err := m.Compute(AccountRows([]db.DbAccountSpend{{AccountID: "a3"}}))
+	if err != nil || v != float64(0) {
+		t.Fatalf("expected 0, got %v (%v)", v, err)
+	}
+
+	// a spend without clicks or conversions is an infinite cost
+	for _, name := range []string{"AVG_CPC", "CPA"} {
+		m, _ := Lookup(name)
+		v, err := m.Compute(AccountRows([]db.DbAccountSpend{{AccountID: "a1", Spend: 100, Impressions: 1000}}))
+		if err != nil || !math.IsInf(v.(float64), 1) {
+			t.Fatalf("%s: expected +Inf, got %v (%v)", name, v, err)
+		}
+	}
+}
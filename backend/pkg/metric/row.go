@@ -0,0 +1,59 @@
+package metric
+
+import "github.com/s0und0fs1lence/ads-zero/pkg/db"
+
+// Row is a single fetched row, either at account or at campaign level.
+// Exactly one of the two fields is set.
+type Row struct {
+	Account  *db.DbAccountSpend
+	Campaign *db.DbCampaignSpend
+}
+
+func AccountRows(data []db.DbAccountSpend) []Row {
+	res := make([]Row, len(data))
+	for idx := range data {
+		res[idx] = Row{Account: &data[idx]}
+	}
+	return res
+}
+
+func CampaignRows(data []db.DbCampaignSpend) []Row {
+	res := make([]Row, len(data))
+	for idx := range data {
+		res[idx] = Row{Campaign: &data[idx]}
+	}
+	return res
+}
+
+// fromBoth builds an extractor for a field present on both row levels.
+func fromBoth(account func(a *db.DbAccountSpend) any, campaign func(c *db.DbCampaignSpend) any) func(r Row) (any, bool) {
+	return func(r Row) (any, bool) {
+		if r.Campaign != nil {
+			return campaign(r.Campaign), true
+		}
+		if r.Account != nil {
+			return account(r.Account), true
+		}
+		return nil, false
+	}
+}
+
+// fromAccount builds an extractor for a field only present on account rows.
+func fromAccount(account func(a *db.DbAccountSpend) any) func(r Row) (any, bool) {
+	return func(r Row) (any, bool) {
+		if r.Account == nil {
+			return nil, false
+		}
+		return account(r.Account), true
+	}
+}
+
+// fromCampaign builds an extractor for a field only present on campaign rows.
+func fromCampaign(campaign func(c *db.DbCampaignSpend) any) func(r Row) (any, bool) {
+	return func(r Row) (any, bool) {
+		if r.Campaign == nil {
+			return nil, false
+		}
+		return campaign(r.Campaign), true
+	}
+}
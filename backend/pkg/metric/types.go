@@ -0,0 +1,89 @@
+package metric
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Type is the kind of value a metric resolves to.
+type Type uint8
+
+const (
+	TypeNumber Type = iota
+	TypeString
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeNumber:
+		return "NUMBER"
+	case TypeString:
+		return "STRING"
+	}
+	return "UNKNOWN"
+}
+
+func (t Type) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToLower(t.String()))
+}
+
+// Unit describes how a numeric metric should be displayed.
+type Unit uint8
+
+const (
+	UnitNone Unit = iota
+	UnitCurrency
+	UnitCount
+	UnitPercent
+)
+
+func (u Unit) String() string {
+	switch u {
+	case UnitNone:
+		return "NONE"
+	case UnitCurrency:
+		return "CURRENCY"
+	case UnitCount:
+		return "COUNT"
+	case UnitPercent:
+		return "PERCENT"
+	}
+	return "UNKNOWN"
+}
+
+func (u Unit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToLower(u.String()))
+}
+
+// Aggregation is how the values of several rows are combined into one.
+type Aggregation uint8
+
+const (
+	// AggSum adds up the value of every row
+	AggSum Aggregation = iota
+	// AggAvg is the mean of the value of every row
+	AggAvg
+	// AggRatio divides the sum of the Numerator metric by the sum of the
+	// Denominator metric, so that the result is weighted by the denominator
+	AggRatio
+	// AggUnique is used by descriptive fields: every row must share the same value
+	AggUnique
+)
+
+func (a Aggregation) String() string {
+	switch a {
+	case AggSum:
+		return "SUM"
+	case AggAvg:
+		return "AVG"
+	case AggRatio:
+		return "RATIO"
+	case AggUnique:
+		return "UNIQUE"
+	}
+	return "UNKNOWN"
+}
+
+func (a Aggregation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToLower(a.String()))
+}
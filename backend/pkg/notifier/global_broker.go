@@ -6,6 +6,7 @@ type globalBroker struct {
 	mailBroker  *mailBroker
 	tgBroker    *telegramBroker
 	slackBroker *slackBroker
+	hookBroker  *webhookBroker
 }
 
 func NewMessageBroker() (MessageBroker, error) {
@@ -15,6 +16,7 @@ func NewMessageBroker() (MessageBroker, error) {
 	tg, _ := newTelegramBroker()
 	mb.tgBroker = tg
 	mb.slackBroker = newSlackBroker()
+	mb.hookBroker = newWebhookBroker()
 	return mb, nil
 }
 
@@ -40,6 +42,11 @@ func (g *globalBroker) SendNotification(n *Notification) error {
 			return errors.New("slack broker is not initialized")
 		}
 		return g.slackBroker.sendNotification(n)
+	case "webhook":
+		if g.hookBroker == nil {
+			return errors.New("webhook broker is not initialized")
+		}
+		return g.hookBroker.sendNotification(n)
 	default:
 		return errors.New("unknown destination type")
 	}
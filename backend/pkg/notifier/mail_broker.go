@@ -19,6 +19,15 @@ type mailTemplateData struct {
 	Threshold    any
 	CurrentSpend float64
 	User         string
+	EntityType   string
+	EntityID     string
+	EntityName   string
+	Metric       string
+	Value        any
+	Baseline     any
+	HasBaseline  bool
+	Reminder     bool
+	Resolved     bool
 }
 
 func newMailBroker() (*mailBroker, error) {
@@ -66,19 +75,41 @@ func (m *mailBroker) sendNotification(n *Notification) error {
 		Threshold:    n.Threshold,
 		CurrentSpend: n.CurrentSpend,
 		User:         n.UserMail,
+		EntityType:   n.EntityType,
+		EntityID:     n.EntityID,
+		EntityName:   n.EntityName,
+		Metric:       n.Metric,
+		Value:        n.Value,
+		Baseline:     n.Baseline,
+		HasBaseline:  n.Baseline != nil,
+		Reminder:     n.Reminder,
+		Resolved:     n.Resolved,
+	}
+	if n.EntityType == "CLIENT" {
+		data.EntityName = ""
 	}
 	message := mail.NewMsg()
 	if err := message.From(m.fromEmail); err != nil {
 		return err
 	}
-	if err := message.To(n.UserMail); err != nil {
+	// the notifications routed to the mail carry their address
+	to := n.Dest
+	if to == "" {
+		to = n.UserMail
+	}
+	if err := message.To(to); err != nil {
 		return err
 	}
-	if err := message.AddAlternativeHTMLTemplate(m.htmlTemplate, data); err != nil {
+	if len(n.Digest) > 0 {
+		message.SetBodyString(mail.TypeTextPlain, n.Text())
+	} else if err := message.AddAlternativeHTMLTemplate(m.htmlTemplate, data); err != nil {
 		return err
 	}
 	if n.Subject == "" {
 		n.Subject = "Alert: You have reached your spending threshold"
+		if n.Severity == "CRITICAL" {
+			n.Subject = "Critical alert: You have reached your spending threshold"
+		}
 	}
 	message.Subject(n.Subject)
 	// message.SetBodyString(mail.TypeTextPlain, "This will be the content of the mail.")
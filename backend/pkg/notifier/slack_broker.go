@@ -3,7 +3,6 @@ package notifier
 import (
 	"context"
 	"errors"
-	"fmt"
 
 	"github.com/slack-go/slack"
 )
@@ -28,6 +27,6 @@ func (m *slackBroker) sendNotification(n *Notification) error {
 		return errors.New("slack broker is not valid")
 	}
 	return slack.PostWebhookContext(m.ctx, n.Dest, &slack.WebhookMessage{
-		Text: fmt.Sprintf("Threshold: %v\nCurrentSpend: %v\nUser: %v", n.Threshold, n.CurrentSpend, n.UserMail),
+		Text: n.Text(),
 	})
 }
@@ -37,7 +37,7 @@ func (m *telegramBroker) sendNotification(n *Notification) error {
 	//TODO: Implement telegram notification
 
 	msg, err := m.client.SendMessage(m.ctx, &bot.SendMessageParams{
-		Text:   fmt.Sprintf("Threshold: %v\nCurrentSpend: %v\nUser: %v", n.Threshold, n.CurrentSpend, n.UserMail),
+		Text:   n.Text(),
 		ChatID: n.Dest,
 	})
 	if err != nil {
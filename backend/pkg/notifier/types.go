@@ -1,18 +1,100 @@
 package notifier
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Notification struct {
 	Subject      string
+	Severity     string // INFO, WARNING or CRITICAL
 	UserMail     string
 	CurrentSpend float64
 	Threshold    any
 	RuleName     string
 	RuleID       string
-	DestType     string
+	// the entity that triggered the rule (CLIENT, ACCOUNT, CAMPAIGN...)
+	EntityType string
+	EntityID   string
+	EntityName string
+	// the metric the rule compared, with its baseline for period over
+	// period rules
+	Metric   string
+	Value    any
+	Baseline any
+	// Reminder is set when the notification of a firing alert is sent
+	// again, Resolved when the alert stopped firing
+	Reminder bool
+	Resolved bool
+	DestType string
 	// Expected values:
 	// - if DestType is "mail", then Dest is the email address
 	// - if DestType is "slack", then Dest is the slack webhook
 	// - if DestType is "telegram", then Dest is the telegram chat id
+	// - if DestType is "webhook", then Dest is the url the JSON is posted to
 	Dest string
+	// Digest are the notifications grouped in a digest or a combined
+	// notification, the other fields are ignored
+	Digest []*Notification
+}
+
+// Destination is where a notification is sent to, see DestType and Dest.
+type Destination struct {
+	Type string
+	Dest string
+}
+
+// NewDigest returns the digest of the notifications for the destination.
+func NewDigest(notifications []*Notification, dest Destination) *Notification {
+	return &Notification{
+		Subject:  fmt.Sprintf("Daily digest: %d alerts", len(notifications)),
+		DestType: dest.Type,
+		Dest:     dest.Dest,
+		Digest:   notifications,
+	}
+}
+
+// NewGroup returns the notification combining the notifications for the
+// destination.
+func NewGroup(notifications []*Notification, dest Destination) *Notification {
+	return &Notification{
+		Subject:  fmt.Sprintf("Alert: %d rules triggered", len(notifications)),
+		Severity: notifications[0].Severity,
+		DestType: dest.Type,
+		Dest:     dest.Dest,
+		Digest:   notifications,
+	}
+}
+
+// Text returns the plain text body of the notification.
+func (n *Notification) Text() string {
+	if len(n.Digest) > 0 {
+		texts := make([]string, 0, len(n.Digest)+1)
+		texts = append(texts, n.Subject)
+		for _, item := range n.Digest {
+			texts = append(texts, item.Text())
+		}
+		return strings.Join(texts, "\n\n")
+	}
+	text := fmt.Sprintf("Rule: %v\nThreshold: %v\nCurrentSpend: %v", n.RuleName, n.Threshold, n.CurrentSpend)
+	if n.Severity != "" {
+		text = fmt.Sprintf("[%s] %s", n.Severity, text)
+	}
+	if n.Resolved {
+		text = "Resolved, the rule no longer matches.\n" + text
+	} else if n.Reminder {
+		text = "Reminder, the rule is still matching.\n" + text
+	}
+	if n.Baseline != nil {
+		text += fmt.Sprintf("\n%s: %v (baseline: %v)", n.Metric, n.Value, n.Baseline)
+	}
+	if n.EntityType != "" && n.EntityType != "CLIENT" {
+		text += fmt.Sprintf("\n%s: %s (%s)", n.EntityType, n.EntityName, n.EntityID)
+	}
+	if n.UserMail != "" {
+		text += fmt.Sprintf("\nUser: %v", n.UserMail)
+	}
+	return text
 }
 
 type MessageBroker interface {
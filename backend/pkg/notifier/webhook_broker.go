@@ -0,0 +1,94 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookPayload is the JSON body posted to the generic webhooks.
+type webhookPayload struct {
+	Text         string           `json:"text"`
+	Severity     string           `json:"severity,omitempty"`
+	RuleID       string           `json:"rule_id,omitempty"`
+	RuleName     string           `json:"rule_name,omitempty"`
+	EntityType   string           `json:"entity_type,omitempty"`
+	EntityID     string           `json:"entity_id,omitempty"`
+	EntityName   string           `json:"entity_name,omitempty"`
+	Metric       string           `json:"metric,omitempty"`
+	Value        any              `json:"value,omitempty"`
+	Baseline     any              `json:"baseline,omitempty"`
+	Threshold    any              `json:"threshold,omitempty"`
+	CurrentSpend float64          `json:"current_spend"`
+	Reminder     bool             `json:"reminder"`
+	Resolved     bool             `json:"resolved"`
+	Digest       []webhookPayload `json:"digest,omitempty"`
+}
+
+func newWebhookPayload(n *Notification) webhookPayload {
+	payload := webhookPayload{
+		Text:         n.Text(),
+		Severity:     n.Severity,
+		RuleID:       n.RuleID,
+		RuleName:     n.RuleName,
+		EntityType:   n.EntityType,
+		EntityID:     n.EntityID,
+		EntityName:   n.EntityName,
+		Metric:       n.Metric,
+		Value:        n.Value,
+		Baseline:     n.Baseline,
+		Threshold:    n.Threshold,
+		CurrentSpend: n.CurrentSpend,
+		Reminder:     n.Reminder,
+		Resolved:     n.Resolved,
+	}
+	for _, item := range n.Digest {
+		payload.Digest = append(payload.Digest, newWebhookPayload(item))
+	}
+	return payload
+}
+
+type webhookBroker struct {
+	ctx    context.Context
+	client *http.Client
+}
+
+func newWebhookBroker() *webhookBroker {
+	return &webhookBroker{
+		ctx:    context.Background(),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (m *webhookBroker) isValid() bool {
+	return m.client != nil
+}
+
+// sendNotification posts the notification as JSON to the webhook url.
+func (m *webhookBroker) sendNotification(n *Notification) error {
+	if !m.isValid() {
+		return errors.New("webhook broker is not valid")
+	}
+	body, err := json.Marshal(newWebhookPayload(n))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, n.Dest, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook replied with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookBroker(t *testing.T) {
+	var received webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	broker := &globalBroker{hookBroker: newWebhookBroker()}
+	err := broker.SendNotification(&Notification{
+		Severity:     "CRITICAL",
+		RuleID:       "r1",
+		RuleName:     "overspend",
+		EntityType:   "ACCOUNT",
+		EntityID:     "a1",
+		CurrentSpend: 150,
+		Threshold:    100.0,
+		Resolved:     true,
+		DestType:     "webhook",
+		Dest:         server.URL + "/hook",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.RuleID != "r1" || received.Severity != "CRITICAL" || !received.Resolved || received.CurrentSpend != 150 || received.Text == "" {
+		t.Fatalf("unexpected payload %+v", received)
+	}
+
+	if err := broker.SendNotification(&Notification{DestType: "webhook", Dest: server.URL + "/fail"}); err == nil {
+		t.Fatal("expected an error for a failed delivery")
+	}
+}
@@ -0,0 +1,303 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+)
+
+// Anomaly detection methods.
+const (
+	// mean and standard deviation of the history
+	AnomalyStdDev = "stddev"
+	// median and median absolute deviation, more robust to past outliers
+	AnomalyMAD = "mad"
+)
+
+// Anomaly directions.
+const (
+	AnomalyUp   = "up"
+	AnomalyDown = "down"
+	AnomalyBoth = "both"
+)
+
+// madScale makes the median absolute deviation comparable to a standard
+// deviation on normally distributed values.
+const madScale = 1.4826
+
+// AnomalyParams configures an anomaly rule, it's stored as JSON in the
+// `params` column of client_rules. Zero values take the defaults.
+type AnomalyParams struct {
+	Column string `json:"column"`
+	// Method is AnomalyStdDev (default) or AnomalyMAD.
+	Method string `json:"method,omitempty"`
+	// Sensitivity is the number of deviations from the center of the history
+	// the current value must exceed, 3 by default.
+	Sensitivity float64 `json:"sensitivity,omitempty"`
+	// Days is the length of the history, 28 by default.
+	Days int `json:"days,omitempty"`
+	// SameWeekday only compares with the same day of the previous weeks.
+	SameWeekday bool `json:"same_weekday,omitempty"`
+	// SameHour compares with the values observed at the same time of the
+	// previous days instead of the whole days, which is what additive
+	// metrics like the spend need during the day.
+	SameHour bool `json:"same_hour,omitempty"`
+	// MinHistory is the number of past values required to evaluate the
+	// rule, 7 by default (3 when comparing the same weekday).
+	MinHistory int `json:"min_history,omitempty"`
+	// Direction is AnomalyUp, AnomalyDown or AnomalyBoth (default).
+	Direction string `json:"direction,omitempty"`
+}
+
+// withDefaults validates the params and fills the missing ones.
+func (p AnomalyParams) withDefaults() (AnomalyParams, error) {
+	column := ColumnFromString(p.Column)
+	if column == INVALID {
+		return p, fmt.Errorf("invalid column %q", p.Column)
+	}
+	if err := validateWindowColumn(column); err != nil {
+		return p, err
+	}
+	p.Column = column.String()
+
+	p.Method = strings.ToLower(p.Method)
+	switch p.Method {
+	case "":
+		p.Method = AnomalyStdDev
+	case AnomalyStdDev, AnomalyMAD:
+	default:
+		return p, fmt.Errorf("invalid anomaly method %q", p.Method)
+	}
+	p.Direction = strings.ToLower(p.Direction)
+	switch p.Direction {
+	case "":
+		p.Direction = AnomalyBoth
+	case AnomalyUp, AnomalyDown, AnomalyBoth:
+	default:
+		return p, fmt.Errorf("invalid anomaly direction %q", p.Direction)
+	}
+
+	if p.Sensitivity == 0 {
+		p.Sensitivity = 3
+	}
+	if p.Sensitivity < 0 {
+		return p, fmt.Errorf("sensitivity must be positive")
+	}
+	if p.Days == 0 {
+		p.Days = 28
+	}
+	if p.Days < 2 || p.Days > MaxWindowDays {
+		return p, fmt.Errorf("history must be between 2 and %d days", MaxWindowDays)
+	}
+	available := p.Days
+	if p.SameWeekday {
+		available = p.Days / 7
+	}
+	if p.MinHistory == 0 {
+		p.MinHistory = min(7, available)
+		if p.SameWeekday {
+			p.MinHistory = min(3, available)
+		}
+	}
+	if p.MinHistory < 2 {
+		return p, fmt.Errorf("at least 2 past values are required, the history is too short")
+	}
+	if p.MinHistory > available {
+		return p, fmt.Errorf("a history of %d days cannot have %d values", p.Days, p.MinHistory)
+	}
+	return p, nil
+}
+
+// anomalyRule fires when the current value of a metric deviates from its
+// history by more than Sensitivity deviations.
+type anomalyRule struct {
+	ruleInfo
+	params AnomalyParams
+	column Column
+}
+
+// NewAnomalyRule returns an anomaly rule, the params are validated.
+func NewAnomalyRule(params AnomalyParams, name, id string) (Rule, error) {
+	p, err := params.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	return &anomalyRule{
+		ruleInfo: newRuleInfo(name, id),
+		params:   p,
+		column:   Column(p.Column),
+	}, nil
+}
+
+// ParseAnomalyParams decodes the JSON params of an anomaly rule.
+func ParseAnomalyParams(data string) (AnomalyParams, error) {
+	var p AnomalyParams
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return p, fmt.Errorf("invalid anomaly params: %w", err)
+	}
+	return p, nil
+}
+
+// Value implements Rule.
+func (a *anomalyRule) Value() interface{} {
+	center := "mean"
+	if a.params.Method == AnomalyMAD {
+		center = "median"
+	}
+	period := fmt.Sprintf("%d days", a.params.Days)
+	if a.params.SameWeekday {
+		period += ", same weekday"
+	}
+	if a.params.SameHour {
+		period += ", same hour"
+	}
+	return fmt.Sprintf("%s %v deviations %s the %s (%s)",
+		strings.ToLower(a.params.Column), a.params.Sensitivity, directionText(a.params.Direction), center, period)
+}
+
+func directionText(direction string) string {
+	switch direction {
+	case AnomalyUp:
+		return "above"
+	case AnomalyDown:
+		return "below"
+	}
+	return "from"
+}
+
+// Exec implements Rule.
+func (a *anomalyRule) Exec(evt common.Event) (bool, error) {
+	h, ok := evt.(common.HistoricalEvent)
+	if !ok {
+		return false, fmt.Errorf("historical data is not available")
+	}
+	value, err := evt.GetFieldValue(a.column.String())
+	if err != nil {
+		return false, err
+	}
+	current, ok := value.(float64)
+	if !ok {
+		return false, fmt.Errorf("field %s is not numeric", a.column)
+	}
+	observe(evt, a, a.column, current)
+
+	history, err := a.history(h)
+	if err != nil {
+		return false, err
+	}
+	if len(history) < a.params.MinHistory {
+		// not enough history to tell what's normal
+		return false, nil
+	}
+	center, spread := meanStdDev(history)
+	if a.params.Method == AnomalyMAD {
+		center, spread = medianMAD(history)
+	}
+	observeBaseline(evt, a, center)
+
+	score := deviations(current, center, spread)
+	switch a.params.Direction {
+	case AnomalyUp:
+		return score > a.params.Sensitivity, nil
+	case AnomalyDown:
+		return score < -a.params.Sensitivity, nil
+	}
+	return math.Abs(score) > a.params.Sensitivity, nil
+}
+
+// history returns the past values of the column, the fetched day excluded.
+// The days are the ones of the entity, as the snapshots and the spend rows;
+// the infinite ratios of the days without denominator have no place in a
+// mean and are left out.
+func (a *anomalyRule) history(h common.HistoricalEvent) ([]float64, error) {
+	loc, err := entityLocation(h, "")
+	if err != nil {
+		return nil, err
+	}
+	now := h.FetchedAt().In(loc)
+	res := make([]float64, 0, a.params.Days)
+	if a.params.SameHour {
+		for i := 1; i <= a.params.Days; i++ {
+			if a.params.SameWeekday && i%7 != 0 {
+				continue
+			}
+			v, ok, err := h.GetFieldAt(a.column.String(), now.AddDate(0, 0, -i))
+			if err != nil {
+				return nil, err
+			}
+			if ok && !math.IsInf(v, 0) {
+				res = append(res, v)
+			}
+		}
+		return res, nil
+	}
+
+	series, err := h.GetFieldSeries(a.column.String(), a.params.Days+1)
+	if err != nil {
+		return nil, err
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, p := range series {
+		if !p.Day.Before(today) || math.IsInf(p.Value, 0) {
+			continue
+		}
+		if a.params.SameWeekday && p.Day.Weekday() != today.Weekday() {
+			continue
+		}
+		res = append(res, p.Value)
+	}
+	return res, nil
+}
+
+// deviations returns how many spreads the value is from the center, a
+// value different from a constant history is an infinite deviation.
+func deviations(value, center, spread float64) float64 {
+	diff := value - center
+	if spread == 0 {
+		if diff == 0 {
+			return 0
+		}
+		return math.Inf(int(math.Copysign(1, diff)))
+	}
+	return diff / spread
+}
+
+// meanStdDev returns the mean and the sample standard deviation.
+func meanStdDev(values []float64) (mean, stddev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	for _, v := range values {
+		stddev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(values)-1))
+}
+
+// medianMAD returns the median and the scaled median absolute deviation.
+func medianMAD(values []float64) (median, mad float64) {
+	median = medianOf(values)
+	deviations := make([]float64, 0, len(values))
+	for _, v := range values {
+		deviations = append(deviations, math.Abs(v-median))
+	}
+	return median, medianOf(deviations) * madScale
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
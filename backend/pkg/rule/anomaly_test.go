@@ -0,0 +1,166 @@
+package rule
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+// anomalyTask returns a task fetched on a monday with 28 days of history:
+// a1 spends 90 or 110 a day (200 on mondays), a2 only has 3 days of history.
+func anomalyTask(a1Today, a2Today float64) *common.FetchTask {
+	today := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	h := &testHistory{}
+	for i := 1; i <= 28; i++ {
+		day := today.AddDate(0, 0, -i)
+		spend := float64(90 + 20*(i%2))
+		if day.Weekday() == time.Monday {
+			spend = 200
+		}
+		h.accounts = append(h.accounts, db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: spend, DateRef: day})
+		if i <= 3 {
+			h.accounts = append(h.accounts, db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a2", Spend: 10, DateRef: day})
+		}
+	}
+	task := common.NewFetchTask(today, today.Add(12*time.Hour))
+	task.Accounts = append(task.Accounts,
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: a1Today, DateRef: today},
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a2", Spend: a2Today, DateRef: today},
+	)
+	return task.WithHistory("c1", h)
+}
+
+func TestAnomalyRule(t *testing.T) {
+	cases := []struct {
+		name     string
+		params   string
+		a1Today  float64
+		entities []string
+	}{
+		{name: "normal day", params: `{"column":"daily_spend"}`, a1Today: 120, entities: []string{}},
+		{name: "spike", params: `{"column":"daily_spend"}`, a1Today: 400, entities: []string{"a1"}},
+		// a2 spends 1000 but has not enough history to be evaluated
+		{name: "min history", params: `{"column":"daily_spend","min_history":4}`, a1Today: 100, entities: []string{}},
+		{name: "drop", params: `{"column":"daily_spend","direction":"down"}`, a1Today: 0, entities: []string{"a1"}},
+		{name: "spike ignored when looking for drops", params: `{"column":"daily_spend","direction":"down"}`, a1Today: 400, entities: []string{}},
+		// 200 is normal on mondays
+		{name: "same weekday", params: `{"column":"daily_spend","same_weekday":true}`, a1Today: 200, entities: []string{}},
+		{name: "same weekday spike", params: `{"column":"daily_spend","same_weekday":true}`, a1Today: 120, entities: []string{"a1"}},
+		// the mondays are outliers for the mean, not for the median
+		{name: "mad", params: `{"column":"daily_spend","method":"mad","sensitivity":2}`, a1Today: 180, entities: []string{"a1"}},
+		{name: "stddev", params: `{"column":"daily_spend","method":"stddev","sensitivity":2}`, a1Today: 180, entities: []string{}},
+	}
+	for _, c := range cases {
+		r, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "anomaly", Params: c.params, Scope: "account"})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		results, err := Execute(r, anomalyTask(c.a1Today, 1000))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(results) != len(c.entities) {
+			t.Fatalf("%s: expected %d results, got %d", c.name, len(c.entities), len(results))
+		}
+		for idx, res := range results {
+			if res.EntityID != c.entities[idx] {
+				t.Fatalf("%s: expected entity %s, got %s", c.name, c.entities[idx], res.EntityID)
+			}
+			if res.Value != c.a1Today || res.Baseline == nil {
+				t.Fatalf("%s: unexpected result %+v", c.name, res)
+			}
+		}
+	}
+}
+
+func TestAnomalyRuleInTimezone(t *testing.T) {
+	cases := []struct {
+		name     string
+		timezone string
+		// the local time of the fetch, on another day in UTC
+		fetchedAt time.Time
+		params    string
+		baseline  float64
+	}{
+		// the current day is not part of the history
+		{name: "daily", timezone: "America/Los_Angeles", fetchedAt: time.Date(2024, 5, 20, 19, 0, 0, 0, time.UTC), params: `{"column":"daily_spend"}`, baseline: 100},
+		// the snapshots are the ones of the days of the account
+		{name: "same hour", timezone: "Asia/Tokyo", fetchedAt: time.Date(2024, 5, 21, 8, 0, 0, 0, time.UTC), params: `{"column":"daily_spend","same_hour":true}`, baseline: 10},
+	}
+	for _, c := range cases {
+		loc, err := time.LoadLocation(c.timezone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fetchedAt := time.Date(c.fetchedAt.Year(), c.fetchedAt.Month(), c.fetchedAt.Day(), c.fetchedAt.Hour(), 0, 0, 0, loc)
+		today := time.Date(fetchedAt.Year(), fetchedAt.Month(), fetchedAt.Day(), 0, 0, 0, 0, time.UTC)
+		h := &testHistory{}
+		for i := 1; i <= 10; i++ {
+			day := today.AddDate(0, 0, -i)
+			row := db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: float64(90 + 20*(i%2)), Timezone: c.timezone, DateRef: day}
+			h.accounts = append(h.accounts, row)
+			// the spend of the end of the day and of the hour before the fetch
+			row.UpdatedAt = time.Date(day.Year(), day.Month(), day.Day(), 23, 30, 0, 0, loc)
+			h.accountSnapshots = append(h.accountSnapshots, row)
+			row.Spend /= 10
+			row.UpdatedAt = time.Date(day.Year(), day.Month(), day.Day(), fetchedAt.Hour()-1, 30, 0, 0, loc)
+			h.accountSnapshots = append(h.accountSnapshots, row)
+		}
+		task := common.NewFetchTask(fetchedAt.UTC(), fetchedAt.UTC())
+		task.Accounts = append(task.Accounts, db.DbAccountSpend{
+			ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: 400, Timezone: c.timezone, DateRef: today,
+		})
+		task.WithHistory("c1", h)
+
+		r, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "anomaly", Params: c.params, Scope: "account"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := Execute(r, task)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(results) != 1 || results[0].Baseline != c.baseline {
+			t.Fatalf("%s: expected the spike of a1 over %v, got %+v", c.name, c.baseline, results)
+		}
+	}
+}
+
+func TestAnomalyParams(t *testing.T) {
+	invalid := []string{
+		`{"column":"campaign_name"}`,
+		`{"column":"unknown"}`,
+		`{"column":"daily_spend","method":"zscore"}`,
+		`{"column":"daily_spend","direction":"sideways"}`,
+		`{"column":"daily_spend","days":1}`,
+		`{"column":"daily_spend","days":14,"min_history":20}`,
+		`{"column":"daily_spend","days":7,"same_weekday":true}`,
+		`{"column":"daily_spend","sensitivity":-1}`,
+		`not json`,
+	}
+	for _, params := range invalid {
+		if _, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "ANOMALY", Params: params}); err == nil {
+			t.Fatalf("%s: expected an error", params)
+		}
+	}
+	if _, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "unknown"}); err == nil {
+		t.Fatal("expected an error for an unknown rule type")
+	}
+}
+
+func TestAnomalyStats(t *testing.T) {
+	mean, stddev := meanStdDev([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if mean != 5 || math.Abs(stddev-2.138) > 0.001 {
+		t.Fatalf("unexpected mean %v and stddev %v", mean, stddev)
+	}
+	median, mad := medianMAD([]float64{1, 1, 2, 2, 4, 6, 9})
+	if median != 2 || mad != madScale {
+		t.Fatalf("unexpected median %v and mad %v", median, mad)
+	}
+	if !math.IsInf(deviations(2, 1, 0), 1) || deviations(1, 1, 0) != 0 {
+		t.Fatal("unexpected deviations for a constant history")
+	}
+}
@@ -0,0 +1,101 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+)
+
+// Backtest modes.
+const (
+	// replay the rows stored at the end of each day
+	BacktestDaily = "daily"
+	// replay each snapshot taken during the days, the snapshots are only kept
+	// for a few weeks
+	BacktestSnapshots = "snapshots"
+)
+
+// MaxBacktestDays is the longest period a rule can be backtested on.
+const MaxBacktestDays = MaxWindowDays
+
+// BacktestStep is the interval the snapshots are looked up at, it matches
+// the interval of the worker.
+const BacktestStep = 15 * time.Minute
+
+// BacktestAlert is an alert the rule would have sent.
+type BacktestAlert struct {
+	// At is the time of the fetch the rule matched on.
+	At     time.Time          `json:"at"`
+	Result *common.RuleResult `json:"result"`
+}
+
+// BacktestModeFromString returns the (case insensitive) mode, the daily one
+// when s is empty.
+func BacktestModeFromString(s string) (string, error) {
+	switch mode := strings.ToLower(s); mode {
+	case "":
+		return BacktestDaily, nil
+	case BacktestDaily, BacktestSnapshots:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid backtest mode %q", s)
+}
+
+// Backtest replays the spend stored for the client between the start and
+// end days (included) through the rule, and returns the alerts it would
+// have sent in chronological order.
+func Backtest(r Rule, h common.History, clientID string, start, end time.Time, mode string) ([]BacktestAlert, error) {
+	mode, err := BacktestModeFromString(mode)
+	if err != nil {
+		return nil, err
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	if end.Before(start) {
+		return nil, fmt.Errorf("the end of the backtest is before its start")
+	}
+	if days := int(end.Sub(start).Hours()/24) + 1; days > MaxBacktestDays {
+		return nil, fmt.Errorf("cannot backtest more than %d days", MaxBacktestDays)
+	}
+
+	res := make([]BacktestAlert, 0)
+	replay := func(task *common.FetchTask) error {
+		if task.Empty() {
+			return nil
+		}
+		results, err := Execute(r, task)
+		if err != nil {
+			return fmt.Errorf("%s: %w", task.End.Format(time.DateTime), err)
+		}
+		for _, result := range results {
+			res = append(res, BacktestAlert{At: task.End, Result: result})
+		}
+		return nil
+	}
+
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if mode == BacktestDaily {
+			task, err := common.NewDayReplayTask(h, clientID, day)
+			if err != nil {
+				return nil, err
+			}
+			if err := replay(task); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		tasks, err := common.NewSnapshotReplayTasks(h, clientID, day, BacktestStep)
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range tasks {
+			if err := replay(task); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
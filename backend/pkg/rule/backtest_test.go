@@ -0,0 +1,142 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+// backtestHistory stores 5 days where account a1 spent 100 a day, but 500
+// on the third day, and a2 spent 10 a day. The last day also has snapshots
+// of a1 taken at 6, 12 and 18.
+func backtestHistory() (*testHistory, time.Time) {
+	start := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	h := &testHistory{}
+	for i := 0; i < 5; i++ {
+		day := start.AddDate(0, 0, i)
+		spend := float64(100)
+		if i == 2 {
+			spend = 500
+		}
+		h.accounts = append(h.accounts,
+			db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: spend, DateRef: day, UpdatedAt: day.Add(23 * time.Hour)},
+			db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a2", Spend: 10, DateRef: day, UpdatedAt: day.Add(23 * time.Hour)},
+		)
+	}
+	// an older version of the third day, not merged yet
+	h.accounts = append(h.accounts, db.DbAccountSpend{
+		ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: 50,
+		DateRef: start.AddDate(0, 0, 2), UpdatedAt: start.AddDate(0, 0, 2).Add(time.Hour),
+	})
+
+	last := start.AddDate(0, 0, 4)
+	for hour, spend := range map[int]float64{6: 30, 12: 60, 18: 200} {
+		at := last.Add(time.Duration(hour) * time.Hour)
+		h.accountSnapshots = append(h.accountSnapshots,
+			db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: spend, DateRef: last, UpdatedAt: at},
+		)
+	}
+	return h, start
+}
+
+func TestBacktestDaily(t *testing.T) {
+	cases := []struct {
+		expression string
+		days       []int
+	}{
+		{expression: "daily_spend > 300", days: []int{12}},
+		{expression: "sum(daily_spend, 2d) > 550", days: []int{12, 13}},
+		{expression: "daily_spend < 50", days: []int{10, 11, 12, 13, 14}},
+		{expression: "daily_spend > 1000", days: []int{}},
+	}
+	for _, c := range cases {
+		r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: c.expression, Scope: "account"})
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		h, start := backtestHistory()
+		alerts, err := Backtest(r, h, "c1", start, start.AddDate(0, 0, 4), "")
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		if len(alerts) != len(c.days) {
+			t.Fatalf("%s: expected %d alerts, got %d", c.expression, len(c.days), len(alerts))
+		}
+		for idx, alert := range alerts {
+			if alert.At.Day() != c.days[idx] || alert.At.Hour() != 23 {
+				t.Fatalf("%s: unexpected alert time %s", c.expression, alert.At)
+			}
+		}
+	}
+}
+
+func TestBacktestSnapshots(t *testing.T) {
+	r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: "daily_spend > 50", Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, start := backtestHistory()
+	alerts, err := Backtest(r, h, "c1", start, start.AddDate(0, 0, 4), BacktestSnapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each snapshot is replayed once
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %d", len(alerts))
+	}
+	for idx, hour := range []int{12, 18} {
+		if alerts[idx].At.Hour() != hour {
+			t.Fatalf("expected an alert at %d, got %s", hour, alerts[idx].At)
+		}
+		if alerts[idx].Result.EntityID != "a1" {
+			t.Fatalf("unexpected entity %s", alerts[idx].Result.EntityID)
+		}
+	}
+	if alerts[1].Result.Value != float64(200) {
+		t.Fatalf("unexpected value %v", alerts[1].Result.Value)
+	}
+}
+
+func TestBacktestSnapshotQueries(t *testing.T) {
+	r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: "sum(daily_spend, 2d) > 150", Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, start := backtestHistory()
+	alerts, err := Backtest(r, h, "c1", start, start.AddDate(0, 0, 4), BacktestSnapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 100 the previous day and the snapshots of 60 and 200
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %d", len(alerts))
+	}
+	// the snapshots are loaded once per day, the history once for the
+	// snapshots of the last day
+	if h.snapshotQueries != 5 || h.queries != 1 {
+		t.Fatalf("expected 5 snapshot and 1 history queries, got %d and %d", h.snapshotQueries, h.queries)
+	}
+}
+
+func TestBacktestPeriod(t *testing.T) {
+	r, _ := FromDbRule(db.DbRule{RuleID: "1", Expression: "daily_spend > 1"})
+	h, start := backtestHistory()
+	if _, err := Backtest(r, h, "c1", start, start.AddDate(0, 0, -1), ""); err == nil {
+		t.Fatal("expected an error for an end before the start")
+	}
+	if _, err := Backtest(r, h, "c1", start, start.AddDate(0, 0, MaxBacktestDays), ""); err == nil {
+		t.Fatal("expected an error for a too long period")
+	}
+	if _, err := Backtest(r, h, "c1", start, start, "hourly"); err == nil {
+		t.Fatal("expected an error for an invalid mode")
+	}
+	// days without data don't alert
+	alerts, err := Backtest(r, h, "c1", start.AddDate(0, 0, -10), start.AddDate(0, 0, -1), BacktestDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 0 {
+		t.Fatalf("expected no alerts, got %d", len(alerts))
+	}
+}
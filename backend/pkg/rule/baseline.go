@@ -0,0 +1,123 @@
+package rule
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+)
+
+// Baseline is the period the change operators compare the column with.
+type Baseline string
+
+const (
+	NoBaseline Baseline = ""
+	// the whole previous day
+	BaselinePreviousDay Baseline = "previous_day"
+	// the whole same day of the previous week
+	BaselineSameDayLastWeek Baseline = "same_day_last_week"
+	// the value observed yesterday at the time of the fetch
+	BaselineSameHourYesterday Baseline = "same_hour_yesterday"
+	// the value observed a week ago at the time of the fetch
+	BaselineSameHourLastWeek Baseline = "same_hour_last_week"
+)
+
+// DefaultBaseline is used by the change operators without an explicit baseline.
+const DefaultBaseline = BaselineSameHourYesterday
+
+// BaselineFromString returns the (case insensitive) baseline, or false if
+// s is not one.
+func BaselineFromString(s string) (Baseline, bool) {
+	switch b := Baseline(strings.ToLower(s)); b {
+	case BaselinePreviousDay, BaselineSameDayLastWeek, BaselineSameHourYesterday, BaselineSameHourLastWeek:
+		return b, true
+	}
+	return NoBaseline, false
+}
+
+// Value returns the value of the column on the event for the baseline
+// period, ok is false if there's no data for it.
+func (b Baseline) Value(column Column, evt common.Event) (value float64, ok bool, err error) {
+	h, isHistorical := evt.(common.HistoricalEvent)
+	if !isHistorical {
+		return 0, false, fmt.Errorf("historical data is not available")
+	}
+	now := h.FetchedAt()
+	switch b {
+	case BaselinePreviousDay:
+		return h.GetFieldOnDay(column.String(), now.AddDate(0, 0, -1))
+	case BaselineSameDayLastWeek:
+		return h.GetFieldOnDay(column.String(), now.AddDate(0, 0, -7))
+	case BaselineSameHourYesterday:
+		return h.GetFieldAt(column.String(), now.Add(-24*time.Hour))
+	case BaselineSameHourLastWeek:
+		return h.GetFieldAt(column.String(), now.Add(-7*24*time.Hour))
+	}
+	return 0, false, fmt.Errorf("invalid baseline %q", b)
+}
+
+// FuncOpChange returns the function of a change operator: the percent (or
+// absolute) change of the column from its baseline is compared with the
+// condition value. A condition without baseline data doesn't match, a change
+// from a baseline of 0 is an infinite percent change.
+func FuncOpChange(percent, greater bool) ConditionFunction {
+	return func(c Condition, evt common.Event) (bool, error) {
+		target, ok := c.GetValue().(float64)
+		if !ok {
+			return false, fmt.Errorf("invalid target value")
+		}
+		value, err := fieldValue(c, evt)
+		if err != nil {
+			return false, err
+		}
+		current, ok := value.(float64)
+		if !ok {
+			return false, fmt.Errorf("field %s is not numeric", c.GetTargetField())
+		}
+		baseline := c.GetBaseline()
+		if baseline == NoBaseline {
+			baseline = DefaultBaseline
+		}
+		base, ok, err := baseline.Value(c.GetTargetField(), evt)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+		observeBaseline(evt, c, base)
+
+		change := current - base
+		if percent {
+			switch {
+			case base != 0:
+				change = change / math.Abs(base) * 100
+			case current == 0:
+				change = 0
+			default:
+				change = math.Inf(int(math.Copysign(1, current)))
+			}
+		}
+		if greater {
+			return change > target, nil
+		}
+		return change < target, nil
+	}
+}
+
+func (b Baseline) format() string {
+	if b == NoBaseline {
+		return string(DefaultBaseline)
+	}
+	return string(b)
+}
+
+// changeFunction is the name of the change operators in rule expressions.
+func changeFunction(op Operator) string {
+	if op == OpPctChangeGT || op == OpPctChangeLT {
+		return "pct_change"
+	}
+	return "delta"
+}
@@ -0,0 +1,128 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+// changeHistory returns the history of historyTask, fetched at noon, with
+// the snapshots of yesterday: at noon a1 had spent 40 and a2 had spent 10.
+func changeHistory() *testHistory {
+	_, h := historyTask()
+	yesterday := time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)
+	h.accountSnapshots = append(h.accountSnapshots,
+		db.DbAccountSpend{ProviderID: "p1", AccountID: "a1", Spend: 20, DateRef: yesterday, UpdatedAt: yesterday.Add(9 * time.Hour)},
+		db.DbAccountSpend{ProviderID: "p1", AccountID: "a1", Spend: 40, DateRef: yesterday, UpdatedAt: yesterday.Add(11 * time.Hour)},
+		db.DbAccountSpend{ProviderID: "p1", AccountID: "a1", Spend: 90, DateRef: yesterday, UpdatedAt: yesterday.Add(20 * time.Hour)},
+		db.DbAccountSpend{ProviderID: "p1", AccountID: "a2", Spend: 10, DateRef: yesterday, UpdatedAt: yesterday.Add(11 * time.Hour)},
+	)
+	return h
+}
+
+func TestChangeRules(t *testing.T) {
+	cases := []struct {
+		expression string
+		entities   []string
+	}{
+		// today a1 spent 400, a2 10
+		{expression: "pct_change(daily_spend) > 50", entities: []string{"a1"}},
+		{expression: "pct_change(daily_spend, same_hour_yesterday) > 900", entities: []string{}},
+		{expression: "pct_change(daily_spend, same_hour_yesterday) > 899", entities: []string{"a1"}},
+		{expression: "delta(daily_spend, previous_day) > 250", entities: []string{"a1"}},
+		{expression: "delta(daily_spend, previous_day) < 1", entities: []string{"a2"}},
+		{expression: "pct_change(daily_spend, same_day_last_week) < -50", entities: []string{}},
+		// no snapshot a week ago: the condition doesn't match
+		{expression: "pct_change(daily_spend, same_hour_last_week) > -100", entities: []string{}},
+	}
+	for _, c := range cases {
+		r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: c.expression, Scope: "account"})
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		task, _ := historyTask()
+		task.WithHistory("c1", changeHistory())
+		results, err := Execute(r, task)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		if len(results) != len(c.entities) {
+			t.Fatalf("%s: expected %d results, got %d", c.expression, len(c.entities), len(results))
+		}
+		for idx, res := range results {
+			if res.EntityID != c.entities[idx] {
+				t.Fatalf("%s: expected entity %s, got %s", c.expression, c.entities[idx], res.EntityID)
+			}
+		}
+	}
+}
+
+func TestChangeResultValues(t *testing.T) {
+	r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: "daily_spend > 100 and pct_change(daily_spend) > 50", Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, _ := historyTask()
+	task.WithHistory("c1", changeHistory())
+	results, err := Execute(r, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	res := results[0]
+	if res.Metric != "DAILY_SPEND" || res.Value != float64(400) || res.Baseline != float64(40) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestChangeSyntax(t *testing.T) {
+	cond, err := Compile("PCT_CHANGE(daily_spend) > 50 or delta(avg_cpc, previous_day) < -1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "pct_change(daily_spend, same_hour_yesterday) > 50 or delta(avg_cpc, previous_day) < -1"
+	if formatted := FormatCondition(cond); formatted != expected {
+		t.Fatalf("expected %s, got %s", expected, formatted)
+	}
+	spec := SpecFromCondition(cond)
+	if spec.Children[1].Operator != "OpDeltaLT" || spec.Children[1].Baseline != "previous_day" {
+		t.Fatalf("unexpected spec %+v", spec.Children[1])
+	}
+	if _, err := ParseConditions(`{"operator":"pct_change_gt","column":"daily_spend","value":50,"baseline":"same_hour_last_week"}`); err != nil {
+		t.Fatal(err)
+	}
+	invalid := []string{
+		`{"operator":"pct_change_gt","column":"daily_spend","value":50,"baseline":"last_year"}`,
+		`{"operator":"pct_change_gt","column":"campaign_name","value":50}`,
+		`{"operator":"delta_lt","column":"daily_spend","value":"50"}`,
+		`{"operator":"gt","column":"daily_spend","value":50,"baseline":"previous_day"}`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseConditions(data); err == nil {
+			t.Fatalf("%s: expected an error", data)
+		}
+	}
+
+	errors := []struct {
+		expression string
+		pos        int
+	}{
+		{expression: "pct_change(daily_spend) >= 50", pos: 24},
+		{expression: "pct_change(daily_spend, last_year) > 50", pos: 24},
+		{expression: "delta(campaign_name) > 1", pos: 6},
+		{expression: `delta(daily_spend) > "1"`, pos: 21},
+	}
+	for _, c := range errors {
+		_, err := Compile(c.expression)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("%q: expected a syntax error, got %v", c.expression, err)
+		}
+		if syntaxErr.Pos != c.pos {
+			t.Fatalf("%q: expected error at %d, got %d (%v)", c.expression, c.pos, syntaxErr.Pos, err)
+		}
+	}
+}
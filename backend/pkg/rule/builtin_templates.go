@@ -0,0 +1,91 @@
+package rule
+
+func init() {
+	MustRegisterTemplate(Template{
+		Name:        "daily_overspend",
+		Title:       "Daily overspend",
+		Description: "An ad account spent more than the threshold in the day",
+		Scope:       "ACCOUNT",
+		RuleType:    RuleTypeThreshold,
+		Expression:  "daily_spend > ${threshold}",
+		Severity:    "WARNING",
+		Parameters: []TemplateParam{
+			{Name: "threshold", Description: "Daily spend above which the rule fires", Type: ParamNumber},
+		},
+	})
+	MustRegisterTemplate(Template{
+		Name:        "cpc_spike",
+		Title:       "CPC spike",
+		Description: "The average CPC of a campaign increased by more than the percent from its baseline",
+		Scope:       "CAMPAIGN",
+		RuleType:    RuleTypeThreshold,
+		Expression:  "pct_change(avg_cpc, ${baseline}) > ${percent} and daily_clicks >= ${min_clicks}",
+		Severity:    "WARNING",
+		Parameters: []TemplateParam{
+			{Name: "percent", Description: "Percent increase of the CPC above which the rule fires", Type: ParamNumber, Default: 50},
+			{
+				Name:        "baseline",
+				Description: "Period the CPC is compared with",
+				Type:        ParamChoice,
+				Choices:     []string{"previous_day", "same_day_last_week", "same_hour_yesterday", "same_hour_last_week"},
+				Default:     "same_hour_yesterday",
+			},
+			{Name: "min_clicks", Description: "Clicks required in the day, so that a few expensive clicks don't fire the rule", Type: ParamInteger, Default: 20},
+		},
+	})
+	MustRegisterTemplate(Template{
+		Name:        "zero_spend_account",
+		Title:       "Zero spend account",
+		Description: "An ad account that usually spends has spent nothing in the day",
+		Scope:       "ACCOUNT",
+		RuleType:    RuleTypeThreshold,
+		Expression:  "daily_spend == 0 and avg(daily_spend, ${days}d) > ${min_avg_spend}",
+		Severity:    "CRITICAL",
+		Parameters: []TemplateParam{
+			{Name: "days", Description: "Days of the average spend of the account", Type: ParamInteger, Default: 7},
+			{Name: "min_avg_spend", Description: "Average daily spend above which the account is expected to spend", Type: ParamNumber, Default: 10},
+		},
+		// an hour of fetches, as the accounts start the day without spend
+		SustainEvaluations: 4,
+	})
+	MustRegisterTemplate(Template{
+		Name:        "campaign_stopped_delivering",
+		Title:       "Campaign stopped delivering",
+		Description: "A campaign that was delivering has no impressions in the day",
+		Scope:       "CAMPAIGN",
+		RuleType:    RuleTypeThreshold,
+		Expression:  "daily_impressions == 0 and sum(daily_impressions, ${days}d) > ${min_impressions}",
+		Severity:    "WARNING",
+		Parameters: []TemplateParam{
+			{Name: "days", Description: "Days the campaign delivered in", Type: ParamInteger, Default: 3},
+			{Name: "min_impressions", Description: "Impressions in the days above which the campaign is expected to deliver", Type: ParamInteger, Default: 1000},
+		},
+		SustainEvaluations: 4,
+	})
+	MustRegisterTemplate(Template{
+		Name:        "campaign_stalled",
+		Title:       "Campaign stalled",
+		Description: "A campaign that spent the previous day has not spent anything more for a while",
+		Scope:       "CAMPAIGN",
+		RuleType:    RuleTypeStall,
+		Params:      `{"minutes": ${minutes}, "min_previous": ${min_spend}}`,
+		Severity:    "CRITICAL",
+		Parameters: []TemplateParam{
+			{Name: "minutes", Description: "Minutes without spend after which the rule fires", Type: ParamInteger, Default: 120},
+			{Name: "min_spend", Description: "Spend of the previous day above which the campaign is expected to spend", Type: ParamNumber, Default: 0},
+		},
+	})
+	MustRegisterTemplate(Template{
+		Name:        "daily_budget_pacing",
+		Title:       "Daily budget pacing",
+		Description: "The spend projected at the end of the day is over the budget",
+		Scope:       "ACCOUNT",
+		RuleType:    RuleTypePacing,
+		Params:      `{"budget": ${budget}, "period": "day", "tolerance": ${tolerance}}`,
+		Severity:    "WARNING",
+		Parameters: []TemplateParam{
+			{Name: "budget", Description: "Daily budget of the account", Type: ParamNumber},
+			{Name: "tolerance", Description: "Percent of the budget the projection can exceed it by", Type: ParamNumber, Default: 10},
+		},
+	})
+}
@@ -17,5 +17,9 @@ type Condition interface {
 	SetFunction(fn ConditionFunction)
 	SetTargetField(field Column)
 	GetTargetField() Column
+	SetWindow(w *Window)
+	GetWindow() *Window
+	SetBaseline(b Baseline)
+	GetBaseline() Baseline
 	GetChildrens() []Condition
 }
@@ -3,13 +3,28 @@ package rule
 import (
 	"cmp"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/s0und0fs1lence/ads-zero/pkg/common"
 )
 
+// fieldValue returns the value the condition compares: the target field of
+// the event, or its aggregation over the condition window.
+func fieldValue(c Condition, evt common.Event) (value any, err error) {
+	if w := c.GetWindow(); w != nil {
+		value, err = w.Apply(c.GetTargetField(), evt)
+	} else {
+		value, err = evt.GetFieldValue(c.GetTargetField().String())
+	}
+	if err == nil {
+		observe(evt, c, c.GetTargetField(), value)
+	}
+	return value, err
+}
+
 func FuncOpGTE[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
-	field := c.GetTargetField()
-	value, err := evt.GetFieldValue(field.String())
+	value, err := fieldValue(c, evt)
 	if err != nil {
 		return false, err
 	}
@@ -19,12 +34,15 @@ func FuncOpGTE[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
 		return false, fmt.Errorf("invalid target value")
 	}
 
-	return value.(K) >= target, nil
+	current, ok := value.(K)
+	if !ok {
+		return false, fmt.Errorf("field %s cannot be compared with %v", c.GetTargetField(), target)
+	}
+	return current >= target, nil
 }
 
 func FuncOpGT[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
-	field := c.GetTargetField()
-	value, err := evt.GetFieldValue(field.String())
+	value, err := fieldValue(c, evt)
 	if err != nil {
 		return false, err
 	}
@@ -34,12 +52,15 @@ func FuncOpGT[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
 		return false, fmt.Errorf("invalid target value")
 	}
 
-	return value.(K) > target, nil
+	current, ok := value.(K)
+	if !ok {
+		return false, fmt.Errorf("field %s cannot be compared with %v", c.GetTargetField(), target)
+	}
+	return current > target, nil
 }
 
 func FuncOpLTE[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
-	field := c.GetTargetField()
-	value, err := evt.GetFieldValue(field.String())
+	value, err := fieldValue(c, evt)
 	if err != nil {
 		return false, err
 	}
@@ -49,11 +70,14 @@ func FuncOpLTE[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
 		return false, fmt.Errorf("invalid target value")
 	}
 
-	return value.(K) <= target, nil
+	current, ok := value.(K)
+	if !ok {
+		return false, fmt.Errorf("field %s cannot be compared with %v", c.GetTargetField(), target)
+	}
+	return current <= target, nil
 }
 func FuncOpLT[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
-	field := c.GetTargetField()
-	value, err := evt.GetFieldValue(field.String())
+	value, err := fieldValue(c, evt)
 	if err != nil {
 		return false, err
 	}
@@ -63,12 +87,15 @@ func FuncOpLT[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
 		return false, fmt.Errorf("invalid target value")
 	}
 
-	return value.(K) < target, nil
+	current, ok := value.(K)
+	if !ok {
+		return false, fmt.Errorf("field %s cannot be compared with %v", c.GetTargetField(), target)
+	}
+	return current < target, nil
 }
 
 func FuncOpEQ[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
-	field := c.GetTargetField()
-	value, err := evt.GetFieldValue(field.String())
+	value, err := fieldValue(c, evt)
 	if err != nil {
 		return false, err
 	}
@@ -78,7 +105,46 @@ func FuncOpEQ[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
 		return false, fmt.Errorf("invalid target value")
 	}
 
-	return value.(K) == target, nil
+	current, ok := value.(K)
+	if !ok {
+		return false, fmt.Errorf("field %s cannot be compared with %v", c.GetTargetField(), target)
+	}
+	return current == target, nil
+}
+
+func FuncOpNotEQ[K cmp.Ordered](c Condition, evt common.Event) (bool, error) {
+	res, err := FuncOpEQ[K](c, evt)
+	if err != nil {
+		return false, err
+	}
+	return !res, nil
+}
+
+func FuncOpREGEX(c Condition, evt common.Event) (bool, error) {
+	field := c.GetTargetField()
+	value, err := fieldValue(c, evt)
+	if err != nil {
+		return false, err
+	}
+	re, err := leafPattern(c)
+	if err != nil {
+		return false, err
+	}
+	str, ok := value.(string)
+	if !ok {
+		return false, fmt.Errorf("field %s is not a string", field)
+	}
+	return re.MatchString(str), nil
+}
+
+// leafPattern returns the pattern compiled when the value of the leaf was
+// set, so that it isn't compiled again on every evaluation.
+func leafPattern(c Condition) (*regexp.Regexp, error) {
+	leaf, ok := c.(*ConditionLeaf)
+	if !ok || leaf.pattern == nil {
+		return nil, fmt.Errorf("invalid pattern %v", c.GetValue())
+	}
+	return leaf.pattern, nil
 }
 
 func FuncOpAND(c Condition, evt common.Event) (bool, error) {
@@ -119,3 +185,110 @@ func FuncOpNOT(c Condition, evt common.Event) (bool, error) {
 	}
 	return true, nil
 }
+
+// FuncOpIN returns the function of the in (or not in, when negated)
+// operator, matching the field against a list of values.
+func FuncOpIN(negated bool) ConditionFunction {
+	return func(c Condition, evt common.Event) (bool, error) {
+		value, err := fieldValue(c, evt)
+		if err != nil {
+			return false, err
+		}
+		list, ok := c.GetValue().([]any)
+		if !ok {
+			return false, fmt.Errorf("invalid target value")
+		}
+		for _, item := range list {
+			if item == value {
+				return !negated, nil
+			}
+		}
+		return negated, nil
+	}
+}
+
+// FuncOpBETWEEN matches a numeric field between two bounds, included.
+func FuncOpBETWEEN(c Condition, evt common.Event) (bool, error) {
+	value, err := fieldValue(c, evt)
+	if err != nil {
+		return false, err
+	}
+	bounds, ok := c.GetValue().([]any)
+	if !ok || len(bounds) != 2 {
+		return false, fmt.Errorf("invalid target value")
+	}
+	low, lowOk := bounds[0].(float64)
+	high, highOk := bounds[1].(float64)
+	if !lowOk || !highOk {
+		return false, fmt.Errorf("invalid target value")
+	}
+	current, ok := value.(float64)
+	if !ok {
+		return false, fmt.Errorf("field %s is not numeric", c.GetTargetField())
+	}
+	return current >= low && current <= high, nil
+}
+
+// stringOperands returns the string field and the string value of the condition.
+func stringOperands(c Condition, evt common.Event) (field, target string, err error) {
+	value, err := fieldValue(c, evt)
+	if err != nil {
+		return "", "", err
+	}
+	target, ok := c.GetValue().(string)
+	if !ok {
+		return "", "", fmt.Errorf("invalid target value")
+	}
+	field, ok = value.(string)
+	if !ok {
+		return "", "", fmt.Errorf("field %s is not a string", c.GetTargetField())
+	}
+	return field, target, nil
+}
+
+func FuncOpCONTAINS(c Condition, evt common.Event) (bool, error) {
+	field, target, err := stringOperands(c, evt)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(field, target), nil
+}
+
+func FuncOpPREFIX(c Condition, evt common.Event) (bool, error) {
+	field, target, err := stringOperands(c, evt)
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(field, target), nil
+}
+
+func FuncOpGLOB(c Condition, evt common.Event) (bool, error) {
+	field, _, err := stringOperands(c, evt)
+	if err != nil {
+		return false, err
+	}
+	re, err := leafPattern(c)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(field), nil
+}
+
+// globRegexp compiles a glob pattern matching the whole string, where `*`
+// matches any sequence of characters and `?` a single one.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
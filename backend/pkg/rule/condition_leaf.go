@@ -2,6 +2,7 @@ package rule
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/rs/zerolog/log"
 	"github.com/s0und0fs1lence/ads-zero/pkg/common"
@@ -12,12 +13,27 @@ type ConditionLeaf struct {
 	function    ConditionFunction
 	targetField Column
 	value       any
+	window      *Window
+	baseline    Baseline
+	// pattern is the compiled value of the pattern operators, nil when the
+	// value is not a valid pattern.
+	pattern *regexp.Regexp
 }
 
 // SetValue implements Condition.
+// Numeric values are normalized to float64, the type of the numeric metrics.
 func (cl *ConditionLeaf) SetValue(value interface{}) {
-	cl.value = value
-	cl.function = cl.Operator.GetFunc(value)
+	cl.value = normalizeValue(value)
+	cl.function = cl.Operator.GetFunc(cl.value)
+	cl.pattern = nil
+	if s, ok := cl.value.(string); ok {
+		switch cl.Operator {
+		case OpREGEX:
+			cl.pattern, _ = regexp.Compile(s)
+		case OpGLOB:
+			cl.pattern, _ = globRegexp(s)
+		}
+	}
 }
 
 func NewConditionLeaf(op Operator) (Condition, error) {
@@ -45,6 +61,26 @@ func (cn *ConditionLeaf) GetTargetField() Column {
 	return cn.targetField
 }
 
+// SetWindow implements Condition.
+func (cl *ConditionLeaf) SetWindow(w *Window) {
+	cl.window = w
+}
+
+// GetWindow implements Condition.
+func (cl *ConditionLeaf) GetWindow() *Window {
+	return cl.window
+}
+
+// SetBaseline implements Condition.
+func (cl *ConditionLeaf) SetBaseline(b Baseline) {
+	cl.baseline = b
+}
+
+// GetBaseline implements Condition.
+func (cl *ConditionLeaf) GetBaseline() Baseline {
+	return cl.baseline
+}
+
 // GetChildrens implements Condition.
 func (cl *ConditionLeaf) GetChildrens() []Condition {
 	return []Condition{}
@@ -61,7 +97,10 @@ func (cl *ConditionLeaf) ClearChildrens() {
 }
 
 func (cl *ConditionLeaf) Exec(evt common.Event) (bool, error) {
-	return cl.function(cl, evt)
+	done := traceCondition(evt, cl)
+	res, err := cl.function(cl, evt)
+	done(res, err)
+	return res, err
 }
 
 func (cl *ConditionLeaf) GetOperator() Operator {
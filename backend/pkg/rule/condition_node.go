@@ -44,6 +44,24 @@ func (cn *ConditionNode) GetTargetField() Column {
 func (cn *ConditionNode) SetTargetField(field Column) {
 }
 
+// SetWindow implements Condition.
+func (cn *ConditionNode) SetWindow(w *Window) {
+}
+
+// GetWindow implements Condition.
+func (cn *ConditionNode) GetWindow() *Window {
+	return nil
+}
+
+// SetBaseline implements Condition.
+func (cn *ConditionNode) SetBaseline(b Baseline) {
+}
+
+// GetBaseline implements Condition.
+func (cn *ConditionNode) GetBaseline() Baseline {
+	return NoBaseline
+}
+
 // Implement the Condition interface for ConditionNode
 func (cn *ConditionNode) Append(child Condition) Condition {
 	cn.Childrens = append(cn.Childrens, child)
@@ -60,7 +78,10 @@ func (cn *ConditionNode) ClearChildrens() {
 }
 
 func (cn *ConditionNode) Exec(evt common.Event) (bool, error) {
-	return cn.function(cn, evt)
+	done := traceCondition(evt, cn)
+	res, err := cn.function(cn, evt)
+	done(res, err)
+	return res, err
 }
 
 func (cn *ConditionNode) GetOperator() Operator {
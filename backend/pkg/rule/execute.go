@@ -0,0 +1,152 @@
+package rule
+
+import (
+	"math"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+)
+
+// observation is a value compared while evaluating a rule, key identifies
+// the condition (or the rule) that compared it.
+type observation struct {
+	key      any
+	column   Column
+	value    any
+	baseline any
+}
+
+// observedEvent records the values the conditions compare, so that the
+// results can report them, and the trace of the evaluation.
+type observedEvent struct {
+	*common.EntityEvent
+	observations []observation
+	trace        *common.Trace
+	// the traces of the conditions being evaluated
+	traces []*common.Trace
+}
+
+func observe(evt common.Event, key any, column Column, value any) {
+	if o, ok := evt.(*observedEvent); ok {
+		o.observations = append(o.observations, observation{key: key, column: column, value: reportable(value)})
+	}
+}
+
+func observeBaseline(evt common.Event, key any, baseline float64) {
+	o, ok := evt.(*observedEvent)
+	if !ok {
+		return
+	}
+	if obs := o.observation(key); obs != nil {
+		obs.baseline = reportable(baseline)
+	}
+}
+
+// reportable returns the value as the results and the traces report it,
+// JSON has no infinite numbers: the ratios without denominator are reported
+// as "+Inf" or "-Inf".
+func reportable(value any) any {
+	if f, ok := value.(float64); ok && math.IsInf(f, 0) {
+		if f > 0 {
+			return "+Inf"
+		}
+		return "-Inf"
+	}
+	return value
+}
+
+// observation returns the last value compared by key.
+func (o *observedEvent) observation(key any) *observation {
+	for idx := len(o.observations) - 1; idx >= 0; idx-- {
+		if o.observations[idx].key == key {
+			return &o.observations[idx]
+		}
+	}
+	return nil
+}
+
+// reported returns the observation shown in the result: the first one with
+// a baseline, or the first one.
+func (o *observedEvent) reported() *observation {
+	if len(o.observations) == 0 {
+		return nil
+	}
+	for idx := range o.observations {
+		if o.observations[idx].baseline != nil {
+			return &o.observations[idx]
+		}
+	}
+	return &o.observations[0]
+}
+
+// ruleEvents returns the events of the task the rule is evaluated on, the
+// stall rules also look at the entities that stopped appearing in the fetch.
+func ruleEvents(r Rule, task *common.FetchTask) ([]*common.EntityEvent, error) {
+	if _, ok := r.(*stallRule); ok {
+		return task.EventsWithMissing(r.Scope())
+	}
+	return task.Events(r.Scope())
+}
+
+// Execute evaluates the rule on every entity of the task matching the rule
+// scope, and returns one result for each entity the rule matched on.
+func Execute(r Rule, task *common.FetchTask) ([]*common.RuleResult, error) {
+	events, err := ruleEvents(r, task)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*common.RuleResult, 0)
+	for _, evt := range events {
+		result, err := evaluate(r, evt)
+		if err != nil {
+			return nil, err
+		}
+		if result.Result {
+			res = append(res, result)
+		}
+	}
+	return res, nil
+}
+
+// Evaluate evaluates the rule on every entity of the task matching the rule
+// scope, and returns the result of each entity, matched or not, with the
+// trace of its evaluation. The evaluation errors are reported in the traces.
+func Evaluate(r Rule, task *common.FetchTask) ([]*common.RuleResult, error) {
+	events, err := ruleEvents(r, task)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*common.RuleResult, 0, len(events))
+	for _, evt := range events {
+		result, _ := evaluate(r, evt)
+		res = append(res, result)
+	}
+	return res, nil
+}
+
+func evaluate(r Rule, evt *common.EntityEvent) (*common.RuleResult, error) {
+	observed := &observedEvent{EntityEvent: evt}
+	match, err := r.Exec(observed)
+	if err != nil {
+		match = false
+	}
+	result := &common.RuleResult{
+		RuleName:     r.Name(),
+		RuleID:       r.Id(),
+		Result:       match,
+		Threshold:    r.Value(),
+		EntityType:   evt.EntityType,
+		EntityID:     evt.EntityID,
+		EntityName:   evt.EntityName,
+		CurrentSpend: evt.GetTotalSpend(),
+		Trace:        observed.trace,
+	}
+	if result.Trace == nil {
+		result.Trace = ruleTrace(r, observed, match, err)
+	}
+	if obs := observed.reported(); obs != nil {
+		result.Metric = obs.column.String()
+		result.Value = obs.value
+		result.Baseline = obs.baseline
+	}
+	return result, err
+}
@@ -0,0 +1,66 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+func entityTask() *common.FetchTask {
+	task := common.NewFetchTask(time.Now(), time.Now())
+	task.Accounts = append(task.Accounts,
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", ProviderType: db.Facebook, BusinessID: "b1", AccountID: "a1", AccountName: "Account 1", Spend: 150},
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", ProviderType: db.Facebook, BusinessID: "b1", AccountID: "a2", AccountName: "Account 2", Spend: 50},
+	)
+	task.Campaigns = append(task.Campaigns,
+		db.DbCampaignSpend{ClientID: "c1", ProviderID: "p1", ProviderType: db.Facebook, BusinessID: "b1", AccountID: "a1", CampaignID: "k1", CampaignName: "PROMO_summer", Spend: 120},
+		db.DbCampaignSpend{ClientID: "c1", ProviderID: "p1", ProviderType: db.Facebook, BusinessID: "b1", AccountID: "a1", CampaignID: "k2", CampaignName: "brand", Spend: 30},
+		db.DbCampaignSpend{ClientID: "c1", ProviderID: "p1", ProviderType: db.Facebook, BusinessID: "b1", AccountID: "a2", CampaignID: "k3", CampaignName: "PROMO_winter", Spend: 50},
+	)
+	return task
+}
+
+func TestExecuteScopes(t *testing.T) {
+	cases := []struct {
+		scope      string
+		expression string
+		entities   []string
+	}{
+		{scope: "client", expression: "daily_spend > 100", entities: []string{"c1"}},
+		{scope: "business", expression: "daily_spend > 100", entities: []string{"b1"}},
+		{scope: "account", expression: "daily_spend > 100", entities: []string{"a1"}},
+		{scope: "campaign", expression: "daily_spend > 40", entities: []string{"k1", "k3"}},
+		{scope: "campaign", expression: `campaign_name ~ "^PROMO_" and daily_spend < 100`, entities: []string{"k3"}},
+		{scope: "account", expression: "daily_spend > 1000", entities: []string{}},
+	}
+	for _, c := range cases {
+		r, err := FromDbRule(db.DbRule{RuleID: "1", RuleName: "scoped", Expression: c.expression, Scope: c.scope})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := Execute(r, entityTask())
+		if err != nil {
+			t.Fatalf("%s %s: %v", c.scope, c.expression, err)
+		}
+		if len(results) != len(c.entities) {
+			t.Fatalf("%s %s: expected %d results, got %d", c.scope, c.expression, len(c.entities), len(results))
+		}
+		for idx, res := range results {
+			if res.EntityID != c.entities[idx] {
+				t.Fatalf("%s %s: expected entity %s, got %s", c.scope, c.expression, c.entities[idx], res.EntityID)
+			}
+		}
+	}
+}
+
+func TestExecuteUnavailableField(t *testing.T) {
+	r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: `campaign_name ~ "x"`, Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Execute(r, entityTask()); err == nil {
+		t.Fatal("expected an error for a campaign field on an account rule")
+	}
+}
@@ -0,0 +1,42 @@
+package rule
+
+import "github.com/s0und0fs1lence/ads-zero/pkg/common"
+
+// ruleInfo holds the fields shared by every Rule implementation.
+type ruleInfo struct {
+	name  string
+	id    string
+	scope common.EntityType
+}
+
+func newRuleInfo(name, id string) ruleInfo {
+	return ruleInfo{
+		name:  name,
+		id:    id,
+		scope: common.CLIENT,
+	}
+}
+
+// Id implements Rule.
+func (r *ruleInfo) Id() string {
+	return r.id
+}
+
+// Name implements Rule.
+func (r *ruleInfo) Name() string {
+	return r.name
+}
+
+// Scope implements Rule.
+func (r *ruleInfo) Scope() common.EntityType {
+	return r.scope
+}
+
+func (r *ruleInfo) setScope(scope common.EntityType) {
+	r.scope = scope
+}
+
+// scopeSetter is implemented by every rule embedding ruleInfo.
+type scopeSetter interface {
+	setScope(scope common.EntityType)
+}
@@ -0,0 +1,245 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind uint8
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokEQ
+	tokNotEQ
+	tokLT
+	tokLTE
+	tokGT
+	tokGTE
+	tokMatch
+	tokComma
+	tokDuration
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of expression"
+	case tokIdent:
+		return "identifier"
+	case tokNumber:
+		return "number"
+	case tokString:
+		return "string"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokAnd:
+		return "'and'"
+	case tokOr:
+		return "'or'"
+	case tokNot:
+		return "'not'"
+	case tokEQ:
+		return "'=='"
+	case tokNotEQ:
+		return "'!='"
+	case tokLT:
+		return "'<'"
+	case tokLTE:
+		return "'<='"
+	case tokGT:
+		return "'>'"
+	case tokGTE:
+		return "'>='"
+	case tokMatch:
+		return "'~'"
+	case tokComma:
+		return "','"
+	case tokDuration:
+		return "duration"
+	}
+	return "unknown token"
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// SyntaxError is returned when a rule expression cannot be parsed.
+// Pos is the 0-based byte offset of the offending token.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at column %d: %s", e.Pos+1, e.Msg)
+}
+
+var keywords = map[string]tokenKind{
+	"and": tokAnd,
+	"or":  tokOr,
+	"not": tokNot,
+}
+
+type lexer struct {
+	input string
+	pos   int
+}
+
+func (l *lexer) errorf(pos int, format string, args ...any) error {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) peekByte(offset int) byte {
+	if l.pos+offset >= len(l.input) {
+		return 0
+	}
+	return l.input[l.pos+offset]
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	ch := l.input[l.pos]
+	switch {
+	case ch == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}, nil
+	case ch == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}, nil
+	case ch == ',':
+		l.pos++
+		return token{kind: tokComma, text: ",", pos: start}, nil
+	case ch == '~':
+		l.pos++
+		return token{kind: tokMatch, text: "~", pos: start}, nil
+	case ch == '=':
+		l.pos++
+		// both "=" and "==" are accepted
+		if l.peekByte(0) == '=' {
+			l.pos++
+		}
+		return token{kind: tokEQ, text: l.input[start:l.pos], pos: start}, nil
+	case ch == '!':
+		if l.peekByte(1) != '=' {
+			return token{}, l.errorf(start, "unexpected character '!', did you mean '!='?")
+		}
+		l.pos += 2
+		return token{kind: tokNotEQ, text: "!=", pos: start}, nil
+	case ch == '<' || ch == '>':
+		l.pos++
+		orEqual := l.peekByte(0) == '='
+		if orEqual {
+			l.pos++
+		}
+		kind := tokLT
+		switch {
+		case ch == '<' && orEqual:
+			kind = tokLTE
+		case ch == '>' && orEqual:
+			kind = tokGTE
+		case ch == '>':
+			kind = tokGT
+		}
+		return token{kind: kind, text: l.input[start:l.pos], pos: start}, nil
+	case ch == '"' || ch == '\'':
+		return l.lexString(ch)
+	case isDigit(ch) || ((ch == '-' || ch == '.') && isDigit(l.peekByte(1))):
+		return l.lexNumber()
+	case isIdentStart(ch):
+		for l.pos < len(l.input) && isIdentPart(l.input[l.pos]) {
+			l.pos++
+		}
+		text := l.input[start:l.pos]
+		if kind, ok := keywords[strings.ToLower(text)]; ok {
+			return token{kind: kind, text: text, pos: start}, nil
+		}
+		return token{kind: tokIdent, text: text, pos: start}, nil
+	}
+	return token{}, l.errorf(start, "unexpected character %q", ch)
+}
+
+func (l *lexer) lexNumber() (token, error) {
+	start := l.pos
+	if l.input[l.pos] == '-' {
+		l.pos++
+	}
+	seenDot := false
+	for l.pos < len(l.input) {
+		ch := l.input[l.pos]
+		if ch == '.' && !seenDot {
+			seenDot = true
+		} else if !isDigit(ch) {
+			break
+		}
+		l.pos++
+	}
+	// a number of days, as in "7d"
+	if ch := l.peekByte(0); (ch == 'd' || ch == 'D') && !isIdentPart(l.peekByte(1)) {
+		l.pos++
+		return token{kind: tokDuration, text: l.input[start:l.pos], pos: start}, nil
+	}
+	if l.pos < len(l.input) && isIdentStart(l.input[l.pos]) {
+		return token{}, l.errorf(l.pos, "unexpected character %q after number", l.input[l.pos])
+	}
+	return token{kind: tokNumber, text: l.input[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) lexString(quote byte) (token, error) {
+	start := l.pos
+	l.pos++
+	var sb strings.Builder
+	for l.pos < len(l.input) {
+		ch := l.input[l.pos]
+		switch {
+		case ch == quote:
+			l.pos++
+			return token{kind: tokString, text: sb.String(), pos: start}, nil
+		case ch == '\\' && l.pos+1 < len(l.input):
+			// keep the escaped character as is, so that regular expressions
+			// like "\d+" can be written without doubling the backslash
+			next := l.input[l.pos+1]
+			if next != quote && next != '\\' {
+				sb.WriteByte(ch)
+			}
+			sb.WriteByte(next)
+			l.pos += 2
+		default:
+			sb.WriteByte(ch)
+			l.pos++
+		}
+	}
+	return token{}, l.errorf(start, "unterminated string")
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isIdentPart(ch byte) bool {
+	return isIdentStart(ch) || isDigit(ch)
+}
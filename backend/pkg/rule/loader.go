@@ -0,0 +1,107 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+// Rule types, as stored in the rule_type column of client_rules.
+const (
+	RuleTypeThreshold = "THRESHOLD"
+	RuleTypeAnomaly   = "ANOMALY"
+	RuleTypePacing    = "PACING"
+	RuleTypeStall     = "STALL"
+)
+
+// FromDbRule builds the Rule described by a client_rules row.
+// Threshold rules with a textual expression or a condition tree are executed
+// as a tree rule, otherwise the flat column / operator / value triple is
+// used. The other rule types are configured by their JSON params.
+func FromDbRule(r db.DbRule) (Rule, error) {
+	newRule, err := buildDbRule(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Scope != "" {
+		scope, err := common.EntityTypeFromString(r.Scope)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.RuleID, err)
+		}
+		if scope != common.CLIENT && scope != common.PROVIDER && scope != common.BUSINESS &&
+			scope != common.ACCOUNT && scope != common.CAMPAIGN {
+			return nil, fmt.Errorf("rule %s: unsupported scope %s", r.RuleID, scope)
+		}
+		newRule.(scopeSetter).setScope(scope)
+	}
+	return newRule, nil
+}
+
+func buildDbRule(r db.DbRule) (Rule, error) {
+	switch strings.ToUpper(r.RuleType) {
+	case "", RuleTypeThreshold:
+	case RuleTypeAnomaly:
+		params, err := ParseAnomalyParams(r.Params)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.RuleID, err)
+		}
+		newRule, err := NewAnomalyRule(params, r.RuleName, r.RuleID)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.RuleID, err)
+		}
+		return newRule, nil
+	case RuleTypePacing:
+		params, err := ParsePacingParams(r.Params)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.RuleID, err)
+		}
+		newRule, err := NewPacingRule(params, r.RuleName, r.RuleID)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.RuleID, err)
+		}
+		return newRule, nil
+	case RuleTypeStall:
+		params, err := ParseStallParams(r.Params)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.RuleID, err)
+		}
+		newRule, err := NewStallRule(params, r.RuleName, r.RuleID)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.RuleID, err)
+		}
+		return newRule, nil
+	default:
+		return nil, fmt.Errorf("rule %s: invalid rule type %q", r.RuleID, r.RuleType)
+	}
+
+	if r.Expression != "" {
+		cond, err := Compile(r.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.RuleID, err)
+		}
+		return NewTreeRule(cond, r.RuleName, r.RuleID), nil
+	}
+	if r.Conditions != "" {
+		cond, err := ParseConditions(r.Conditions)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.RuleID, err)
+		}
+		return NewTreeRule(cond, r.RuleName, r.RuleID), nil
+	}
+
+	column := ColumnFromString(r.Column)
+	if column == INVALID {
+		return nil, fmt.Errorf("rule %s: invalid column %q", r.RuleID, r.Column)
+	}
+	operator := OperatorFromString(r.Operator)
+	if operator == OpINVALID || operator.IsLogical() {
+		return nil, fmt.Errorf("rule %s: invalid operator %q", r.RuleID, r.Operator)
+	}
+	newRule, err := newSimpleRule(column, operator, r.Value, r.RuleName, r.RuleID)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", r.RuleID, err)
+	}
+	return newRule, nil
+}
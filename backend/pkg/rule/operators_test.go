@@ -0,0 +1,159 @@
+package rule
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+func TestSetAndStringOperators(t *testing.T) {
+	cases := []struct {
+		scope      string
+		expression string
+		entities   []string
+	}{
+		{scope: "campaign", expression: `campaign_name prefix "PROMO_"`, entities: []string{"k1", "k3"}},
+		{scope: "campaign", expression: `campaign_name contains "summer"`, entities: []string{"k1"}},
+		{scope: "campaign", expression: `campaign_name glob "PROMO_*er"`, entities: []string{"k1", "k3"}},
+		{scope: "campaign", expression: `campaign_name glob "*_w?nter"`, entities: []string{"k3"}},
+		{scope: "campaign", expression: `campaign_name glob "PROMO"`, entities: []string{}},
+		{scope: "campaign", expression: `campaign_id in ("k1", "k2")`, entities: []string{"k1", "k2"}},
+		{scope: "campaign", expression: `campaign_id not in ("k1", "k2")`, entities: []string{"k3"}},
+		{scope: "campaign", expression: "daily_spend between 30 and 50", entities: []string{"k2", "k3"}},
+		{scope: "campaign", expression: "daily_spend in (30, 120)", entities: []string{"k1", "k2"}},
+		{scope: "campaign", expression: `business_id in ("b1") and daily_spend between 100 and 200`, entities: []string{"k1"}},
+		{scope: "campaign", expression: `not campaign_name prefix "PROMO_"`, entities: []string{"k2"}},
+		{scope: "account", expression: `business_id in ("b2", "b1")`, entities: []string{"a1", "a2"}},
+		{scope: "account", expression: `account_name not in ("Account 1")`, entities: []string{"a2"}},
+	}
+	for _, c := range cases {
+		r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: c.expression, Scope: c.scope})
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		results, err := Execute(r, entityTask())
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		if len(results) != len(c.entities) {
+			t.Fatalf("%s: expected %d results, got %d", c.expression, len(c.entities), len(results))
+		}
+		for idx, res := range results {
+			if res.EntityID != c.entities[idx] {
+				t.Fatalf("%s: expected entity %s, got %s", c.expression, c.entities[idx], res.EntityID)
+			}
+		}
+	}
+}
+
+func TestSetOperatorsFormat(t *testing.T) {
+	expressions := []string{
+		`business_id not in ("b1", "b2")`,
+		`daily_spend between 10 and 100 and campaign_name glob "*x*"`,
+		`daily_spend in (1, 2.5) or campaign_name contains "a" or campaign_name prefix "b"`,
+		"sum(daily_spend, 7d) between 100 and 200",
+	}
+	for _, expression := range expressions {
+		cond, err := Compile(expression)
+		if err != nil {
+			t.Fatalf("%s: %v", expression, err)
+		}
+		if formatted := FormatCondition(cond); formatted != expression {
+			t.Fatalf("expected %s, got %s", expression, formatted)
+		}
+
+		// the specs survive a JSON round trip
+		data, err := json.Marshal(SpecFromCondition(cond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rebuilt, err := ParseConditions(string(data))
+		if err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+		if formatted := FormatCondition(rebuilt); formatted != expression {
+			t.Fatalf("expected %s, got %s", expression, formatted)
+		}
+	}
+}
+
+func TestSetOperatorsValidation(t *testing.T) {
+	invalid := []string{
+		`{"operator":"in","column":"business_id","value":[]}`,
+		`{"operator":"in","column":"business_id","value":"b1"}`,
+		`{"operator":"in","column":"business_id","value":["b1", 2]}`,
+		`{"operator":"between","column":"daily_spend","value":[100, 10]}`,
+		`{"operator":"between","column":"daily_spend","value":[1, 2, 3]}`,
+		`{"operator":"between","column":"campaign_name","value":["a", "b"]}`,
+		`{"operator":"contains","column":"daily_spend","value":"1"}`,
+		`{"operator":"prefix","column":"campaign_name","value":1}`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseConditions(data); err == nil {
+			t.Fatalf("%s: expected an error", data)
+		}
+	}
+
+	errors := []struct {
+		expression string
+		pos        int
+	}{
+		{expression: "daily_spend in ()", pos: 16},
+		{expression: "daily_spend in (1 2)", pos: 18},
+		{expression: "daily_spend in 1", pos: 15},
+		{expression: "daily_spend not 1", pos: 16},
+		{expression: "daily_spend between 1 or 2", pos: 22},
+		{expression: "daily_spend between 10 and 1", pos: 20},
+		{expression: `campaign_name between "a" and "b"`, pos: 22},
+		{expression: `daily_spend contains "1"`, pos: 21},
+		{expression: `campaign_name in ("a", 1)`, pos: 17},
+		{expression: "daily_spend like 1", pos: 12},
+	}
+	for _, c := range errors {
+		_, err := Compile(c.expression)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("%q: expected a syntax error, got %v", c.expression, err)
+		}
+		if syntaxErr.Pos != c.pos {
+			t.Fatalf("%q: expected error at %d, got %d (%v)", c.expression, c.pos, syntaxErr.Pos, err)
+		}
+	}
+}
+
+func TestGlobCompiledOnce(t *testing.T) {
+	cond, err := Compile(`campaign_name glob "PROMO_*"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(cond); err != nil {
+		t.Fatal(err)
+	}
+	leaf := cond.(*ConditionLeaf)
+	if leaf.pattern == nil || leaf.pattern.String() != "^PROMO_.*$" {
+		t.Fatalf("expected the glob to be compiled with the value, got %v", leaf.pattern)
+	}
+	pattern := leaf.pattern
+	events, err := entityTask().Events(common.CAMPAIGN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := 0
+	for _, evt := range events {
+		match, err := cond.Exec(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match {
+			matches++
+		}
+	}
+	if matches != 2 {
+		t.Fatalf("expected 2 matches, got %d", matches)
+	}
+	if leaf.pattern != pattern {
+		t.Fatal("the glob was compiled again")
+	}
+}
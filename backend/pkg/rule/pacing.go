@@ -0,0 +1,296 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/metric"
+)
+
+// Pacing periods.
+const (
+	PacingDay   = "day"
+	PacingMonth = "month"
+)
+
+// Pacing directions.
+const (
+	PacingOver  = "over"
+	PacingUnder = "under"
+	PacingBoth  = "both"
+)
+
+// profileDays is the number of previous days whose snapshots are used to
+// learn how the spend is distributed over the day.
+const profileDays = 7
+
+// minProfileDays is the number of previous days with snapshots required to
+// project with the intraday profile instead of linearly.
+const minProfileDays = 3
+
+// PacingParams configures a pacing rule, it's stored as JSON in the `params`
+// column of client_rules. Zero values take the defaults.
+type PacingParams struct {
+	// Column is an additive metric, DAILY_SPEND by default.
+	Column string `json:"column,omitempty"`
+	// Period is PacingDay (default) or PacingMonth.
+	Period string `json:"period,omitempty"`
+	// Budget of the period, required.
+	Budget float64 `json:"budget"`
+	// Tolerance is the percent of the budget the projection can be off
+	// before alerting, 10 by default.
+	Tolerance float64 `json:"tolerance,omitempty"`
+	// Direction is PacingOver (default), PacingUnder or PacingBoth.
+	Direction string `json:"direction,omitempty"`
+	// MinElapsed is the fraction of the period that must be elapsed before
+	// the projection is trusted, 0.1 by default.
+	MinElapsed float64 `json:"min_elapsed,omitempty"`
+	// Timezone overrides the timezone of the ad accounts.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (p PacingParams) withDefaults() (PacingParams, error) {
+	if p.Column == "" {
+		p.Column = DAILY_SPEND.String()
+	}
+	column := ColumnFromString(p.Column)
+	if column == INVALID {
+		return p, fmt.Errorf("invalid column %q", p.Column)
+	}
+	if m, _ := column.Metric(); m.Aggregation != metric.AggSum {
+		return p, fmt.Errorf("column %s cannot be projected, it's not additive", column)
+	}
+	p.Column = column.String()
+
+	p.Period = strings.ToLower(p.Period)
+	switch p.Period {
+	case "":
+		p.Period = PacingDay
+	case PacingDay, PacingMonth:
+	default:
+		return p, fmt.Errorf("invalid pacing period %q", p.Period)
+	}
+	p.Direction = strings.ToLower(p.Direction)
+	switch p.Direction {
+	case "":
+		p.Direction = PacingOver
+	case PacingOver, PacingUnder, PacingBoth:
+	default:
+		return p, fmt.Errorf("invalid pacing direction %q", p.Direction)
+	}
+	if p.Budget <= 0 {
+		return p, fmt.Errorf("budget must be positive")
+	}
+	if p.Tolerance == 0 {
+		p.Tolerance = 10
+	}
+	if p.Tolerance < 0 {
+		return p, fmt.Errorf("tolerance must be positive")
+	}
+	if p.MinElapsed == 0 {
+		p.MinElapsed = 0.1
+	}
+	if p.MinElapsed < 0 || p.MinElapsed >= 1 {
+		return p, fmt.Errorf("min_elapsed must be between 0 and 1")
+	}
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return p, fmt.Errorf("invalid timezone %q", p.Timezone)
+		}
+	}
+	return p, nil
+}
+
+// pacingRule projects the spend at the end of the period and compares it
+// with the budget.
+type pacingRule struct {
+	ruleInfo
+	params PacingParams
+	column Column
+}
+
+// NewPacingRule returns a pacing rule, the params are validated.
+func NewPacingRule(params PacingParams, name, id string) (Rule, error) {
+	p, err := params.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	return &pacingRule{
+		ruleInfo: newRuleInfo(name, id),
+		params:   p,
+		column:   Column(p.Column),
+	}, nil
+}
+
+// ParsePacingParams decodes the JSON params of a pacing rule.
+func ParsePacingParams(data string) (PacingParams, error) {
+	var p PacingParams
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return p, fmt.Errorf("invalid pacing params: %w", err)
+	}
+	return p, nil
+}
+
+// Value implements Rule.
+func (r *pacingRule) Value() interface{} {
+	direction := "over"
+	switch r.params.Direction {
+	case PacingUnder:
+		direction = "under"
+	case PacingBoth:
+		direction = "over or under"
+	}
+	return fmt.Sprintf("end of %s %s projected %s the budget of %v by %v%%",
+		r.params.Period, strings.ToLower(r.params.Column), direction, r.params.Budget, r.params.Tolerance)
+}
+
+// entityLocation returns the timezone the days of the entity start in, the
+// one of its ad account unless overridden.
+func entityLocation(evt common.Event, timezone string) (*time.Location, error) {
+	name := timezone
+	if name == "" {
+		tz, err := evt.GetFieldValue("TIMEZONE")
+		if err != nil {
+			return nil, fmt.Errorf("could not get the timezone of the entity: %w", err)
+		}
+		name, _ = tz.(string)
+	}
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// Exec implements Rule.
+func (r *pacingRule) Exec(evt common.Event) (bool, error) {
+	h, ok := evt.(common.HistoricalEvent)
+	if !ok {
+		return false, fmt.Errorf("historical data is not available")
+	}
+	loc, err := entityLocation(evt, r.params.Timezone)
+	if err != nil {
+		return false, err
+	}
+	now := h.FetchedAt().In(loc)
+
+	project := r.projectDay
+	if r.params.Period == PacingMonth {
+		project = r.projectMonth
+	}
+	projected, ready, err := project(h, now)
+	if err != nil {
+		return false, err
+	}
+	if !ready {
+		// too early in the period to tell
+		return false, nil
+	}
+	observe(evt, r, r.column, projected)
+	observeBaseline(evt, r, r.params.Budget)
+
+	over := projected > r.params.Budget*(1+r.params.Tolerance/100)
+	under := projected < r.params.Budget*(1-r.params.Tolerance/100)
+	switch r.params.Direction {
+	case PacingUnder:
+		return under, nil
+	case PacingBoth:
+		return over || under, nil
+	}
+	return over, nil
+}
+
+// numericValue returns the current value of a numeric column of the event.
+func numericValue(evt common.Event, column Column) (float64, error) {
+	value, err := evt.GetFieldValue(column.String())
+	if err != nil {
+		return 0, err
+	}
+	current, ok := value.(float64)
+	if !ok {
+		return 0, fmt.Errorf("field %s is not numeric", column)
+	}
+	return current, nil
+}
+
+// projectDay projects the value at the end of the day, using the share of
+// the day value usually reached at this time when the snapshots of the
+// previous days allow it, linearly otherwise.
+func (r *pacingRule) projectDay(h common.HistoricalEvent, now time.Time) (float64, bool, error) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	elapsed := fraction(now, midnight, midnight.AddDate(0, 0, 1))
+	if elapsed < r.params.MinElapsed {
+		return 0, false, nil
+	}
+	current, err := numericValue(h, r.column)
+	if err != nil {
+		return 0, false, err
+	}
+
+	share, err := r.dayShare(h, now)
+	if err != nil {
+		return 0, false, err
+	}
+	if share == 0 {
+		share = elapsed
+	}
+	return current / share, true, nil
+}
+
+// dayShare returns the average share of the day value reached at the time
+// of now on the previous days, 0 without enough snapshots.
+func (r *pacingRule) dayShare(h common.HistoricalEvent, now time.Time) (float64, error) {
+	total, count := float64(0), 0
+	for i := 1; i <= profileDays; i++ {
+		at := now.AddDate(0, 0, -i)
+		partial, ok, err := h.GetFieldAt(r.column.String(), at)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		full, ok, err := h.GetFieldOnDay(r.column.String(), at)
+		if err != nil {
+			return 0, err
+		}
+		if !ok || full <= 0 || partial > full {
+			continue
+		}
+		total += partial / full
+		count++
+	}
+	if count < minProfileDays || total == 0 {
+		return 0, nil
+	}
+	return total / float64(count), nil
+}
+
+// projectMonth projects the value at the end of the month linearly, from
+// the stored days of the month and the current one.
+func (r *pacingRule) projectMonth(h common.HistoricalEvent, now time.Time) (float64, bool, error) {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	elapsed := fraction(now, start, start.AddDate(0, 1, 0))
+	if elapsed < r.params.MinElapsed {
+		return 0, false, nil
+	}
+	series, err := h.GetFieldSeries(r.column.String(), now.Day())
+	if err != nil {
+		return 0, false, err
+	}
+	total := float64(0)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, p := range series {
+		if !p.Day.Before(monthStart) {
+			total += p.Value
+		}
+	}
+	return total / elapsed, true, nil
+}
+
+// fraction returns the elapsed fraction of the [start, end) period at t.
+func fraction(t, start, end time.Time) float64 {
+	return float64(t.Sub(start)) / float64(end.Sub(start))
+}
@@ -0,0 +1,118 @@
+package rule
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+func TestPacingRule(t *testing.T) {
+	cases := []struct {
+		name     string
+		params   string
+		entities []string
+	}{
+		// fetched at noon: a1 spent 400, a2 10, so 800 and 20 at the end of the day
+		{name: "linear", params: `{"budget":500}`, entities: []string{"a1"}},
+		{name: "within tolerance", params: `{"budget":750,"tolerance":10}`, entities: []string{}},
+		{name: "under", params: `{"budget":100,"direction":"under"}`, entities: []string{"a2"}},
+		{name: "both", params: `{"budget":100,"direction":"both"}`, entities: []string{"a1", "a2"}},
+		{name: "too early", params: `{"budget":500,"min_elapsed":0.6}`, entities: []string{}},
+		// 8 am in New York: a third of the day is elapsed, a1 will spend 1200
+		{name: "timezone", params: `{"budget":1000,"timezone":"America/New_York"}`, entities: []string{"a1"}},
+		// 19 stored days and a half: a1 spends 1000 + 400 in 19.5/31 of the month
+		{name: "month", params: `{"budget":2000,"period":"month"}`, entities: []string{"a1"}},
+		{name: "month within budget", params: `{"budget":2100,"period":"month"}`, entities: []string{}},
+	}
+	for _, c := range cases {
+		r, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "pacing", Params: c.params, Scope: "account"})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		task, _ := historyTask()
+		results, err := Execute(r, task)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(results) != len(c.entities) {
+			t.Fatalf("%s: expected %d results, got %d", c.name, len(c.entities), len(results))
+		}
+		for idx, res := range results {
+			if res.EntityID != c.entities[idx] {
+				t.Fatalf("%s: expected entity %s, got %s", c.name, c.entities[idx], res.EntityID)
+			}
+		}
+	}
+}
+
+func TestPacingAccountTimezone(t *testing.T) {
+	r, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "pacing", Params: `{"budget":500}`, Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, _ := historyTask()
+	// 9 pm in Tokyo, a1 will spend 457
+	task.Accounts[0].Timezone = "Asia/Tokyo"
+	results, err := Execute(r, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected no results, got %d", len(results))
+	}
+}
+
+func TestPacingProfile(t *testing.T) {
+	r, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "pacing", Params: `{"budget":500}`, Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, h := historyTask()
+	// on the previous days a1 had spent 80% of its daily 100 by noon, so
+	// it will spend 500 today instead of 800
+	today := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 3; i++ {
+		day := today.AddDate(0, 0, -i)
+		h.accountSnapshots = append(h.accountSnapshots,
+			db.DbAccountSpend{AccountID: "a1", ProviderID: "p1", Spend: 80, DateRef: day, UpdatedAt: day.Add(11 * time.Hour)},
+		)
+	}
+	results, err := Execute(r, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected no results, got %+v", results[0])
+	}
+
+	r, _ = FromDbRule(db.DbRule{RuleID: "1", RuleType: "pacing", Params: `{"budget":400}`, Scope: "account"})
+	results, err = Execute(r, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if v := results[0].Value.(float64); math.Abs(v-500) > 1e-9 || results[0].Baseline != float64(400) {
+		t.Fatalf("unexpected result %+v", *results[0])
+	}
+}
+
+func TestPacingParams(t *testing.T) {
+	invalid := []string{
+		`{}`,
+		`{"budget":-1}`,
+		`{"budget":100,"column":"avg_cpc"}`,
+		`{"budget":100,"period":"week"}`,
+		`{"budget":100,"direction":"sideways"}`,
+		`{"budget":100,"min_elapsed":1}`,
+		`{"budget":100,"timezone":"Mars/Olympus"}`,
+	}
+	for _, params := range invalid {
+		if _, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "PACING", Params: params}); err == nil {
+			t.Fatalf("%s: expected an error", params)
+		}
+	}
+}
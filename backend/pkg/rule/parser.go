@@ -0,0 +1,516 @@
+package rule
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Compile parses a textual rule expression and compiles it into a Condition tree.
+//
+// The grammar, from the lowest to the highest precedence, is:
+//
+//	expr       := and ("or" and)*
+//	and        := unary ("and" unary)*
+//	unary      := "not" unary | "(" expr ")" | comparison
+//	comparison := operand op literal
+//	            | operand ["not"] "in" "(" literal ("," literal)* ")"
+//	            | operand "between" number "and" number
+//	operand    := column | window "(" column "," days "d" ")"
+//	            | change "(" column ["," baseline] ")"
+//	window     := "sum" | "avg" | "min" | "max"
+//	change     := "pct_change" | "delta"
+//	baseline   := "previous_day" | "same_day_last_week"
+//	            | "same_hour_yesterday" | "same_hour_last_week"
+//	op         := "==" | "=" | "!=" | "<" | "<=" | ">" | ">=" | "~"
+//	            | "contains" | "prefix" | "glob"
+//	literal    := number | "double quoted" | 'single quoted'
+//
+// Keywords and column names are case insensitive, `~` matches a string
+// column against a regular expression and glob against a pattern where `*`
+// matches any sequence of characters and `?` a single one. The bounds of
+// between are included. A window aggregates the daily values of a numeric
+// column over the last days, the fetched day included.
+// For example:
+//
+//	daily_spend > 200 and avg_cpc >= 1.5 or not campaign_name ~ "brand"
+//	sum(daily_spend, 7d) > 5000 or avg(avg_cpc, 14d) > 3
+//	business_id in ("b1", "b2") and campaign_name prefix "PROMO_"
+//	daily_spend between 100 and 500 and campaign_name glob "*_summer_*"
+//
+// A change compares the percent (pct_change) or absolute (delta) change of a
+// numeric column from its baseline, same_hour_yesterday by default, and can
+// only be compared with '>' or '<':
+//
+//	pct_change(daily_spend, same_hour_last_week) > 50
+func Compile(expression string) (Condition, error) {
+	p := &parser{lex: &lexer{input: expression}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokEOF {
+		return nil, &SyntaxError{Pos: p.tok.pos, Msg: "empty expression"}
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected("'and', 'or' or end of expression")
+	}
+	return cond, nil
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected(expected string) error {
+	found := p.tok.kind.String()
+	if p.tok.kind != tokEOF {
+		found = fmt.Sprintf("%s %q", found, p.tok.text)
+	}
+	return &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf("expected %s, found %s", expected, found)}
+}
+
+// parseLogical parses a list of operands joined by the given keyword,
+// flattening them into a single node.
+func (p *parser) parseLogical(kind tokenKind, op Operator, operand func() (Condition, error)) (Condition, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != kind {
+		return first, nil
+	}
+	node, err := NewConditionNode(op, op.GetFunc(nil))
+	if err != nil {
+		return nil, err
+	}
+	node.Append(first)
+	for p.tok.kind == kind {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		node.Append(next)
+	}
+	return node, nil
+}
+
+func (p *parser) parseOr() (Condition, error) {
+	return p.parseLogical(tokOr, OpOR, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (Condition, error) {
+	return p.parseLogical(tokAnd, OpAND, p.parseUnary)
+}
+
+func (p *parser) parseUnary() (Condition, error) {
+	switch p.tok.kind {
+	case tokNot:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negate(child)
+	case tokLParen:
+		open := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			if p.tok.kind == tokEOF {
+				return nil, &SyntaxError{Pos: open.pos, Msg: "unclosed '('"}
+			}
+			return nil, p.unexpected("')'")
+		}
+		return cond, p.advance()
+	case tokIdent:
+		return p.parseComparison()
+	}
+	return nil, p.unexpected("column name, 'not' or '('")
+}
+
+func (p *parser) parseComparison() (Condition, error) {
+	ident := p.tok
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var window *Window
+	var change string
+	baseline := NoBaseline
+	column := ColumnFromString(ident.text)
+	if fn, ok := WindowFuncFromString(ident.text); ok && p.tok.kind == tokLParen {
+		var err error
+		if column, window, err = p.parseWindow(fn); err != nil {
+			return nil, err
+		}
+	} else if fn := strings.ToLower(ident.text); (fn == "pct_change" || fn == "delta") && p.tok.kind == tokLParen {
+		var err error
+		if column, baseline, err = p.parseChange(); err != nil {
+			return nil, err
+		}
+		change = fn
+	} else if column == INVALID {
+		return nil, &SyntaxError{Pos: ident.pos, Msg: fmt.Sprintf("unknown column %q", ident.text)}
+	}
+
+	opTok := p.tok
+	var op Operator
+	negated := false
+	switch opTok.kind {
+	case tokEQ:
+		op = OpEQ
+	case tokNotEQ:
+		// compiled as not(column == value)
+		op, negated = OpEQ, true
+	case tokLT:
+		op = OpLT
+	case tokLTE:
+		op = OpLTE
+	case tokGT:
+		op = OpGT
+	case tokGTE:
+		op = OpGTE
+	case tokMatch:
+		if window != nil {
+			return nil, &SyntaxError{Pos: opTok.pos, Msg: "'~' cannot be applied to a window"}
+		}
+		op = OpREGEX
+	case tokNot:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokIdent || !strings.EqualFold(p.tok.text, "in") {
+			return nil, p.unexpected("'in'")
+		}
+		op = OpNotIN
+	case tokIdent:
+		switch strings.ToLower(opTok.text) {
+		case "in":
+			op = OpIN
+		case "between":
+			op = OpBETWEEN
+		case "contains":
+			op = OpCONTAINS
+		case "prefix":
+			op = OpPREFIX
+		case "glob":
+			op = OpGLOB
+		default:
+			return nil, p.unexpected("comparison operator")
+		}
+	default:
+		return nil, p.unexpected("comparison operator")
+	}
+	if change != "" {
+		switch {
+		case op == OpGT && change == "pct_change":
+			op = OpPctChangeGT
+		case op == OpLT && change == "pct_change":
+			op = OpPctChangeLT
+		case op == OpGT:
+			op = OpDeltaGT
+		case op == OpLT:
+			op = OpDeltaLT
+		default:
+			return nil, &SyntaxError{Pos: opTok.pos, Msg: fmt.Sprintf("%s can only be compared with '>' or '<'", change)}
+		}
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	valueTok := p.tok
+	var value any
+	var err error
+	switch op {
+	case OpIN, OpNotIN:
+		value, err = p.parseList()
+	case OpBETWEEN:
+		value, err = p.parseRange()
+	default:
+		value, err = p.parseValue(op, window != nil || change != "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := NewConditionLeaf(op)
+	if err != nil {
+		return nil, err
+	}
+	leaf.SetTargetField(column)
+	leaf.SetWindow(window)
+	leaf.SetBaseline(baseline)
+	leaf.SetValue(value)
+	if err := compileLeaf(leaf); err != nil {
+		return nil, &SyntaxError{Pos: valueTok.pos, Msg: err.Error()}
+	}
+	if negated {
+		return negate(leaf)
+	}
+	return leaf, nil
+}
+
+// parseValue parses the literal compared by the operator.
+func (p *parser) parseValue(op Operator, numeric bool) (any, error) {
+	valueTok := p.tok
+	var value any
+	switch valueTok.kind {
+	case tokNumber:
+		if op == OpREGEX {
+			return nil, &SyntaxError{Pos: valueTok.pos, Msg: "'~' requires a string pattern"}
+		}
+		f, err := strconv.ParseFloat(valueTok.text, 64)
+		if err != nil {
+			return nil, &SyntaxError{Pos: valueTok.pos, Msg: fmt.Sprintf("invalid number %q", valueTok.text)}
+		}
+		value = f
+	case tokString:
+		if numeric {
+			return nil, &SyntaxError{Pos: valueTok.pos, Msg: "a window or a change can only be compared to a number"}
+		}
+		if op == OpREGEX {
+			if _, err := regexp.Compile(valueTok.text); err != nil {
+				return nil, &SyntaxError{Pos: valueTok.pos, Msg: fmt.Sprintf("invalid regular expression: %v", err)}
+			}
+		}
+		value = valueTok.text
+	default:
+		return nil, p.unexpected("number or string")
+	}
+	return value, p.advance()
+}
+
+// parseList parses the "(value, ...)" list of the in operators.
+func (p *parser) parseList() ([]any, error) {
+	if p.tok.kind != tokLParen {
+		return nil, p.unexpected("'('")
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	list := make([]any, 0)
+	for {
+		value, err := p.parseValue(OpIN, false)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+		if p.tok.kind == tokRParen {
+			return list, p.advance()
+		}
+		if p.tok.kind != tokComma {
+			return nil, p.unexpected("',' or ')'")
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseRange parses the "low and high" bounds of the between operator.
+func (p *parser) parseRange() ([]any, error) {
+	if p.tok.kind != tokNumber {
+		return nil, p.unexpected("number")
+	}
+	low, err := p.parseValue(OpBETWEEN, true)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokAnd {
+		return nil, p.unexpected("'and'")
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokNumber {
+		return nil, p.unexpected("number")
+	}
+	high, err := p.parseValue(OpBETWEEN, true)
+	if err != nil {
+		return nil, err
+	}
+	return []any{low, high}, nil
+}
+
+// parseWindow parses the "(column, 7d)" part of a window, the current token
+// is the opening parenthesis.
+func (p *parser) parseWindow(fn WindowFunc) (Column, *Window, error) {
+	if err := p.advance(); err != nil {
+		return INVALID, nil, err
+	}
+	if p.tok.kind != tokIdent {
+		return INVALID, nil, p.unexpected("column name")
+	}
+	columnTok := p.tok
+	column := ColumnFromString(columnTok.text)
+	if column == INVALID {
+		return INVALID, nil, &SyntaxError{Pos: columnTok.pos, Msg: fmt.Sprintf("unknown column %q", columnTok.text)}
+	}
+	if err := validateWindowColumn(column); err != nil {
+		return INVALID, nil, &SyntaxError{Pos: columnTok.pos, Msg: err.Error()}
+	}
+	if err := p.advance(); err != nil {
+		return INVALID, nil, err
+	}
+	if p.tok.kind != tokComma {
+		return INVALID, nil, p.unexpected("','")
+	}
+	if err := p.advance(); err != nil {
+		return INVALID, nil, err
+	}
+	if p.tok.kind != tokDuration {
+		return INVALID, nil, p.unexpected("number of days (e.g. 7d)")
+	}
+	daysTok := p.tok
+	days, err := strconv.Atoi(daysTok.text[:len(daysTok.text)-1])
+	if err != nil {
+		return INVALID, nil, &SyntaxError{Pos: daysTok.pos, Msg: fmt.Sprintf("invalid number of days %q", daysTok.text)}
+	}
+	window, err := NewWindow(string(fn), days)
+	if err != nil {
+		return INVALID, nil, &SyntaxError{Pos: daysTok.pos, Msg: err.Error()}
+	}
+	if err := p.advance(); err != nil {
+		return INVALID, nil, err
+	}
+	if p.tok.kind != tokRParen {
+		return INVALID, nil, p.unexpected("')'")
+	}
+	return column, window, p.advance()
+}
+
+// parseChange parses the "(column[, baseline])" part of a change, the current
+// token is the opening parenthesis.
+func (p *parser) parseChange() (Column, Baseline, error) {
+	if err := p.advance(); err != nil {
+		return INVALID, NoBaseline, err
+	}
+	if p.tok.kind != tokIdent {
+		return INVALID, NoBaseline, p.unexpected("column name")
+	}
+	columnTok := p.tok
+	column := ColumnFromString(columnTok.text)
+	if column == INVALID {
+		return INVALID, NoBaseline, &SyntaxError{Pos: columnTok.pos, Msg: fmt.Sprintf("unknown column %q", columnTok.text)}
+	}
+	if err := validateWindowColumn(column); err != nil {
+		return INVALID, NoBaseline, &SyntaxError{Pos: columnTok.pos, Msg: err.Error()}
+	}
+	if err := p.advance(); err != nil {
+		return INVALID, NoBaseline, err
+	}
+	baseline := NoBaseline
+	if p.tok.kind == tokComma {
+		if err := p.advance(); err != nil {
+			return INVALID, NoBaseline, err
+		}
+		var ok bool
+		if baseline, ok = BaselineFromString(p.tok.text); p.tok.kind != tokIdent || !ok {
+			return INVALID, NoBaseline, p.unexpected("baseline (previous_day, same_day_last_week, same_hour_yesterday or same_hour_last_week)")
+		}
+		if err := p.advance(); err != nil {
+			return INVALID, NoBaseline, err
+		}
+	}
+	if p.tok.kind != tokRParen {
+		return INVALID, NoBaseline, p.unexpected("')'")
+	}
+	return column, baseline, p.advance()
+}
+
+func negate(c Condition) (Condition, error) {
+	node, err := NewConditionNode(OpNOT, OpNOT.GetFunc(nil))
+	if err != nil {
+		return nil, err
+	}
+	return node.Append(c), nil
+}
+
+// FormatCondition renders a Condition tree using the expression syntax
+// accepted by Compile.
+func FormatCondition(c Condition) string {
+	op := c.GetOperator()
+	switch op {
+	case OpAND, OpOR:
+		keyword := " and "
+		if op == OpOR {
+			keyword = " or "
+		}
+		parts := make([]string, 0, len(c.GetChildrens()))
+		for _, child := range c.GetChildrens() {
+			part := FormatCondition(child)
+			if childOp := child.GetOperator(); childOp.IsLogical() && childOp != OpNOT && childOp != op {
+				part = "(" + part + ")"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, keyword)
+	case OpNOT:
+		parts := make([]string, 0, len(c.GetChildrens()))
+		for _, child := range c.GetChildrens() {
+			part := FormatCondition(child)
+			if childOp := child.GetOperator(); childOp == OpAND || childOp == OpOR {
+				part = "(" + part + ")"
+			}
+			parts = append(parts, "not "+part)
+		}
+		return strings.Join(parts, " and ")
+	}
+
+	value := formatLiteral(c.GetValue())
+	if list, ok := c.GetValue().([]any); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, formatLiteral(item))
+		}
+		if op == OpBETWEEN {
+			value = strings.Join(items, " and ")
+		} else {
+			value = "(" + strings.Join(items, ", ") + ")"
+		}
+	}
+	operand := strings.ToLower(c.GetTargetField().String())
+	if w := c.GetWindow(); w != nil {
+		operand = w.format(c.GetTargetField())
+	}
+	if op.IsChange() {
+		operand = fmt.Sprintf("%s(%s, %s)", changeFunction(op), operand, c.GetBaseline().format())
+	}
+	return fmt.Sprintf("%s %s %s", operand, op.Symbol(), value)
+}
+
+func formatLiteral(v any) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	// the lexer reads no exponent, large and small numbers are written out
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
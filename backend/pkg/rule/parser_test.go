@@ -0,0 +1,176 @@
+package rule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+func taskWithSpend(spend float64) *common.FetchTask {
+	task := common.NewFetchTask(time.Now(), time.Now())
+	task.Accounts = append(task.Accounts, db.DbAccountSpend{
+		Spend: spend,
+	})
+	return task
+}
+
+func TestCompile(t *testing.T) {
+	cases := []struct {
+		expression string
+		spend      float64
+		match      bool
+	}{
+		{expression: "daily_spend > 200", spend: 250, match: true},
+		{expression: "DAILY_SPEND <= 200", spend: 250, match: false},
+		{expression: "daily_spend = 10", spend: 10, match: true},
+		{expression: "daily_spend != 10", spend: 10, match: false},
+		// and binds tighter than or
+		{expression: "daily_spend > 100 and daily_spend < 50 or daily_spend == 20", spend: 20, match: true},
+		{expression: "daily_spend > 100 and (daily_spend < 50 or daily_spend == 20)", spend: 20, match: false},
+		{expression: "not daily_spend > 100", spend: 20, match: true},
+		{expression: "not (daily_spend > 10 and daily_spend < 30)", spend: 20, match: false},
+		{expression: "daily_spend > -1.5", spend: 0, match: true},
+	}
+	for _, c := range cases {
+		cond, err := Compile(c.expression)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		match, err := cond.Exec(taskWithSpend(c.spend))
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		if match != c.match {
+			t.Fatalf("%s with spend %v: expected match=%v, got %v", c.expression, c.spend, c.match, match)
+		}
+	}
+}
+
+func TestCompileFlattens(t *testing.T) {
+	cond, err := Compile("daily_spend > 1 and daily_spend > 2 and daily_spend > 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cond.GetOperator() != OpAND || len(cond.GetChildrens()) != 3 {
+		t.Fatalf("expected a single and node with 3 children, got %s", FormatCondition(cond))
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		expression string
+		pos        int
+	}{
+		{expression: "", pos: 0},
+		{expression: "daily_spend >", pos: 13},
+		{expression: "unknown_metric > 1", pos: 0},
+		{expression: "daily_spend > 1 and", pos: 19},
+		{expression: "(daily_spend > 1", pos: 0},
+		{expression: "daily_spend > 1)", pos: 15},
+		{expression: "daily_spend ! 1", pos: 12},
+		{expression: `daily_spend ~ "unterminated`, pos: 14},
+		{expression: `daily_spend ~ "[a-"`, pos: 14},
+		{expression: "daily_spend > 10abc", pos: 16},
+	}
+	for _, c := range cases {
+		_, err := Compile(c.expression)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("%q: expected a syntax error, got %v", c.expression, err)
+		}
+		if syntaxErr.Pos != c.pos {
+			t.Fatalf("%q: expected error at %d, got %d (%v)", c.expression, c.pos, syntaxErr.Pos, err)
+		}
+	}
+}
+
+func TestFormatCondition(t *testing.T) {
+	expressions := []string{
+		"daily_spend > 200 and (daily_spend < 1 or not daily_spend == 5)",
+		`campaign_name ~ "^\\d+$"`,
+	}
+	for _, expression := range expressions {
+		cond, err := Compile(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatted := FormatCondition(cond)
+		if formatted != expression {
+			t.Fatalf("expected %s, got %s", expression, formatted)
+		}
+		if _, err := Compile(formatted); err != nil {
+			t.Fatalf("%s: %v", formatted, err)
+		}
+	}
+}
+
+func TestFormatNumbers(t *testing.T) {
+	// 1e-7 and 1000000 are printed in exponent form by default
+	expressions := []string{"daily_spend > 1000000", "avg_cpc < 0.0000001"}
+	for _, expression := range expressions {
+		cond, err := Compile(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatted := FormatCondition(cond)
+		if formatted != expression {
+			t.Fatalf("expected %s, got %s", expression, formatted)
+		}
+		again, err := Compile(formatted)
+		if err != nil {
+			t.Fatalf("%s: %v", formatted, err)
+		}
+		if FormatCondition(again) != expression {
+			t.Fatalf("expected %s, got %s", expression, FormatCondition(again))
+		}
+	}
+}
+
+func TestExpressionRule(t *testing.T) {
+	r, err := FromDbRule(db.DbRule{RuleID: "1", RuleName: "expr", Expression: "daily_spend > 10 and daily_spend < 30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, err := r.Exec(taskWithSpend(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Fatal("Expected match")
+	}
+	if _, err := FromDbRule(db.DbRule{RuleID: "2", Expression: "daily_spend >"}); err == nil {
+		t.Fatal("expected an error for an invalid expression")
+	}
+}
+
+func TestRegexCompiledOnce(t *testing.T) {
+	cond, err := Compile(`account_name ~ "^Acc"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(cond); err != nil {
+		t.Fatal(err)
+	}
+	leaf := cond.(*ConditionLeaf)
+	if leaf.pattern == nil || leaf.pattern.String() != "^Acc" {
+		t.Fatalf("expected the pattern to be compiled with the value, got %v", leaf.pattern)
+	}
+	pattern := leaf.pattern
+	task := taskWithSpend(10)
+	task.Accounts[0].AccountName = "Account 1"
+	for i := 0; i < 2; i++ {
+		match, err := cond.Exec(task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !match {
+			t.Fatal("Expected match")
+		}
+	}
+	if leaf.pattern != pattern {
+		t.Fatal("the pattern was compiled again")
+	}
+}
@@ -5,21 +5,10 @@ import (
 )
 
 type simpleRule struct {
-	name      string
-	id        string
+	ruleInfo
 	condition Condition
 }
 
-// Id implements Rule.
-func (s *simpleRule) Id() string {
-	return s.id
-}
-
-// Name implements Rule.
-func (s *simpleRule) Name() string {
-	return s.name
-}
-
 // Name implements Rule.
 func (s *simpleRule) Value() interface{} {
 	return s.condition.GetValue()
@@ -32,17 +21,29 @@ func (s *simpleRule) Exec(taskResult common.Event) (bool, error) {
 
 }
 
+// NewSimpleRule returns a rule comparing the column with the value, nil if
+// the comparison is invalid.
 func NewSimpleRule(column Column, operator Operator, value interface{}, name, id string) Rule {
-	cond, err := NewConditionLeaf(operator)
+	s, err := newSimpleRule(column, operator, value, name, id)
 	if err != nil {
 		return nil
 	}
+	return s
+}
+
+func newSimpleRule(column Column, operator Operator, value interface{}, name, id string) (Rule, error) {
+	cond, err := NewConditionLeaf(operator)
+	if err != nil {
+		return nil, err
+	}
 	cond.SetTargetField(column)
 	cond.SetValue(value)
+	if err := compileLeaf(cond); err != nil {
+		return nil, err
+	}
 	s := &simpleRule{
-		name:      name,
-		id:        id,
+		ruleInfo:  newRuleInfo(name, id),
 		condition: cond,
 	}
-	return s
+	return s, nil
 }
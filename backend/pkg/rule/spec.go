@@ -0,0 +1,149 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ConditionSpec is the serialized form of a Condition tree, as stored in
+// the `conditions` column of client_rules.
+//
+// A logical node (and / or / not) only carries children:
+//
+//	{"operator": "and", "children": [...]}
+//
+// while a leaf compares a column against a value:
+//
+//	{"operator": "gt", "column": "daily_spend", "value": 500}
+//
+// optionally over a trailing window of days:
+//
+//	{"operator": "gt", "column": "daily_spend", "value": 5000, "window": {"days": 7, "func": "sum"}}
+//
+// the set operators take a list of values, the lower and upper bounds for
+// between:
+//
+//	{"operator": "in", "column": "business_id", "value": ["b1", "b2"]}
+//	{"operator": "between", "column": "daily_spend", "value": [100, 500]}
+//
+// and the change operators compare the column against a baseline period:
+//
+//	{"operator": "pct_change_gt", "column": "daily_spend", "value": 50, "baseline": "same_hour_yesterday"}
+type ConditionSpec struct {
+	Operator string          `json:"operator"`
+	Column   string          `json:"column,omitempty"`
+	Value    any             `json:"value,omitempty"`
+	Window   *Window         `json:"window,omitempty"`
+	Baseline string          `json:"baseline,omitempty"`
+	Children []ConditionSpec `json:"children,omitempty"`
+}
+
+// ParseConditions decodes a JSON condition tree and builds the matching Condition.
+func ParseConditions(data string) (Condition, error) {
+	var spec ConditionSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		return nil, fmt.Errorf("invalid condition tree: %w", err)
+	}
+	return BuildCondition(spec)
+}
+
+// BuildCondition rebuilds the Condition tree described by spec.
+func BuildCondition(spec ConditionSpec) (Condition, error) {
+	op := OperatorFromString(spec.Operator)
+	if op == OpINVALID {
+		return nil, fmt.Errorf("invalid operator %q", spec.Operator)
+	}
+
+	if op.IsLogical() {
+		if len(spec.Children) == 0 {
+			return nil, fmt.Errorf("operator %s requires at least one child", op)
+		}
+		if spec.Window != nil || spec.Baseline != "" {
+			return nil, fmt.Errorf("operator %s cannot have a window or a baseline", op)
+		}
+		if op == OpNOT && len(spec.Children) != 1 {
+			return nil, fmt.Errorf("operator %s requires exactly one child", op)
+		}
+		node, err := NewConditionNode(op, op.GetFunc(nil))
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range spec.Children {
+			c, err := BuildCondition(child)
+			if err != nil {
+				return nil, err
+			}
+			node.Append(c)
+		}
+		return node, nil
+	}
+
+	if len(spec.Children) > 0 {
+		return nil, fmt.Errorf("operator %s cannot have children", op)
+	}
+	column := ColumnFromString(spec.Column)
+	if column == INVALID {
+		return nil, fmt.Errorf("invalid column %q", spec.Column)
+	}
+	if spec.Value == nil {
+		return nil, fmt.Errorf("missing value for column %q", spec.Column)
+	}
+	leaf, err := NewConditionLeaf(op)
+	if err != nil {
+		return nil, err
+	}
+	leaf.SetTargetField(column)
+	if op.IsChange() {
+		if err := validateWindowColumn(column); err != nil {
+			return nil, err
+		}
+		if spec.Window != nil {
+			return nil, fmt.Errorf("operator %s cannot have a window", op)
+		}
+		if spec.Baseline != "" {
+			baseline, ok := BaselineFromString(spec.Baseline)
+			if !ok {
+				return nil, fmt.Errorf("invalid baseline %q", spec.Baseline)
+			}
+			leaf.SetBaseline(baseline)
+		}
+	} else if spec.Baseline != "" {
+		return nil, fmt.Errorf("operator %s cannot have a baseline", op)
+	}
+	if spec.Window != nil {
+		if op == OpREGEX {
+			return nil, fmt.Errorf("operator %s cannot have a window", op)
+		}
+		if err := validateWindowColumn(column); err != nil {
+			return nil, err
+		}
+		window, err := NewWindow(string(spec.Window.Func), spec.Window.Days)
+		if err != nil {
+			return nil, err
+		}
+		leaf.SetWindow(window)
+	}
+	leaf.SetValue(spec.Value)
+	if err := compileLeaf(leaf); err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+// SpecFromCondition serializes a Condition tree back to its ConditionSpec.
+func SpecFromCondition(c Condition) ConditionSpec {
+	spec := ConditionSpec{
+		Operator: c.GetOperator().String(),
+	}
+	if c.GetOperator().IsLogical() {
+		for _, child := range c.GetChildrens() {
+			spec.Children = append(spec.Children, SpecFromCondition(child))
+		}
+		return spec
+	}
+	spec.Column = c.GetTargetField().String()
+	spec.Value = c.GetValue()
+	spec.Window = c.GetWindow()
+	spec.Baseline = string(c.GetBaseline())
+	return spec
+}
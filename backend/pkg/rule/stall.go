@@ -0,0 +1,153 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/metric"
+)
+
+// StallParams configures a stall rule, it's stored as JSON in the `params`
+// column of client_rules. Zero values take the defaults.
+type StallParams struct {
+	// Column is an additive metric, DAILY_SPEND by default.
+	Column string `json:"column,omitempty"`
+	// Minutes the value must have stayed at zero, or stopped increasing
+	// between the intraday snapshots, 120 by default.
+	Minutes int `json:"minutes,omitempty"`
+	// MinPrevious is the value the entity must have exceeded the previous
+	// day to be expected to deliver.
+	MinPrevious float64 `json:"min_previous,omitempty"`
+	// IncludeInactive also checks the entities with an INACTIVE status,
+	// which are paused on purpose and skipped by default.
+	IncludeInactive bool `json:"include_inactive,omitempty"`
+	// Timezone overrides the timezone of the ad accounts.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (p StallParams) withDefaults() (StallParams, error) {
+	if p.Column == "" {
+		p.Column = DAILY_SPEND.String()
+	}
+	column := ColumnFromString(p.Column)
+	if column == INVALID {
+		return p, fmt.Errorf("invalid column %q", p.Column)
+	}
+	if m, _ := column.Metric(); m.Aggregation != metric.AggSum {
+		return p, fmt.Errorf("column %s cannot stall, it's not additive", column)
+	}
+	p.Column = column.String()
+
+	if p.Minutes == 0 {
+		p.Minutes = 120
+	}
+	if p.Minutes < 0 || p.Minutes >= 24*60 {
+		return p, fmt.Errorf("minutes must be between 1 and 1439")
+	}
+	if p.MinPrevious < 0 {
+		return p, fmt.Errorf("min_previous must be positive")
+	}
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return p, fmt.Errorf("invalid timezone %q", p.Timezone)
+		}
+	}
+	return p, nil
+}
+
+// stallRule detects the entities that were delivering the previous day and
+// stopped: their value stayed at zero since the start of the day, or didn't
+// increase between the snapshots, for the configured time.
+type stallRule struct {
+	ruleInfo
+	params StallParams
+	column Column
+}
+
+// NewStallRule returns a stall rule, the params are validated.
+func NewStallRule(params StallParams, name, id string) (Rule, error) {
+	p, err := params.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	return &stallRule{
+		ruleInfo: newRuleInfo(name, id),
+		params:   p,
+		column:   Column(p.Column),
+	}, nil
+}
+
+// ParseStallParams decodes the JSON params of a stall rule, empty params
+// take the defaults.
+func ParseStallParams(data string) (StallParams, error) {
+	var p StallParams
+	if strings.TrimSpace(data) == "" {
+		return p, nil
+	}
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return p, fmt.Errorf("invalid stall params: %w", err)
+	}
+	return p, nil
+}
+
+// Value implements Rule.
+func (r *stallRule) Value() interface{} {
+	return fmt.Sprintf("%s stalled for %d minutes after more than %v the previous day",
+		strings.ToLower(r.params.Column), r.params.Minutes, r.params.MinPrevious)
+}
+
+// Exec implements Rule.
+func (r *stallRule) Exec(evt common.Event) (bool, error) {
+	h, ok := evt.(common.HistoricalEvent)
+	if !ok {
+		return false, fmt.Errorf("historical data is not available")
+	}
+	if !r.params.IncludeInactive {
+		if status, err := evt.GetFieldValue("STATUS"); err == nil && status == "INACTIVE" {
+			return false, nil
+		}
+	}
+	loc, err := entityLocation(evt, r.params.Timezone)
+	if err != nil {
+		return false, err
+	}
+	now := h.FetchedAt().In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	since := now.Add(-time.Duration(r.params.Minutes) * time.Minute)
+	if since.Before(midnight) {
+		// too early in the day to tell
+		return false, nil
+	}
+
+	previous, ok, err := h.GetFieldOnDay(r.column.String(), midnight.AddDate(0, 0, -1))
+	if err != nil {
+		return false, err
+	}
+	if !ok || previous <= r.params.MinPrevious {
+		// not delivering the previous day, nothing to stop
+		return false, nil
+	}
+	current, err := numericValue(h, r.column)
+	if err != nil {
+		return false, err
+	}
+	observe(evt, r, r.column, current)
+	if current == 0 {
+		observeBaseline(evt, r, previous)
+		return true, nil
+	}
+
+	past, ok, err := h.GetFieldAt(r.column.String(), since)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		// no snapshot of the day old enough to compare with
+		return false, nil
+	}
+	observeBaseline(evt, r, past)
+	return current <= past, nil
+}
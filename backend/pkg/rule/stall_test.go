@@ -0,0 +1,123 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+// stallTask returns a task fetched today at noon. The previous day a1 spent
+// 100, a3 50, a5 30 and a4 nothing; campaigns k1 and k2 of a1 spent 80 and
+// 20. Today a1 is stuck at the 40 it had spent at 9, a2 is still spending,
+// a5 is paused and a3 and k2 are missing from the fetch.
+func stallTask() *common.FetchTask {
+	today := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+	h := &testHistory{}
+	h.accounts = append(h.accounts,
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: 100, DateRef: yesterday},
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a2", Spend: 10, DateRef: yesterday},
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a3", AccountName: "Account 3", Spend: 50, DateRef: yesterday},
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a4", Spend: 0, DateRef: yesterday},
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a5", Spend: 30, DateRef: yesterday},
+	)
+	h.campaigns = append(h.campaigns,
+		db.DbCampaignSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", CampaignID: "k1", Spend: 80, DateRef: yesterday},
+		db.DbCampaignSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", CampaignID: "k2", CampaignName: "brand", Spend: 20, DateRef: yesterday},
+	)
+	h.accountSnapshots = append(h.accountSnapshots,
+		db.DbAccountSpend{ProviderID: "p1", AccountID: "a1", Spend: 40, DateRef: today, UpdatedAt: today.Add(9 * time.Hour)},
+		db.DbAccountSpend{ProviderID: "p1", AccountID: "a2", Spend: 2, DateRef: today, UpdatedAt: today.Add(9 * time.Hour)},
+	)
+	h.campaignSnapshots = append(h.campaignSnapshots,
+		db.DbCampaignSpend{ProviderID: "p1", AccountID: "a1", CampaignID: "k1", Spend: 10, DateRef: today, UpdatedAt: today.Add(9 * time.Hour)},
+	)
+
+	task := common.NewFetchTask(today, today.Add(12*time.Hour))
+	task.Accounts = append(task.Accounts,
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: 40, Status: "ACTIVE", DateRef: today},
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a2", Spend: 5, Status: "ACTIVE", DateRef: today},
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a4", Spend: 0, Status: "ACTIVE", DateRef: today},
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a5", Spend: 0, Status: "INACTIVE", DateRef: today},
+	)
+	task.Campaigns = append(task.Campaigns,
+		db.DbCampaignSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", CampaignID: "k1", Spend: 40, DateRef: today},
+	)
+	return task.WithHistory("c1", h)
+}
+
+func TestStallRule(t *testing.T) {
+	cases := []struct {
+		name     string
+		scope    string
+		params   string
+		entities []string
+	}{
+		{name: "default", scope: "account", params: ``, entities: []string{"a1", "a3"}},
+		// no snapshot of a1 before 8, only the missing a3 is known to be stalled
+		{name: "longer", scope: "account", params: `{"minutes":240}`, entities: []string{"a3"}},
+		{name: "too early", scope: "account", params: `{"minutes":780}`, entities: []string{}},
+		{name: "min previous", scope: "account", params: `{"min_previous":60}`, entities: []string{"a1"}},
+		{name: "inactive", scope: "account", params: `{"include_inactive":true}`, entities: []string{"a1", "a5", "a3"}},
+		{name: "campaigns", scope: "campaign", params: `{}`, entities: []string{"k2"}},
+		{name: "impressions", scope: "account", params: `{"column":"daily_impressions"}`, entities: []string{}},
+	}
+	for _, c := range cases {
+		r, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "stall", Params: c.params, Scope: c.scope})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		results, err := Execute(r, stallTask())
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(results) != len(c.entities) {
+			t.Fatalf("%s: expected %d results, got %d", c.name, len(c.entities), len(results))
+		}
+		for idx, res := range results {
+			if res.EntityID != c.entities[idx] {
+				t.Fatalf("%s: expected entity %s, got %s", c.name, c.entities[idx], res.EntityID)
+			}
+		}
+	}
+}
+
+func TestStallResultValues(t *testing.T) {
+	r, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "stall", Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Execute(r, stallTask())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	// a1 compared with its snapshot, a3 with the previous day
+	if results[0].Value != float64(40) || results[0].Baseline != float64(40) {
+		t.Fatalf("unexpected result %+v", *results[0])
+	}
+	if results[1].Value != float64(0) || results[1].Baseline != float64(50) || results[1].EntityName != "Account 3" {
+		t.Fatalf("unexpected result %+v", *results[1])
+	}
+}
+
+func TestStallParams(t *testing.T) {
+	invalid := []string{
+		`{"column":"avg_cpc"}`,
+		`{"column":"unknown"}`,
+		`{"minutes":-5}`,
+		`{"minutes":1440}`,
+		`{"min_previous":-1}`,
+		`{"timezone":"Mars/Olympus"}`,
+		`{`,
+	}
+	for _, params := range invalid {
+		if _, err := FromDbRule(db.DbRule{RuleID: "1", RuleType: "STALL", Params: params}); err == nil {
+			t.Fatalf("%s: expected an error", params)
+		}
+	}
+}
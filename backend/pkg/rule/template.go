@@ -0,0 +1,217 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+// Types of the template parameters.
+const (
+	ParamNumber  = "number"
+	ParamInteger = "integer"
+	ParamString  = "string"
+	// ParamChoice is substituted as is, as a keyword of the expression, and
+	// must be one of the choices of the parameter.
+	ParamChoice = "choice"
+)
+
+// TemplateParam is a parameter of a rule template, substituted for its
+// ${name} placeholders.
+type TemplateParam struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Type        string   `json:"type"`
+	Choices     []string `json:"choices,omitempty"`
+	// Default is used when the parameter is not given, the parameters
+	// without default are required.
+	Default any `json:"default,omitempty"`
+}
+
+// Template is a parameterized rule, instantiated as a normal rule of a client.
+// The placeholders of the expression are substituted with literals of the
+// expression language, the ones of the params with JSON values.
+type Template struct {
+	Name        string          `json:"name"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Scope       string          `json:"scope"`
+	RuleType    string          `json:"rule_type"`
+	Expression  string          `json:"expression,omitempty"`
+	Params      string          `json:"params,omitempty"`
+	Severity    string          `json:"severity"`
+	Parameters  []TemplateParam `json:"parameters"`
+	// SustainEvaluations is the number of consecutive evaluations the
+	// instantiated rule must match at before firing.
+	SustainEvaluations uint32 `json:"sustain_evaluations,omitempty"`
+}
+
+var (
+	templatesMx sync.RWMutex
+	templates   = make(map[string]*Template)
+
+	placeholderRegexp = regexp.MustCompile(`\$\{(\w+)\}`)
+)
+
+// RegisterTemplate adds a template to the catalog, every placeholder must be
+// a parameter of the template.
+func RegisterTemplate(t Template) error {
+	t.Name = strings.ToLower(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+	names := make(map[string]bool, len(t.Parameters))
+	for _, p := range t.Parameters {
+		switch p.Type {
+		case ParamNumber, ParamInteger, ParamString:
+		case ParamChoice:
+			if len(p.Choices) == 0 {
+				return fmt.Errorf("template %s: parameter %s has no choices", t.Name, p.Name)
+			}
+		default:
+			return fmt.Errorf("template %s: parameter %s has an invalid type %q", t.Name, p.Name, p.Type)
+		}
+		names[p.Name] = true
+	}
+	for _, match := range placeholderRegexp.FindAllStringSubmatch(t.Expression+t.Params, -1) {
+		if !names[match[1]] {
+			return fmt.Errorf("template %s: unknown parameter %s", t.Name, match[1])
+		}
+	}
+
+	templatesMx.Lock()
+	defer templatesMx.Unlock()
+	if _, exist := templates[t.Name]; exist {
+		return fmt.Errorf("template %s is already registered", t.Name)
+	}
+	templates[t.Name] = &t
+	return nil
+}
+
+// MustRegisterTemplate is like RegisterTemplate but panics on error, it's
+// meant to be used in init functions.
+func MustRegisterTemplate(t Template) {
+	if err := RegisterTemplate(t); err != nil {
+		panic(err)
+	}
+}
+
+// LookupTemplate returns the template with the given name.
+func LookupTemplate(name string) (*Template, bool) {
+	templatesMx.RLock()
+	defer templatesMx.RUnlock()
+	t, ok := templates[strings.ToLower(name)]
+	return t, ok
+}
+
+// Templates returns the catalog of templates sorted by name.
+func Templates() []*Template {
+	templatesMx.RLock()
+	defer templatesMx.RUnlock()
+	res := make([]*Template, 0, len(templates))
+	for _, t := range templates {
+		res = append(res, t)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// Instantiate returns the rule of the client made from the template with the
+// parameters, the rule is validated as the worker would load it.
+func (t *Template) Instantiate(clientID, ruleName string, params map[string]any) (db.DbRule, error) {
+	values := make(map[string]any, len(t.Parameters))
+	for _, p := range t.Parameters {
+		value, ok := params[p.Name]
+		if !ok || value == nil {
+			if p.Default == nil {
+				return db.DbRule{}, fmt.Errorf("missing parameter %s", p.Name)
+			}
+			value = p.Default
+		}
+		value, err := p.check(value)
+		if err != nil {
+			return db.DbRule{}, err
+		}
+		values[p.Name] = value
+	}
+	for name := range params {
+		if _, ok := values[name]; !ok {
+			return db.DbRule{}, fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+
+	if ruleName == "" {
+		ruleName = t.Title
+	}
+	r := db.DbRule{
+		ClientID:           clientID,
+		RuleName:           ruleName,
+		Scope:              strings.ToUpper(t.Scope),
+		RuleType:           strings.ToUpper(t.RuleType),
+		Severity:           t.Severity,
+		SustainEvaluations: t.SustainEvaluations,
+		Expression:         substitute(t.Expression, values, formatLiteral),
+		Params:             substitute(t.Params, values, jsonLiteral),
+	}
+	if _, err := FromDbRule(r); err != nil {
+		return db.DbRule{}, err
+	}
+	return r, nil
+}
+
+// check returns the value of the parameter in its type.
+func (p TemplateParam) check(value any) (any, error) {
+	value = normalizeValue(value)
+	switch p.Type {
+	case ParamNumber, ParamInteger:
+		f, ok := value.(float64)
+		if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("parameter %s must be a number, got %v", p.Name, value)
+		}
+		if p.Type == ParamInteger && f != math.Trunc(f) {
+			return nil, fmt.Errorf("parameter %s must be an integer, got %v", p.Name, value)
+		}
+		return f, nil
+	case ParamChoice:
+		s, ok := value.(string)
+		if !ok || !slices.Contains(p.Choices, s) {
+			return nil, fmt.Errorf("parameter %s must be one of %s, got %v", p.Name, strings.Join(p.Choices, ", "), value)
+		}
+		return choice(s), nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("parameter %s must be a string, got %v", p.Name, value)
+	}
+	return s, nil
+}
+
+// choice is a parameter substituted as is.
+type choice string
+
+// substitute replaces the placeholders of the text with the formatted values.
+func substitute(text string, values map[string]any, format func(any) string) string {
+	return placeholderRegexp.ReplaceAllStringFunc(text, func(placeholder string) string {
+		value := values[placeholderRegexp.FindStringSubmatch(placeholder)[1]]
+		if c, ok := value.(choice); ok {
+			return string(c)
+		}
+		return format(value)
+	})
+}
+
+func jsonLiteral(value any) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
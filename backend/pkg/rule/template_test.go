@@ -0,0 +1,142 @@
+package rule
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBuiltinTemplates(t *testing.T) {
+	required := map[string]map[string]any{
+		"daily_overspend":     {"threshold": 100},
+		"daily_budget_pacing": {"budget": 500},
+	}
+	names := []string{"campaign_stalled", "campaign_stopped_delivering", "cpc_spike", "daily_budget_pacing", "daily_overspend", "zero_spend_account"}
+	catalog := Templates()
+	if len(catalog) != len(names) {
+		t.Fatalf("expected %d templates, got %d", len(names), len(catalog))
+	}
+	for idx, tmpl := range catalog {
+		if tmpl.Name != names[idx] {
+			t.Fatalf("expected template %s, got %s", names[idx], tmpl.Name)
+		}
+		r, err := tmpl.Instantiate("c1", "", required[tmpl.Name])
+		if err != nil {
+			t.Fatalf("%s: %v", tmpl.Name, err)
+		}
+		if r.ClientID != "c1" || r.RuleName != tmpl.Title || r.Scope != tmpl.Scope || r.Severity != tmpl.Severity {
+			t.Fatalf("%s: unexpected rule %+v", tmpl.Name, r)
+		}
+	}
+}
+
+func TestTemplateSubstitution(t *testing.T) {
+	cases := []struct {
+		template   string
+		params     map[string]any
+		expression string
+	}{
+		{template: "daily_overspend", params: map[string]any{"threshold": 200}, expression: "daily_spend > 200"},
+		{template: "daily_overspend", params: map[string]any{"threshold": json.Number("99.5")}, expression: "daily_spend > 99.5"},
+		// the budgets in JPY, IDR or KRW
+		{template: "daily_overspend", params: map[string]any{"threshold": 1000000}, expression: "daily_spend > 1000000"},
+		{
+			template:   "campaign_stopped_delivering",
+			params:     map[string]any{"min_impressions": 5000000},
+			expression: "daily_impressions == 0 and sum(daily_impressions, 3d) > 5000000",
+		},
+		{
+			template:   "cpc_spike",
+			params:     map[string]any{"percent": 30, "baseline": "same_day_last_week"},
+			expression: "pct_change(avg_cpc, same_day_last_week) > 30 and daily_clicks >= 20",
+		},
+		{
+			template:   "zero_spend_account",
+			params:     map[string]any{"days": 14.0},
+			expression: "daily_spend == 0 and avg(daily_spend, 14d) > 10",
+		},
+	}
+	for _, c := range cases {
+		tmpl, ok := LookupTemplate(c.template)
+		if !ok {
+			t.Fatalf("unknown template %s", c.template)
+		}
+		r, err := tmpl.Instantiate("c1", "my rule", c.params)
+		if err != nil {
+			t.Fatalf("%s: %v", c.template, err)
+		}
+		if r.Expression != c.expression {
+			t.Fatalf("%s: expected %q, got %q", c.template, c.expression, r.Expression)
+		}
+		if r.RuleName != "my rule" {
+			t.Fatalf("%s: expected the given rule name, got %q", c.template, r.RuleName)
+		}
+	}
+
+	tmpl, _ := LookupTemplate("daily_budget_pacing")
+	r, err := tmpl.Instantiate("c1", "", map[string]any{"budget": 250, "tolerance": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var params map[string]any
+	if err := json.Unmarshal([]byte(r.Params), &params); err != nil {
+		t.Fatalf("invalid params %s: %v", r.Params, err)
+	}
+	if params["budget"] != 250.0 || params["tolerance"] != 5.0 || params["period"] != "day" {
+		t.Fatalf("unexpected params %s", r.Params)
+	}
+}
+
+func TestTemplateParamErrors(t *testing.T) {
+	cases := []struct {
+		template string
+		params   map[string]any
+	}{
+		{template: "daily_overspend", params: nil},
+		{template: "daily_overspend", params: map[string]any{"threshold": 100, "other": 1}},
+		{template: "daily_overspend", params: map[string]any{"threshold": "100; drop"}},
+		{template: "zero_spend_account", params: map[string]any{"days": 1.5}},
+		{template: "cpc_spike", params: map[string]any{"baseline": "last_year"}},
+		{template: "cpc_spike", params: map[string]any{"baseline": 1}},
+	}
+	for _, c := range cases {
+		tmpl, _ := LookupTemplate(c.template)
+		if _, err := tmpl.Instantiate("c1", "", c.params); err == nil {
+			t.Fatalf("%s %v: expected an error", c.template, c.params)
+		}
+	}
+}
+
+func TestRegisterTemplateErrors(t *testing.T) {
+	invalid := []Template{
+		{},
+		{Name: "daily_overspend", Expression: "daily_spend > 1"},
+		{Name: "unknown_param", Expression: "daily_spend > ${missing}"},
+		{Name: "invalid_type", Expression: "daily_spend > ${x}", Parameters: []TemplateParam{{Name: "x", Type: "date"}}},
+		{Name: "no_choices", Expression: "daily_spend > ${x}", Parameters: []TemplateParam{{Name: "x", Type: ParamChoice}}},
+	}
+	for _, tmpl := range invalid {
+		if err := RegisterTemplate(tmpl); err == nil {
+			t.Fatalf("%s: expected an error", tmpl.Name)
+		}
+	}
+}
+
+func TestTemplateRuleExecution(t *testing.T) {
+	tmpl, _ := LookupTemplate("daily_overspend")
+	dbRule, err := tmpl.Instantiate("c1", "", map[string]any{"threshold": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbRule.RuleID = "1"
+	r, err := FromDbRule(dbRule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Execute(r, entityTask())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].EntityID != "a1" {
+		t.Fatalf("expected account a1 to match, got %+v", results)
+	}
+}
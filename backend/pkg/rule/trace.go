@@ -0,0 +1,87 @@
+package rule
+
+import (
+	"fmt"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+)
+
+// newTrace returns the trace of a condition that wasn't evaluated, with the
+// traces of its children.
+func newTrace(c Condition) *common.Trace {
+	t := &common.Trace{
+		Expression: FormatCondition(c),
+		Operator:   c.GetOperator().Symbol(),
+	}
+	if c.GetOperator().IsLogical() {
+		for _, child := range c.GetChildrens() {
+			t.Children = append(t.Children, newTrace(child))
+		}
+		return t
+	}
+	t.Field = c.GetTargetField().String()
+	t.Target = c.GetValue()
+	return t
+}
+
+// traceCondition starts the trace of the evaluation of c on the event, the
+// returned function ends it with the outcome. Events that aren't observed
+// aren't traced.
+func traceCondition(evt common.Event, c Condition) func(bool, error) {
+	o, ok := evt.(*observedEvent)
+	if !ok {
+		return func(bool, error) {}
+	}
+	t := &common.Trace{
+		Expression: FormatCondition(c),
+		Operator:   c.GetOperator().Symbol(),
+		Evaluated:  true,
+	}
+	if !c.GetOperator().IsLogical() {
+		t.Field = c.GetTargetField().String()
+		t.Target = c.GetValue()
+	}
+	if n := len(o.traces); n > 0 {
+		parent := o.traces[n-1]
+		parent.Children = append(parent.Children, t)
+	} else {
+		o.trace = t
+	}
+	o.traces = append(o.traces, t)
+
+	return func(res bool, err error) {
+		o.traces = o.traces[:len(o.traces)-1]
+		t.Result = res
+		if err != nil {
+			t.Error = err.Error()
+		}
+		if obs := o.observation(c); obs != nil {
+			t.Value = obs.value
+			t.Baseline = obs.baseline
+		}
+		// the children after a short circuit aren't evaluated
+		children := c.GetChildrens()
+		for idx := len(t.Children); idx < len(children) && c.GetOperator().IsLogical(); idx++ {
+			t.Children = append(t.Children, newTrace(children[idx]))
+		}
+	}
+}
+
+// ruleTrace traces a rule that isn't made of conditions as a single
+// comparison of the observed value.
+func ruleTrace(r Rule, o *observedEvent, res bool, err error) *common.Trace {
+	t := &common.Trace{
+		Expression: fmt.Sprint(r.Value()),
+		Result:     res,
+		Evaluated:  true,
+	}
+	if err != nil {
+		t.Error = err.Error()
+	}
+	if obs := o.reported(); obs != nil {
+		t.Field = obs.column.String()
+		t.Value = obs.value
+		t.Baseline = obs.baseline
+	}
+	return t
+}
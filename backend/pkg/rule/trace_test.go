@@ -0,0 +1,147 @@
+package rule
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+func TestEvaluateTrace(t *testing.T) {
+	r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: "daily_spend > 100 or daily_spend < 10", Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Evaluate(r, entityTask())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected a result for each account, got %d", len(results))
+	}
+
+	// a1 spent 150, the second comparison is short circuited
+	root := results[0].Trace
+	if !results[0].Result || root == nil || !root.Result || root.Operator != "or" || len(root.Children) != 2 {
+		t.Fatalf("unexpected trace %+v", root)
+	}
+	first, second := root.Children[0], root.Children[1]
+	if !first.Evaluated || !first.Result || first.Field != "DAILY_SPEND" || first.Value != float64(150) || first.Target != float64(100) || first.Operator != ">" {
+		t.Fatalf("unexpected trace %+v", first)
+	}
+	if second.Evaluated || second.Result || second.Expression != "daily_spend < 10" || second.Value != nil {
+		t.Fatalf("unexpected trace %+v", second)
+	}
+
+	// a2 spent 50, both comparisons fail
+	root = results[1].Trace
+	if results[1].Result || root.Result || !root.Children[1].Evaluated || root.Children[1].Value != float64(50) {
+		t.Fatalf("unexpected trace %+v", root)
+	}
+
+	// the matched results keep their trace
+	matched, err := Execute(r, entityTask())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched) != 1 || matched[0].Trace == nil || matched[0].Trace.Expression != "daily_spend > 100 or daily_spend < 10" {
+		t.Fatalf("unexpected results %+v", matched)
+	}
+}
+
+func TestEvaluateTraceNested(t *testing.T) {
+	r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: `not (daily_spend > 100 and campaign_name ~ "^PROMO_")`, Scope: "campaign"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Evaluate(r, entityTask())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// k1 spent 120 on a promo
+	root := results[0].Trace
+	if results[0].Result || root.Operator != "not" || len(root.Children) != 1 {
+		t.Fatalf("unexpected trace %+v", root)
+	}
+	and := root.Children[0]
+	if !and.Result || len(and.Children) != 2 || and.Children[1].Value != "PROMO_summer" || and.Children[1].Target != "^PROMO_" {
+		t.Fatalf("unexpected trace %+v", and)
+	}
+
+	// the trace is stored with the alert as JSON
+	data, err := json.Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded common.Trace
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Children[0].Children[0].Expression != "daily_spend > 100" {
+		t.Fatalf("unexpected decoded trace %+v", decoded)
+	}
+}
+
+func TestEvaluateTraceRules(t *testing.T) {
+	// rules without conditions are traced as a single comparison
+	r, err := NewPacingRule(PacingParams{Budget: 500}, "pacing", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.(scopeSetter).setScope(common.ACCOUNT)
+	task, _ := historyTask()
+	results, err := Evaluate(r, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := results[0].Trace
+	if !results[0].Result || trace == nil || trace.Field != "DAILY_SPEND" || trace.Value != float64(800) || trace.Baseline != float64(500) {
+		t.Fatalf("unexpected trace %+v", trace)
+	}
+
+	// the evaluation errors are reported in the traces
+	r, _ = FromDbRule(db.DbRule{RuleID: "1", Expression: `daily_spend > 1 and campaign_name ~ "x"`, Scope: "account"})
+	results, err = Evaluate(r, entityTask())
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace = results[0].Trace
+	if results[0].Result || trace.Error == "" || trace.Children[1].Error == "" || trace.Children[0].Error != "" {
+		t.Fatalf("unexpected trace %+v", trace)
+	}
+}
+
+func TestEvaluateTraceInfinite(t *testing.T) {
+	// a spend without conversions is an infinite cost per acquisition
+	r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: "cpa > 50", Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := taskWithSpend(100)
+	task.Accounts = append(task.Accounts, db.DbAccountSpend{AccountID: "a2"})
+	results, err := Evaluate(r, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !results[0].Result || results[0].Value != "+Inf" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	// without spend there is no cost
+	if results[1].Result || results[1].Value != float64(0) {
+		t.Fatalf("unexpected result %+v", results[1])
+	}
+
+	// the trace is stored with the alert as JSON
+	data, err := json.Marshal(results[0].Trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded common.Trace
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Value != "+Inf" {
+		t.Fatalf("unexpected decoded trace %+v", decoded)
+	}
+}
@@ -0,0 +1,32 @@
+package rule
+
+import (
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+)
+
+// treeRule executes an arbitrary Condition tree, so that a single rule can
+// combine several comparisons with and / or / not.
+type treeRule struct {
+	ruleInfo
+	condition Condition
+}
+
+// Value implements Rule.
+func (t *treeRule) Value() interface{} {
+	return FormatCondition(t.condition)
+}
+
+// Exec implements Rule.
+func (t *treeRule) Exec(taskResult common.Event) (bool, error) {
+	return t.condition.Exec(taskResult)
+}
+
+func NewTreeRule(condition Condition, name, id string) Rule {
+	if condition == nil {
+		return nil
+	}
+	return &treeRule{
+		ruleInfo:  newRuleInfo(name, id),
+		condition: condition,
+	}
+}
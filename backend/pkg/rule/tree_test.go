@@ -0,0 +1,87 @@
+package rule
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+func TestTreeRule(t *testing.T) {
+	conditions := `{
+		"operator": "and",
+		"children": [
+			{"operator": "gt", "column": "daily_spend", "value": 10},
+			{"operator": "or", "children": [
+				{"operator": "lt", "column": "daily_spend", "value": 15},
+				{"operator": "not", "children": [
+					{"operator": "lte", "column": "daily_spend", "value": 100}
+				]}
+			]}
+		]
+	}`
+	r, err := FromDbRule(db.DbRule{RuleID: "1", RuleName: "tree", Conditions: conditions})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		spend float64
+		match bool
+	}{
+		{spend: 5, match: false},
+		{spend: 12, match: true},
+		{spend: 50, match: false},
+		{spend: 150, match: true},
+	}
+	for _, c := range cases {
+		task := common.NewFetchTask(time.Now(), time.Now())
+		task.Accounts = append(task.Accounts, db.DbAccountSpend{
+			Spend: c.spend,
+		})
+		match, err := r.Exec(task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match != c.match {
+			t.Fatalf("spend %v: expected match=%v, got %v", c.spend, c.match, match)
+		}
+	}
+}
+
+func TestConditionSpecRoundTrip(t *testing.T) {
+	spec := ConditionSpec{
+		Operator: "OpOR",
+		Children: []ConditionSpec{
+			{Operator: "OpGT", Column: "DAILY_SPEND", Value: float64(500)},
+			{Operator: "OpLT", Column: "DAILY_SPEND", Value: float64(1)},
+		},
+	}
+	cond, err := BuildCondition(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(spec)
+	got, _ := json.Marshal(SpecFromCondition(cond))
+	if string(want) != string(got) {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestInvalidConditions(t *testing.T) {
+	invalid := []string{
+		`{"operator": "and"}`,
+		`{"operator": "not", "children": [{"operator": "gt", "column": "daily_spend", "value": 1}, {"operator": "gt", "column": "daily_spend", "value": 2}]}`,
+		`{"operator": "gt", "column": "unknown", "value": 1}`,
+		`{"operator": "gt", "column": "daily_spend"}`,
+		`{"operator": "bogus"}`,
+		`not json`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseConditions(data); err == nil {
+			t.Fatalf("expected an error for %s", data)
+		}
+	}
+}
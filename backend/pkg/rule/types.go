@@ -4,31 +4,33 @@ import (
 	"strings"
 
 	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/metric"
 )
 
-type Column uint
+// Column is the name of a metric registered in the metric package.
+type Column string
 
 const (
-	INVALID Column = iota
-	DAILY_SPEND
+	INVALID     Column = ""
+	DAILY_SPEND Column = "DAILY_SPEND"
 )
 
 func (c Column) String() string {
-	switch c {
-	case DAILY_SPEND:
-		return "DAILY_SPEND"
-	}
-	return ""
+	return string(c)
+}
+
+// Metric returns the registry entry of the column.
+func (c Column) Metric() (*metric.Metric, bool) {
+	return metric.Lookup(string(c))
 }
 
+// ColumnFromString resolves a (case insensitive) metric name.
 func ColumnFromString(s string) Column {
-	s = strings.ToLower(s)
-	switch s {
-	case "daily_spend":
-		return DAILY_SPEND
-	default:
+	m, ok := metric.Lookup(s)
+	if !ok {
 		return INVALID
 	}
+	return Column(m.Name)
 }
 
 type Operator uint
@@ -45,6 +47,21 @@ const (
 	OpLTE
 	OpGT
 	OpGTE
+	OpREGEX
+	// period over period operators, comparing the change of the column
+	// against its baseline
+	OpPctChangeGT
+	OpPctChangeLT
+	OpDeltaGT
+	OpDeltaLT
+	// set and range operators, the value is a list
+	OpIN
+	OpNotIN
+	OpBETWEEN
+	// string matching operators
+	OpCONTAINS
+	OpPREFIX
+	OpGLOB
 )
 
 func (o Operator) String() string {
@@ -69,35 +86,124 @@ func (o Operator) String() string {
 		return "OpGT"
 	case OpGTE:
 		return "OpGTE"
+	case OpREGEX:
+		return "OpREGEX"
+	case OpPctChangeGT:
+		return "OpPctChangeGT"
+	case OpPctChangeLT:
+		return "OpPctChangeLT"
+	case OpDeltaGT:
+		return "OpDeltaGT"
+	case OpDeltaLT:
+		return "OpDeltaLT"
+	case OpIN:
+		return "OpIN"
+	case OpNotIN:
+		return "OpNotIN"
+	case OpBETWEEN:
+		return "OpBETWEEN"
+	case OpCONTAINS:
+		return "OpCONTAINS"
+	case OpPREFIX:
+		return "OpPREFIX"
+	case OpGLOB:
+		return "OpGLOB"
 	}
 	return "OpINVALID"
 }
 
+// Symbol returns the operator as written in rule expressions.
+func (o Operator) Symbol() string {
+	switch o {
+	case OpAND:
+		return "and"
+	case OpOR:
+		return "or"
+	case OpNOT:
+		return "not"
+	case OpEQ:
+		return "=="
+	case OpNotEQ:
+		return "!="
+	case OpLT, OpPctChangeLT, OpDeltaLT:
+		return "<"
+	case OpLTE:
+		return "<="
+	case OpGT, OpPctChangeGT, OpDeltaGT:
+		return ">"
+	case OpGTE:
+		return ">="
+	case OpREGEX:
+		return "~"
+	case OpIN:
+		return "in"
+	case OpNotIN:
+		return "not in"
+	case OpBETWEEN:
+		return "between"
+	case OpCONTAINS:
+		return "contains"
+	case OpPREFIX:
+		return "prefix"
+	case OpGLOB:
+		return "glob"
+	}
+	return "?"
+}
+
+// IsLogical reports whether the operator combines child conditions
+// instead of comparing a field against a value.
+func (o Operator) IsLogical() bool {
+	return o == OpAND || o == OpOR || o == OpNOT
+}
+
+// IsList reports whether the operator compares the column with a list of
+// values.
+func (o Operator) IsList() bool {
+	return o == OpIN || o == OpNotIN || o == OpBETWEEN
+}
+
+// IsChange reports whether the operator compares the change of the column
+// against a baseline period.
+func (o Operator) IsChange() bool {
+	return o == OpPctChangeGT || o == OpPctChangeLT || o == OpDeltaGT || o == OpDeltaLT
+}
+
 func (o Operator) GetFunc(valueType any) ConditionFunction {
+	// logical, change, set and string matching operators don't depend on
+	// the value type
+	switch o {
+	case OpAND:
+		return FuncOpAND
+	case OpOR:
+		return FuncOpOR
+	case OpNOT:
+		return FuncOpNOT
+	case OpPctChangeGT:
+		return FuncOpChange(true, true)
+	case OpPctChangeLT:
+		return FuncOpChange(true, false)
+	case OpDeltaGT:
+		return FuncOpChange(false, true)
+	case OpDeltaLT:
+		return FuncOpChange(false, false)
+	case OpIN:
+		return FuncOpIN(false)
+	case OpNotIN:
+		return FuncOpIN(true)
+	case OpBETWEEN:
+		return FuncOpBETWEEN
+	case OpCONTAINS:
+		return FuncOpCONTAINS
+	case OpPREFIX:
+		return FuncOpPREFIX
+	case OpGLOB:
+		return FuncOpGLOB
+	}
 
+	// numeric values are compared as float64, like the numeric metrics
 	switch valueType.(type) {
-	case int, int32, int64:
-		switch o {
-		case OpAND:
-			return FuncOpAND
-		case OpOR:
-			return FuncOpOR
-		case OpNOT:
-			return FuncOpNOT
-		case OpEQ:
-			return FuncOpEQ[int64]
-		// case OpNotEQ:
-		// 	return "OpNotEQ"
-		case OpLT:
-			return FuncOpLT[int64]
-		case OpLTE:
-			return FuncOpLTE[int64]
-		case OpGT:
-			return FuncOpGT[int64]
-		case OpGTE:
-			return FuncOpGTE[int64]
-		}
-	case float32, float64:
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
 		switch o {
 		case OpAND:
 			return FuncOpAND
@@ -107,8 +213,8 @@ func (o Operator) GetFunc(valueType any) ConditionFunction {
 			return FuncOpNOT
 		case OpEQ:
 			return FuncOpEQ[float64]
-		// case OpNotEQ:
-		// 	return "OpNotEQ"
+		case OpNotEQ:
+			return FuncOpNotEQ[float64]
 		case OpLT:
 			return FuncOpLT[float64]
 		case OpLTE:
@@ -128,8 +234,8 @@ func (o Operator) GetFunc(valueType any) ConditionFunction {
 			return FuncOpNOT
 		case OpEQ:
 			return FuncOpEQ[string]
-		// case OpNotEQ:
-		// 	return "OpNotEQ"
+		case OpNotEQ:
+			return FuncOpNotEQ[string]
 		case OpLT:
 			return FuncOpLT[string]
 		case OpLTE:
@@ -138,36 +244,62 @@ func (o Operator) GetFunc(valueType any) ConditionFunction {
 			return FuncOpGT[string]
 		case OpGTE:
 			return FuncOpGTE[string]
+		case OpREGEX:
+			return FuncOpREGEX
 		}
 	}
 
 	return nil
 }
 
-// Function to match a string to an operator (case insensitive)
+// Function to match a string to an operator (case insensitive).
+// Both the "OpGT" and the short "gt" forms are accepted, as well as
+// "pct_change_gt" for "OpPctChangeGT".
 func OperatorFromString(s string) Operator {
-	s = strings.ToLower(s)
+	s = strings.ReplaceAll(strings.TrimPrefix(strings.ToLower(s), "op"), "_", "")
 	switch s {
-	case "oproot":
+	case "root":
 		return OpROOT
-	case "opand":
+	case "and":
 		return OpAND
-	case "opor":
+	case "or":
 		return OpOR
-	case "opnot":
+	case "not":
 		return OpNOT
-	case "opeq":
+	case "eq":
 		return OpEQ
-	case "opnoteq":
+	case "noteq":
 		return OpNotEQ
-	case "oplt":
+	case "lt":
 		return OpLT
-	case "oplte":
+	case "lte":
 		return OpLTE
-	case "opgt":
+	case "gt":
 		return OpGT
-	case "opgte":
+	case "gte":
 		return OpGTE
+	case "regex":
+		return OpREGEX
+	case "pctchangegt":
+		return OpPctChangeGT
+	case "pctchangelt":
+		return OpPctChangeLT
+	case "deltagt":
+		return OpDeltaGT
+	case "deltalt":
+		return OpDeltaLT
+	case "in":
+		return OpIN
+	case "notin":
+		return OpNotIN
+	case "between":
+		return OpBETWEEN
+	case "contains":
+		return OpCONTAINS
+	case "prefix":
+		return OpPREFIX
+	case "glob":
+		return OpGLOB
 	default:
 		return OpINVALID
 	}
@@ -178,4 +310,6 @@ type Rule interface {
 	Name() string
 	Id() string
 	Value() interface{}
+	// Scope is the kind of entity the rule is evaluated on.
+	Scope() common.EntityType
 }
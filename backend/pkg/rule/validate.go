@@ -0,0 +1,183 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/metric"
+)
+
+// normalizeValue converts the numeric values to float64, the type of every
+// numeric metric, and the lists to []any. The other values are returned
+// unchanged.
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	case string:
+		return v
+	}
+	// lists, as the values of the in and between operators
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		list := make([]any, rv.Len())
+		for idx := range list {
+			list[idx] = normalizeValue(rv.Index(idx).Interface())
+		}
+		return list
+	}
+	return value
+}
+
+// coerceValue checks the value compared by the operator with the column and
+// returns it in the type of the column, numeric columns accept any number.
+// The list operators take a list of values of the type of the column.
+func coerceValue(column Column, op Operator, value any, aggregated bool) (any, error) {
+	m, ok := column.Metric()
+	if !ok {
+		return nil, fmt.Errorf("invalid column %q", column)
+	}
+	if value == nil {
+		return nil, fmt.Errorf("missing value for column %s", column)
+	}
+	value = normalizeValue(value)
+	numeric := m.Type == metric.TypeNumber || aggregated || op.IsChange()
+
+	if op.IsList() {
+		list, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("operator %s requires a list of values", op)
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("operator %s requires at least one value", op)
+		}
+		for idx, item := range list {
+			coerced, err := coerceScalar(column, numeric, item)
+			if err != nil {
+				return nil, err
+			}
+			list[idx] = coerced
+		}
+		if op == OpBETWEEN {
+			if !numeric {
+				return nil, fmt.Errorf("operator %s cannot be applied to the string column %s", op, column)
+			}
+			if len(list) != 2 {
+				return nil, fmt.Errorf("operator %s requires a lower and an upper bound", op)
+			}
+			if list[0].(float64) > list[1].(float64) {
+				return nil, fmt.Errorf("the lower bound %v is greater than the upper bound %v", list[0], list[1])
+			}
+		}
+		return list, nil
+	}
+
+	if numeric {
+		switch op {
+		case OpEQ, OpNotEQ, OpLT, OpLTE, OpGT, OpGTE, OpPctChangeGT, OpPctChangeLT, OpDeltaGT, OpDeltaLT:
+		default:
+			return nil, fmt.Errorf("operator %s cannot be applied to the numeric column %s", op, column)
+		}
+		return coerceScalar(column, numeric, value)
+	}
+
+	switch op {
+	case OpEQ, OpNotEQ, OpCONTAINS, OpPREFIX:
+	case OpREGEX:
+		if s, ok := value.(string); ok {
+			if _, err := regexp.Compile(s); err != nil {
+				return nil, fmt.Errorf("invalid regular expression: %w", err)
+			}
+		}
+	case OpGLOB:
+		if s, ok := value.(string); ok {
+			if _, err := globRegexp(s); err != nil {
+				return nil, fmt.Errorf("invalid glob pattern: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("operator %s cannot be applied to the string column %s", op, column)
+	}
+	return coerceScalar(column, numeric, value)
+}
+
+// coerceScalar checks a single value against the type of the column.
+func coerceScalar(column Column, numeric bool, value any) (any, error) {
+	if !numeric {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("column %s is a string, got a %T value", column, value)
+		}
+		return s, nil
+	}
+	f, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("column %s is numeric, got a %T value", column, value)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("invalid value %v for column %s", f, column)
+	}
+	return f, nil
+}
+
+// compileLeaf validates the comparison of the leaf and normalizes its value,
+// so that it can't fail on a type mismatch when evaluated.
+func compileLeaf(c Condition) error {
+	op := c.GetOperator()
+	value, err := coerceValue(c.GetTargetField(), op, c.GetValue(), c.GetWindow() != nil)
+	if err != nil {
+		return err
+	}
+	c.SetValue(value)
+	if leaf, ok := c.(*ConditionLeaf); ok && leaf.function == nil {
+		return fmt.Errorf("operator %s is not supported", op)
+	}
+	return nil
+}
+
+// Validate checks every comparison of the condition tree against the type
+// of its column, and normalizes the compared values.
+func Validate(c Condition) error {
+	if c == nil {
+		return fmt.Errorf("empty condition")
+	}
+	op := c.GetOperator()
+	if !op.IsLogical() {
+		return compileLeaf(c)
+	}
+	if len(c.GetChildrens()) == 0 {
+		return fmt.Errorf("operator %s requires at least one child", op)
+	}
+	for _, child := range c.GetChildrens() {
+		if err := Validate(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
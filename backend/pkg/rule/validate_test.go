@@ -0,0 +1,109 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+func TestValueCoercion(t *testing.T) {
+	// every numeric type is compared as a float64
+	for _, value := range []any{int(100), int64(100), uint64(100), float32(100)} {
+		s := NewSimpleRule(DAILY_SPEND, OpGT, value, "1", "1")
+		if s == nil {
+			t.Fatalf("%T: could not build the rule", value)
+		}
+		match, err := s.Exec(taskWithSpend(150))
+		if err != nil {
+			t.Fatalf("%T: %v", value, err)
+		}
+		if !match {
+			t.Fatalf("%T: expected a match", value)
+		}
+	}
+
+	r, err := FromDbRule(db.DbRule{RuleID: "1", Column: "daily_spend", Operator: "noteq", Value: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for spend, expected := range map[float64]bool{100: false, 150: true} {
+		match, err := r.Exec(taskWithSpend(spend))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match != expected {
+			t.Fatalf("%v != 100: expected %v", spend, expected)
+		}
+	}
+
+	cond, err := ParseConditions(`{"operator":"noteq","column":"campaign_name","value":"brand"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FormatCondition(cond) != `campaign_name != "brand"` {
+		t.Fatalf("unexpected format %s", FormatCondition(cond))
+	}
+}
+
+func TestValueValidation(t *testing.T) {
+	invalid := []string{
+		`{"operator":"gt","column":"daily_spend","value":"100"}`,
+		`{"operator":"eq","column":"campaign_name","value":5}`,
+		`{"operator":"gt","column":"campaign_name","value":"a"}`,
+		`{"operator":"regex","column":"daily_spend","value":"^1"}`,
+		`{"operator":"regex","column":"campaign_name","value":"[a-"}`,
+		`{"operator":"eq","column":"campaign_name","value":true}`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseConditions(data); err == nil {
+			t.Fatalf("%s: expected an error", data)
+		}
+	}
+
+	if _, err := FromDbRule(db.DbRule{RuleID: "1", Column: "campaign_name", Operator: "gt", Value: 1}); err == nil {
+		t.Fatal("expected an error for a numeric comparison of a string column")
+	}
+	if NewSimpleRule(Column("CAMPAIGN_NAME"), OpLT, 1, "1", "1") != nil {
+		t.Fatal("expected no rule for an invalid comparison")
+	}
+
+	errors := []struct {
+		expression string
+		pos        int
+	}{
+		{expression: "campaign_name > 5", pos: 16},
+		{expression: `campaign_name < "b"`, pos: 16},
+		{expression: `daily_spend == "5"`, pos: 15},
+	}
+	for _, c := range errors {
+		_, err := Compile(c.expression)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("%q: expected a syntax error, got %v", c.expression, err)
+		}
+		if syntaxErr.Pos != c.pos {
+			t.Fatalf("%q: expected error at %d, got %d (%v)", c.expression, c.pos, syntaxErr.Pos, err)
+		}
+	}
+}
+
+func TestComparisonTypeMismatch(t *testing.T) {
+	// a leaf built without validation fails instead of panicking
+	leaf, _ := NewConditionLeaf(OpGT)
+	leaf.SetTargetField(Column("CAMPAIGN_NAME"))
+	leaf.SetValue(10)
+	r := NewTreeRule(leaf, "1", "1")
+	r.(scopeSetter).setScope(common.CAMPAIGN)
+	if _, err := Execute(r, entityTask()); err == nil {
+		t.Fatal("expected an error for a string field compared with a number")
+	}
+	if err := Validate(leaf); err == nil {
+		t.Fatal("expected a validation error")
+	}
+
+	node, _ := NewConditionNode(OpAND, FuncOpAND)
+	if err := Validate(node); err == nil {
+		t.Fatal("expected an error for an empty node")
+	}
+}
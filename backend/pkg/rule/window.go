@@ -0,0 +1,108 @@
+package rule
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/metric"
+)
+
+// MaxWindowDays is the longest trailing window a condition can look at.
+const MaxWindowDays = 90
+
+// WindowFunc is the aggregation applied to the daily values of a window.
+type WindowFunc string
+
+const (
+	WindowSum WindowFunc = "sum"
+	WindowAvg WindowFunc = "avg"
+	WindowMin WindowFunc = "min"
+	WindowMax WindowFunc = "max"
+)
+
+// WindowFuncFromString returns the (case insensitive) window function, or
+// false if s is not one.
+func WindowFuncFromString(s string) (WindowFunc, bool) {
+	switch fn := WindowFunc(strings.ToLower(s)); fn {
+	case WindowSum, WindowAvg, WindowMin, WindowMax:
+		return fn, true
+	}
+	return "", false
+}
+
+// Window makes a condition compare the aggregation of the daily values of
+// its column over the last Days days, the fetched day included, instead of
+// the value of the fetched period only.
+type Window struct {
+	Days int        `json:"days"`
+	Func WindowFunc `json:"func"`
+}
+
+// NewWindow validates and returns the window.
+func NewWindow(fn string, days int) (*Window, error) {
+	f, ok := WindowFuncFromString(fn)
+	if !ok {
+		return nil, fmt.Errorf("invalid window function %q", fn)
+	}
+	if days < 1 || days > MaxWindowDays {
+		return nil, fmt.Errorf("window must be between 1 and %d days", MaxWindowDays)
+	}
+	return &Window{Days: days, Func: f}, nil
+}
+
+// validateWindowColumn checks that the column can be aggregated over a window.
+func validateWindowColumn(column Column) error {
+	m, ok := column.Metric()
+	if !ok || m.Type != metric.TypeNumber {
+		return fmt.Errorf("column %s is not numeric and cannot be aggregated", column)
+	}
+	return nil
+}
+
+// Apply computes the window of the column on the event, the event must be
+// able to look at its history.
+func (w *Window) Apply(column Column, evt common.Event) (float64, error) {
+	h, ok := evt.(common.HistoricalEvent)
+	if !ok {
+		return 0, fmt.Errorf("historical data is not available")
+	}
+	series, err := h.GetFieldSeries(column.String(), w.Days)
+	if err != nil {
+		return 0, err
+	}
+	if len(series) == 0 {
+		return 0, nil
+	}
+	values := make([]float64, 0, len(series))
+	for _, p := range series {
+		values = append(values, p.Value)
+	}
+	res := values[0]
+	switch w.Func {
+	case WindowSum, WindowAvg:
+		res = 0
+		for _, v := range values {
+			res += v
+		}
+		if w.Func == WindowAvg {
+			res /= float64(len(values))
+		}
+	case WindowMin:
+		for _, v := range values {
+			res = math.Min(res, v)
+		}
+	case WindowMax:
+		for _, v := range values {
+			res = math.Max(res, v)
+		}
+	default:
+		return 0, fmt.Errorf("invalid window function %q", w.Func)
+	}
+	return res, nil
+}
+
+func (w *Window) format(column Column) string {
+	return fmt.Sprintf("%s(%s, %dd)", w.Func, strings.ToLower(column.String()), w.Days)
+}
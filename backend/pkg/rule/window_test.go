@@ -0,0 +1,253 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+)
+
+type testHistory struct {
+	accounts          []db.DbAccountSpend
+	campaigns         []db.DbCampaignSpend
+	accountSnapshots  []db.DbAccountSpend
+	campaignSnapshots []db.DbCampaignSpend
+	queries           int
+	snapshotQueries   int
+}
+
+func (h *testHistory) GetAccountSpend(clientID string, start, end time.Time) ([]db.DbAccountSpend, error) {
+	h.queries++
+	res := make([]db.DbAccountSpend, 0)
+	for _, r := range h.accounts {
+		if !r.DateRef.Before(start) && !r.DateRef.After(end) {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+func (h *testHistory) GetCampaignSpend(clientID string, start, end time.Time) ([]db.DbCampaignSpend, error) {
+	res := make([]db.DbCampaignSpend, 0)
+	for _, r := range h.campaigns {
+		if !r.DateRef.Before(start) && !r.DateRef.After(end) {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+func (h *testHistory) GetAccountSnapshots(clientID string, at time.Time) ([]db.DbAccountSpend, error) {
+	latest := make(map[string]db.DbAccountSpend)
+	for _, r := range h.accountSnapshots {
+		if !sameDay(r.DateRef, at) || r.UpdatedAt.After(at) {
+			continue
+		}
+		if prev, ok := latest[r.AccountID]; !ok || r.UpdatedAt.After(prev.UpdatedAt) {
+			latest[r.AccountID] = r
+		}
+	}
+	res := make([]db.DbAccountSpend, 0)
+	for _, r := range latest {
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// GetAccountSnapshotsOfDay counts the queries of the snapshots of the days.
+func (h *testHistory) GetAccountSnapshotsOfDay(clientID string, day time.Time) ([]db.DbAccountSpend, error) {
+	h.snapshotQueries++
+	res := make([]db.DbAccountSpend, 0)
+	for _, r := range h.accountSnapshots {
+		if sameDay(r.DateRef, day) {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+func (h *testHistory) GetCampaignSnapshotsOfDay(clientID string, day time.Time) ([]db.DbCampaignSpend, error) {
+	res := make([]db.DbCampaignSpend, 0)
+	for _, r := range h.campaignSnapshots {
+		if sameDay(r.DateRef, day) {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+func (h *testHistory) GetCampaignSnapshots(clientID string, at time.Time) ([]db.DbCampaignSpend, error) {
+	latest := make(map[string]db.DbCampaignSpend)
+	for _, r := range h.campaignSnapshots {
+		if !sameDay(r.DateRef, at) || r.UpdatedAt.After(at) {
+			continue
+		}
+		if prev, ok := latest[r.CampaignID]; !ok || r.UpdatedAt.After(prev.UpdatedAt) {
+			latest[r.CampaignID] = r
+		}
+	}
+	res := make([]db.DbCampaignSpend, 0)
+	for _, r := range latest {
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// historyTask returns a task fetched today, with 10 days of history where
+// account a1 spent 100 a day with 50 clicks and a2 spent 10 a day.
+func historyTask() (*common.FetchTask, *testHistory) {
+	today := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	h := &testHistory{}
+	for i := 1; i <= 10; i++ {
+		day := today.AddDate(0, 0, -i)
+		h.accounts = append(h.accounts,
+			db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: 100, Clicks: 50, DateRef: day},
+			db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a2", Spend: 10, Clicks: 10, DateRef: day},
+		)
+	}
+	// an older version of a stored day, not merged yet
+	h.accounts = append(h.accounts, db.DbAccountSpend{
+		ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: 1000,
+		DateRef: today.AddDate(0, 0, -1), UpdatedAt: today.AddDate(0, 0, -2),
+	})
+	h.accounts[0].UpdatedAt = today.AddDate(0, 0, -1)
+
+	task := common.NewFetchTask(today, today.Add(12*time.Hour))
+	task.Accounts = append(task.Accounts,
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a1", Spend: 400, Clicks: 50, DateRef: today},
+		db.DbAccountSpend{ClientID: "c1", ProviderID: "p1", AccountID: "a2", Spend: 10, Clicks: 10, DateRef: today},
+	)
+	return task.WithHistory("c1", h), h
+}
+
+func TestWindowRules(t *testing.T) {
+	cases := []struct {
+		expression string
+		entities   []string
+	}{
+		// 6 stored days and the fetched one
+		{expression: "sum(daily_spend, 7d) == 1000", entities: []string{"a1"}},
+		{expression: "sum(daily_spend, 7d) == 70", entities: []string{"a2"}},
+		{expression: "sum(daily_spend, 1d) == 400", entities: []string{"a1"}},
+		// the average of the daily cpc, not the cpc of the window
+		{expression: "avg(avg_cpc, 2d) == 5", entities: []string{"a1"}},
+		{expression: "max(daily_spend, 30d) >= 400 and min(daily_spend, 30d) == 100", entities: []string{"a1"}},
+		{expression: "avg(daily_spend, 14d) < 10", entities: []string{}},
+	}
+	for _, c := range cases {
+		r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: c.expression, Scope: "account"})
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		task, _ := historyTask()
+		results, err := Execute(r, task)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		if len(results) != len(c.entities) {
+			t.Fatalf("%s: expected %d results, got %d", c.expression, len(c.entities), len(results))
+		}
+		for idx, res := range results {
+			if res.EntityID != c.entities[idx] {
+				t.Fatalf("%s: expected entity %s, got %s", c.expression, c.entities[idx], res.EntityID)
+			}
+		}
+	}
+}
+
+func TestWindowRulesPerProvider(t *testing.T) {
+	// the account a1 of a second provider spends 1 a day
+	build := func() *common.FetchTask {
+		task, h := historyTask()
+		today := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+		for i := 1; i <= 10; i++ {
+			h.accounts = append(h.accounts, db.DbAccountSpend{ClientID: "c1", ProviderID: "p2", AccountID: "a1", Spend: 1, DateRef: today.AddDate(0, 0, -i)})
+		}
+		task.Accounts = append(task.Accounts, db.DbAccountSpend{ClientID: "c1", ProviderID: "p2", AccountID: "a1", Spend: 1, DateRef: today})
+		return task
+	}
+	for _, expression := range []string{"sum(daily_spend, 7d) == 1000", "sum(daily_spend, 7d) == 7"} {
+		r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: expression, Scope: "account"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := Execute(r, build())
+		if err != nil {
+			t.Fatalf("%s: %v", expression, err)
+		}
+		if len(results) != 1 || results[0].EntityID != "a1" {
+			t.Fatalf("%s: expected the account of a single provider, got %+v", expression, results)
+		}
+	}
+}
+
+func TestWindowHistoryCache(t *testing.T) {
+	task, h := historyTask()
+	for _, expression := range []string{"sum(daily_spend, 7d) > 1", "sum(daily_spend, 3d) > 1", "sum(daily_spend, 10d) > 1"} {
+		r, err := FromDbRule(db.DbRule{RuleID: "1", Expression: expression, Scope: "account"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Execute(r, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the 3 days window is served by the 7 days one
+	if h.queries != 2 {
+		t.Fatalf("expected 2 history queries, got %d", h.queries)
+	}
+
+	// without history only the fetched days can be looked at
+	r, _ := FromDbRule(db.DbRule{RuleID: "1", Expression: "sum(daily_spend, 7d) > 1"})
+	if _, err := Execute(r, taskWithSpend(10)); err == nil {
+		t.Fatal("expected an error without history")
+	}
+}
+
+func TestWindowSyntax(t *testing.T) {
+	cond, err := Compile("SUM(Daily_Spend, 7D) > 5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted := FormatCondition(cond); formatted != "sum(daily_spend, 7d) > 5000" {
+		t.Fatalf("unexpected format %s", formatted)
+	}
+	spec := SpecFromCondition(cond)
+	if spec.Window == nil || spec.Window.Days != 7 || spec.Window.Func != WindowSum {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+	if _, err := BuildCondition(spec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseConditions(`{"operator":"gt","column":"daily_spend","value":1,"window":{"days":0,"func":"sum"}}`); err == nil {
+		t.Fatal("expected an error for an empty window")
+	}
+
+	errors := []struct {
+		expression string
+		pos        int
+	}{
+		{expression: "sum(campaign_name, 7d) > 1", pos: 4},
+		{expression: "sum(daily_spend 7d) > 1", pos: 16},
+		{expression: "sum(daily_spend, 7) > 1", pos: 17},
+		{expression: "sum(daily_spend, 365d) > 1", pos: 17},
+		{expression: "sum(daily_spend, 7d > 1", pos: 20},
+		{expression: `sum(daily_spend, 7d) ~ "1"`, pos: 21},
+		{expression: "median(daily_spend, 7d) > 1", pos: 0},
+	}
+	for _, c := range errors {
+		_, err := Compile(c.expression)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("%q: expected a syntax error, got %v", c.expression, err)
+		}
+		if syntaxErr.Pos != c.pos {
+			t.Fatalf("%q: expected error at %d, got %d (%v)", c.expression, c.pos, syntaxErr.Pos, err)
+		}
+	}
+}
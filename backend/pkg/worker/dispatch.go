@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0und0fs1lence/ads-zero/pkg/alert"
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/fetcher"
+	"github.com/s0und0fs1lence/ads-zero/pkg/notifier"
+)
+
+// outbox collects the notifications of a fetch by destination, in the order
+// the destinations are first seen.
+type outbox struct {
+	dests         []notifier.Destination
+	notifications map[notifier.Destination][]*notifier.Notification
+}
+
+func newOutbox() *outbox {
+	return &outbox{notifications: make(map[notifier.Destination][]*notifier.Notification)}
+}
+
+func (o *outbox) add(dest notifier.Destination, n *notifier.Notification) {
+	if _, ok := o.notifications[dest]; !ok {
+		o.dests = append(o.dests, dest)
+	}
+	o.notifications[dest] = append(o.notifications[dest], n)
+}
+
+// send delivers the notifications, the most severe first, and returns the
+// ones that were sent. When grouped, the notifications of a destination are
+// combined in one.
+func (o *outbox) send(broker notifier.MessageBroker, group bool) []*notifier.Notification {
+	sent := make([]*notifier.Notification, 0)
+	for _, dest := range o.dests {
+		notifications := o.notifications[dest]
+		sort.SliceStable(notifications, func(i, j int) bool {
+			return severityOf(notifications[i]) > severityOf(notifications[j])
+		})
+		if group && len(notifications) > 1 {
+			notifications = []*notifier.Notification{notifier.NewGroup(notifications, dest)}
+		}
+		for _, n := range notifications {
+			log.Info().Str("notification_channel", dest.Type).Str("value", dest.Dest).Int("grouped", len(n.Digest)).Msg("sending notification")
+			if err := broker.SendNotification(n); err != nil {
+				log.Error().Err(err).Str("rule_id", n.RuleID).Msg("could not send the notification")
+				continue
+			}
+			if len(n.Digest) > 0 {
+				sent = append(sent, n.Digest...)
+			} else {
+				sent = append(sent, n)
+			}
+		}
+	}
+	return sent
+}
+
+func severityOf(n *notifier.Notification) common.Severity {
+	severity, _ := common.SeverityFromString(n.Severity)
+	return severity
+}
+
+// dispatchNotifications sends the notifications of the alert transitions to
+// every destination of their rule, the most severe first. They are queued
+// during the quiet hours of the client, and until the next day when routed
+// to the digest; the queues are delivered by the first fetch after.
+func dispatchNotifications(client fetcher.Client, broker notifier.MessageBroker, events []alert.Event, at time.Time) error {
+	quiet := client.QuietHours(at)
+	out := newOutbox()
+	for _, event := range events {
+		if event.Action == alert.Fire {
+			if _, err := client.SaveAlert(event.Result, at); err != nil {
+				log.Error().Err(err).Str("rule_id", event.Result.RuleID).Msg("could not save the alert")
+			}
+		}
+		for _, dest := range client.GetNotificationDestinations(event.Result) {
+			queue := ""
+			if dest.Type == alert.ChannelDigest {
+				dest.Type = "mail"
+				queue = db.QueueDigest
+			} else if quiet {
+				queue = db.QueueQuietHours
+			}
+			n := event.Notification(dest.Type, dest.Dest)
+			if queue != "" {
+				if err := client.QueueNotification(queue, n, at); err != nil {
+					log.Error().Err(err).Str("rule_id", event.Result.RuleID).Msg("could not queue the notification")
+				}
+				continue
+			}
+			out.add(dest, n)
+		}
+	}
+	if quiet {
+		return nil
+	}
+
+	// the queued notifications are removed from their queue once sent
+	queued, err := client.DequeueNotifications(db.QueueQuietHours, at, at)
+	if err != nil {
+		return err
+	}
+	ids := make(map[*notifier.Notification]string, len(queued))
+	for _, q := range queued {
+		ids[q.Notification] = q.ID
+		out.add(notifier.Destination{Type: q.DestType, Dest: q.Dest}, q.Notification)
+	}
+	delivered := make([]string, 0, len(queued))
+	for _, n := range out.send(broker, client.GroupAlerts()) {
+		if id, ok := ids[n]; ok {
+			delivered = append(delivered, id)
+		}
+	}
+	if err := client.MarkDelivered(db.QueueQuietHours, delivered, at); err != nil {
+		return err
+	}
+
+	// the digest of the previous days, one per address
+	digest, err := client.DequeueNotifications(db.QueueDigest, client.DayStart(at), at)
+	if err != nil {
+		return err
+	}
+	digests := newOutbox()
+	ids = make(map[*notifier.Notification]string, len(digest))
+	for _, q := range digest {
+		ids[q.Notification] = q.ID
+		digests.add(notifier.Destination{Type: "mail", Dest: q.Dest}, q.Notification)
+	}
+	delivered = make([]string, 0, len(digest))
+	for _, dest := range digests.dests {
+		log.Info().Str("value", dest.Dest).Int("notifications", len(digests.notifications[dest])).Msg("sending digest")
+		if err := broker.SendNotification(notifier.NewDigest(digests.notifications[dest], dest)); err != nil {
+			log.Error().Err(err).Str("value", dest.Dest).Msg("could not send the digest")
+			continue
+		}
+		for _, n := range digests.notifications[dest] {
+			delivered = append(delivered, ids[n])
+		}
+	}
+	return client.MarkDelivered(db.QueueDigest, delivered, at)
+}
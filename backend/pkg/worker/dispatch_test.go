@@ -0,0 +1,261 @@
+package worker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/s0und0fs1lence/ads-zero/pkg/alert"
+	"github.com/s0und0fs1lence/ads-zero/pkg/common"
+	"github.com/s0und0fs1lence/ads-zero/pkg/db"
+	"github.com/s0und0fs1lence/ads-zero/pkg/fetcher"
+	"github.com/s0und0fs1lence/ads-zero/pkg/notifier"
+)
+
+type queuedNotification struct {
+	id string
+	n  *notifier.Notification
+	at time.Time
+}
+
+// dispatchClient is a client with a routing and queues in memory, the
+// methods the dispatch does not use are not implemented.
+type dispatchClient struct {
+	fetcher.Client
+	routing      alert.Routing
+	destinations map[string][]notifier.Destination
+	quiet        bool
+	group        bool
+	queues       map[string][]queuedNotification
+	alerts       int
+}
+
+func (c *dispatchClient) SaveAlert(result *common.RuleResult, firedAt time.Time) (*db.DbAlert, error) {
+	c.alerts++
+	return &db.DbAlert{}, nil
+}
+
+func (c *dispatchClient) GetNotificationDestinations(result *common.RuleResult) []notifier.Destination {
+	if dests, ok := c.destinations[result.RuleID]; ok {
+		return dests
+	}
+	res := make([]notifier.Destination, 0)
+	for _, channel := range c.routing.Channels(result.Severity) {
+		res = append(res, notifier.Destination{Type: channel, Dest: channel + "-dest"})
+	}
+	if len(res) == 0 {
+		res = append(res, notifier.Destination{Type: "mail", Dest: "user@example.com"})
+	}
+	return res
+}
+
+func (c *dispatchClient) GroupAlerts() bool {
+	return c.group
+}
+
+func (c *dispatchClient) QuietHours(at time.Time) bool {
+	return c.quiet
+}
+
+func (c *dispatchClient) QueueNotification(queue string, n *notifier.Notification, at time.Time) error {
+	id := fmt.Sprintf("%s-%d", queue, len(c.queues[queue]))
+	c.queues[queue] = append(c.queues[queue], queuedNotification{id: id, n: n, at: at})
+	return nil
+}
+
+func (c *dispatchClient) DequeueNotifications(queue string, before, at time.Time) ([]fetcher.QueuedNotification, error) {
+	res := make([]fetcher.QueuedNotification, 0)
+	for _, q := range c.queues[queue] {
+		if q.at.Before(before) {
+			res = append(res, fetcher.QueuedNotification{ID: q.id, Notification: q.n})
+		}
+	}
+	return res, nil
+}
+
+func (c *dispatchClient) MarkDelivered(queue string, ids []string, at time.Time) error {
+	delivered := make(map[string]bool)
+	for _, id := range ids {
+		delivered[id] = true
+	}
+	kept := make([]queuedNotification, 0)
+	for _, q := range c.queues[queue] {
+		if !delivered[q.id] {
+			kept = append(kept, q)
+		}
+	}
+	c.queues[queue] = kept
+	return nil
+}
+
+func (c *dispatchClient) DayStart(at time.Time) time.Time {
+	return at.Truncate(24 * time.Hour)
+}
+
+type recordBroker struct {
+	sent []*notifier.Notification
+	// failing are the destination types the notifications fail to be sent to
+	failing map[string]bool
+}
+
+func (b *recordBroker) SendNotification(n *notifier.Notification) error {
+	if b.failing[n.DestType] {
+		return fmt.Errorf("could not send to %s", n.DestType)
+	}
+	b.sent = append(b.sent, n)
+	return nil
+}
+
+func TestDispatchNotifications(t *testing.T) {
+	routing, err := alert.ParseRouting(`{"critical": ["telegram", "slack"], "info": ["digest"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &dispatchClient{routing: routing, queues: make(map[string][]queuedNotification)}
+	broker := &recordBroker{}
+	event := func(severity common.Severity, action alert.Action) alert.Event {
+		return alert.Event{Action: action, Result: &common.RuleResult{RuleID: severity.String(), Severity: severity}}
+	}
+	events := []alert.Event{
+		event(common.CRITICAL, alert.Fire),
+		event(common.WARNING, alert.Fire),
+		event(common.INFO, alert.Fire),
+		event(common.INFO, alert.Resolve),
+	}
+	at := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	if err := dispatchNotifications(client, broker, events, at); err != nil {
+		t.Fatal(err)
+	}
+	// critical to telegram and slack, warning to the default channel and
+	// info in the digest
+	if len(broker.sent) != 3 || broker.sent[0].DestType != "telegram" || broker.sent[1].DestType != "slack" || broker.sent[2].DestType != "mail" {
+		t.Fatalf("unexpected notifications %+v", broker.sent)
+	}
+	if broker.sent[0].Severity != "CRITICAL" || client.alerts != 3 {
+		t.Fatalf("unexpected notification %+v", broker.sent[0])
+	}
+	if len(client.queues[db.QueueDigest]) != 2 {
+		t.Fatalf("expected the info notifications in the digest, got %d", len(client.queues[db.QueueDigest]))
+	}
+
+	// during the quiet hours everything is queued
+	broker.sent = nil
+	client.quiet = true
+	if err := dispatchNotifications(client, broker, events[:1], at.Add(12*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.sent) != 0 || len(client.queues[db.QueueQuietHours]) != 2 {
+		t.Fatalf("expected the notifications to be queued, sent %d", len(broker.sent))
+	}
+
+	// the next day the queue and the digest are delivered
+	client.quiet = false
+	if err := dispatchNotifications(client, broker, nil, at.Add(20*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.sent) != 3 {
+		t.Fatalf("expected the queued notifications and the digest, got %d", len(broker.sent))
+	}
+	digest := broker.sent[2]
+	if len(digest.Digest) != 2 || digest.DestType != "mail" || digest.Dest != "digest-dest" {
+		t.Fatalf("unexpected digest %+v", digest)
+	}
+	if len(client.queues[db.QueueDigest]) != 0 || len(client.queues[db.QueueQuietHours]) != 0 {
+		t.Fatal("expected the queues to be empty")
+	}
+}
+
+func TestDispatchDestinations(t *testing.T) {
+	client := &dispatchClient{
+		destinations: map[string][]notifier.Destination{
+			"r1": {{Type: "mail", Dest: "a@example.com"}, {Type: "mail", Dest: "b@example.com"}, {Type: "webhook", Dest: "https://example.com/hook"}},
+		},
+		queues: make(map[string][]queuedNotification),
+	}
+	broker := &recordBroker{}
+	events := []alert.Event{{Action: alert.Renotify, Result: &common.RuleResult{RuleID: "r1"}}}
+	if err := dispatchNotifications(client, broker, events, time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	// every destination of the rule gets the notification
+	if len(broker.sent) != 3 || broker.sent[1].Dest != "b@example.com" || broker.sent[2].DestType != "webhook" || !broker.sent[2].Reminder {
+		t.Fatalf("unexpected notifications %+v", broker.sent)
+	}
+	if client.alerts != 0 {
+		t.Fatal("a reminder does not store a new alert")
+	}
+}
+
+func TestDispatchGrouped(t *testing.T) {
+	client := &dispatchClient{queues: make(map[string][]queuedNotification)}
+	broker := &recordBroker{}
+	events := []alert.Event{
+		{Action: alert.Fire, Result: &common.RuleResult{RuleID: "warning", Severity: common.WARNING}},
+		{Action: alert.Fire, Result: &common.RuleResult{RuleID: "info", Severity: common.INFO}},
+		{Action: alert.Fire, Result: &common.RuleResult{RuleID: "critical", Severity: common.CRITICAL}},
+	}
+	at := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+
+	// every matching rule is sent, the most severe first
+	if err := dispatchNotifications(client, broker, events, at); err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.sent) != 3 || broker.sent[0].RuleID != "critical" || broker.sent[1].RuleID != "warning" || broker.sent[2].RuleID != "info" {
+		t.Fatalf("unexpected notifications %+v", broker.sent)
+	}
+
+	// grouped in a single notification per destination
+	broker.sent = nil
+	client.group = true
+	if err := dispatchNotifications(client, broker, events, at); err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.sent) != 1 {
+		t.Fatalf("expected a combined notification, got %d", len(broker.sent))
+	}
+	combined := broker.sent[0]
+	if len(combined.Digest) != 3 || combined.Digest[0].RuleID != "critical" || combined.Severity != "CRITICAL" || combined.Dest != "user@example.com" {
+		t.Fatalf("unexpected combined notification %+v", combined)
+	}
+}
+
+func TestDispatchRetriesQueued(t *testing.T) {
+	routing, err := alert.ParseRouting(`{"critical": ["telegram", "slack"], "info": ["digest"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &dispatchClient{routing: routing, queues: make(map[string][]queuedNotification), quiet: true}
+	events := []alert.Event{
+		{Action: alert.Fire, Result: &common.RuleResult{RuleID: "critical", Severity: common.CRITICAL}},
+		{Action: alert.Fire, Result: &common.RuleResult{RuleID: "info", Severity: common.INFO}},
+	}
+	at := time.Date(2024, 5, 20, 22, 0, 0, 0, time.UTC)
+	if err := dispatchNotifications(client, &recordBroker{}, events, at); err != nil {
+		t.Fatal(err)
+	}
+
+	// slack and the mails fail at the end of the quiet hours
+	client.quiet = false
+	broker := &recordBroker{failing: map[string]bool{"slack": true, "mail": true}}
+	if err := dispatchNotifications(client, broker, nil, at.Add(10*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.sent) != 1 || broker.sent[0].DestType != "telegram" {
+		t.Fatalf("unexpected notifications %+v", broker.sent)
+	}
+	if len(client.queues[db.QueueQuietHours]) != 1 || len(client.queues[db.QueueDigest]) != 1 {
+		t.Fatal("expected the notifications that failed to stay queued")
+	}
+
+	// and are sent by the next fetch
+	broker = &recordBroker{}
+	if err := dispatchNotifications(client, broker, nil, at.Add(10*time.Hour+15*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.sent) != 2 || broker.sent[0].DestType != "slack" || len(broker.sent[1].Digest) != 1 {
+		t.Fatalf("unexpected notifications %+v", broker.sent)
+	}
+	if len(client.queues[db.QueueQuietHours]) != 0 || len(client.queues[db.QueueDigest]) != 0 {
+		t.Fatal("expected the queues to be empty")
+	}
+}
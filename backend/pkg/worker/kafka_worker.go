@@ -60,27 +60,13 @@ func (k *kafkaWorker) processRuleExecution(client fetcher.Client, task *common.F
 		return err
 	}
 	log.Info().Any("total_spend", task.GetTotalSpend()).Any("rule_result", results).Msg("processing rule execution")
-	notified := false
-	for _, result := range results {
-		if result.Result {
-			channel, val := client.GetNotificationChannel()
-			log.Info().Str("notification_channel", channel).Str("value", val).Msg("sending notification")
-			k.messageBroker.SendNotification(&notifier.Notification{
-				CurrentSpend: task.GetTotalSpend(),
-				Threshold:    result.Threshold,
-				RuleName:     result.RuleName,
-				RuleID:       result.RuleID,
-				DestType:     channel,
-				Dest:         val,
-			})
-			notified = true
-
-		}
-		if notified {
-			break
-		}
+	// only the transitions of the alerts are notified, a rule that keeps
+	// matching is not sent again at every fetch
+	events, err := client.ProcessAlerts(task, results)
+	if err != nil {
+		return err
 	}
-	return nil
+	return dispatchNotifications(client, k.messageBroker, events, task.End)
 
 }
 